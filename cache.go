@@ -0,0 +1,354 @@
+package net
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CacheHeader 是缓存层给由缓存提供的响应加上的头 (值为 "1"), 含 304 重新验证后返回的缓存内容.
+const CacheHeader = "X-From-Cache"
+
+// maxCacheBodySize 是可缓存响应 body 的上限 (传输字节数, 未解压); 超出的响应照常返回, 不入缓存.
+const maxCacheBodySize = 16 << 20
+
+// CacheStorage 是 HTTP 缓存的存储, 实现需并发安全. 存入的 *CachedResponse 之后不再修改,
+// Get 返回的值也不应被调用方修改.
+type CacheStorage interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, entry *CachedResponse)
+	Delete(key string)
+}
+
+// CachedResponse 是一条缓存的响应. Body 为线上原样字节 (按 Content-Encoding 编码),
+// 由 Response.Read 照常解码.
+type CachedResponse struct {
+	StatusCode   int
+	Status       string
+	Header       http.Header
+	Body         []byte
+	RequestTime  time.Time   // 发出请求的时间
+	ResponseTime time.Time   // 收到响应的时间
+	Vary         http.Header // 响应 Vary 列出的请求头在原请求中的取值
+}
+
+// ConfigureCache 开启 RFC 9111 私有缓存, storage 为 nil 关闭. 只缓存 GET:
+//   - 响应 Cache-Control max-age (或 Expires) 未过期时直接返回缓存, 不发请求;
+//   - 过期但有 ETag / Last-Modified 时带 If-None-Match / If-Modified-Since 重新验证,
+//     收到 304 时更新缓存并返回缓存内容 (状态码为原响应的);
+//   - 请求或响应带 no-store、响应 Vary: *、请求带 Range 或调用方自带条件头时不走缓存;
+//   - Vary 列出的请求头与缓存时不同视为未命中, 每个 URL 只保留最新的一个变体;
+//   - POST / PUT / PATCH / DELETE 成功后删除同 URL 的缓存.
+//
+// 没有验证器的响应只在新鲜期 > 0 时缓存; 没有显式过期时间 (max-age / Expires) 的响应只在
+// 有验证器时缓存, 且每次使用前重新验证. 缓存返回的响应带按 RFC 9111 4.2.3 计算的 Age 头.
+// 缓存位于中间件之内: 命中时中间件仍然执行. 与其他 Configure* 一样需在调用 Request 前设置好.
+func (h *HTTP) ConfigureCache(storage CacheStorage) {
+	if storage == nil {
+		h.cache = nil
+	} else {
+		h.cache = &cacheTransport{storage: storage}
+	}
+	h.applyTransport()
+}
+
+// cacheTransport 在下一层 transport 之上实现缓存逻辑.
+type cacheTransport struct {
+	next    http.RoundTripper
+	storage CacheStorage
+}
+
+func (c *cacheTransport) CloseIdleConnections() {
+	closeIdle(c.next)
+}
+
+func cacheKey(req *http.Request) string {
+	return req.URL.String()
+}
+
+func (c *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := cacheKey(req)
+	if req.Method != http.MethodGet {
+		res, err := c.next.RoundTrip(req)
+		if err == nil && isUnsafeMethod(req.Method) && res.StatusCode < 400 {
+			c.storage.Delete(key)
+		}
+		return res, err
+	}
+	requestControl := parseCacheControl(req.Header)
+	if _, ok := requestControl["no-store"]; ok || req.Header.Get("Range") != "" || hasConditional(req.Header) {
+		return c.next.RoundTrip(req)
+	}
+	entry, ok := c.storage.Get(key)
+	if ok && !entry.varyMatches(req.Header) {
+		entry, ok = nil, false
+	}
+	if now := time.Now(); ok && entry.fresh(requestControl, now) {
+		return entry.response(req, now), nil
+	}
+	outgoing := req
+	if ok && entry.hasValidator() {
+		outgoing = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			outgoing.Header.Set("If-None-Match", etag)
+		}
+		if modified := entry.Header.Get("Last-Modified"); modified != "" {
+			outgoing.Header.Set("If-Modified-Since", modified)
+		}
+	}
+	requestTime := time.Now()
+	res, err := c.next.RoundTrip(outgoing)
+	if err != nil {
+		return nil, err
+	}
+	responseTime := time.Now()
+	if outgoing != req && res.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4<<10))
+		_ = res.Body.Close()
+		entry = entry.revalidated(res.Header, requestTime, responseTime)
+		c.storage.Set(key, entry)
+		return entry.response(req, responseTime), nil
+	}
+	if !isStorable(requestControl, res, requestTime, responseTime) {
+		if ok {
+			c.storage.Delete(key)
+		}
+		return res, nil
+	}
+	stored := &CachedResponse{
+		StatusCode:   res.StatusCode,
+		Status:       res.Status,
+		Header:       res.Header.Clone(),
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+		Vary:         varyValues(res.Header, req.Header),
+	}
+	res.Body = &cacheBody{ReadCloser: res.Body, contentLength: res.ContentLength, store: func(body []byte) {
+		stored.Body = body
+		c.storage.Set(key, stored)
+	}}
+	return res, nil
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func hasConditional(header http.Header) bool {
+	for _, name := range []string{"If-None-Match", "If-Modified-Since", "If-Match", "If-Unmodified-Since", "If-Range"} {
+		if header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheableStatus 是 RFC 9110 定义为默认可缓存的状态码.
+func cacheableStatus(code int) bool {
+	switch code {
+	case 200, 203, 204, 300, 301, 308, 404, 405, 410, 414, 501:
+		return true
+	}
+	return false
+}
+
+// isStorable 按 RFC 9111 3 节判断响应能否存入私有缓存, 另要求新鲜期 > 0 或有验证器,
+// 否则存了既不能直接使用也无法重新验证.
+func isStorable(requestControl map[string]string, res *http.Response, requestTime, responseTime time.Time) bool {
+	if !cacheableStatus(res.StatusCode) {
+		return false
+	}
+	if _, ok := requestControl["no-store"]; ok {
+		return false
+	}
+	control := parseCacheControl(res.Header)
+	if _, ok := control["no-store"]; ok {
+		return false
+	}
+	if strings.TrimSpace(res.Header.Get("Vary")) == "*" {
+		return false
+	}
+	if res.Header.Get("ETag") != "" || res.Header.Get("Last-Modified") != "" {
+		return true
+	}
+	entry := &CachedResponse{Header: res.Header, RequestTime: requestTime, ResponseTime: responseTime}
+	return entry.age(responseTime) < entry.lifetime(control)
+}
+
+// parseCacheControl 解析 Cache-Control 指令, 名称转小写, 无值的指令值为 "".
+func parseCacheControl(header http.Header) map[string]string {
+	control := make(map[string]string)
+	for _, line := range header.Values("Cache-Control") {
+		for directive := range strings.SplitSeq(line, ",") {
+			directive = strings.TrimSpace(directive)
+			if directive == "" {
+				continue
+			}
+			name, value, _ := strings.Cut(directive, "=")
+			control[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return control
+}
+
+// varyValues 记录响应 Vary 列出的请求头取值.
+func varyValues(resHeader, reqHeader http.Header) http.Header {
+	var vary http.Header
+	for _, line := range resHeader.Values("Vary") {
+		for name := range strings.SplitSeq(line, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if vary == nil {
+				vary = make(http.Header)
+			}
+			vary[http.CanonicalHeaderKey(name)] = reqHeader.Values(name)
+		}
+	}
+	return vary
+}
+
+func (entry *CachedResponse) varyMatches(reqHeader http.Header) bool {
+	for name, values := range entry.Vary {
+		if strings.Join(values, ",") != strings.Join(reqHeader.Values(name), ",") {
+			return false
+		}
+	}
+	return true
+}
+
+func (entry *CachedResponse) hasValidator() bool {
+	return entry.Header.Get("ETag") != "" || entry.Header.Get("Last-Modified") != ""
+}
+
+// age 按 RFC 9111 4.2.3 计算当前年龄.
+func (entry *CachedResponse) age(now time.Time) time.Duration {
+	var apparent time.Duration
+	if date, err := http.ParseTime(entry.Header.Get("Date")); err == nil {
+		apparent = max(entry.ResponseTime.Sub(date), 0)
+	}
+	if seconds, err := strconv.Atoi(entry.Header.Get("Age")); err == nil && seconds > 0 {
+		corrected := time.Duration(seconds)*time.Second + entry.ResponseTime.Sub(entry.RequestTime)
+		apparent = max(apparent, corrected)
+	}
+	return apparent + now.Sub(entry.ResponseTime)
+}
+
+// lifetime 按 RFC 9111 4.2.1 计算新鲜期, 没有显式过期时间时为 0 (不做启发式).
+func (entry *CachedResponse) lifetime(control map[string]string) time.Duration {
+	if value, ok := control["max-age"]; ok {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		return 0
+	}
+	expires, err := http.ParseTime(entry.Header.Get("Expires"))
+	if err != nil {
+		return 0
+	}
+	date, err := http.ParseTime(entry.Header.Get("Date"))
+	if err != nil {
+		date = entry.ResponseTime
+	}
+	return max(expires.Sub(date), 0)
+}
+
+// fresh 报告缓存能否不经验证直接使用.
+func (entry *CachedResponse) fresh(requestControl map[string]string, now time.Time) bool {
+	control := parseCacheControl(entry.Header)
+	if _, ok := control["no-cache"]; ok {
+		return false
+	}
+	if _, ok := requestControl["no-cache"]; ok {
+		return false
+	}
+	age := entry.age(now)
+	if value, ok := requestControl["max-age"]; ok {
+		if seconds, err := strconv.Atoi(value); err != nil || age > time.Duration(seconds)*time.Second {
+			return false
+		}
+	}
+	return age < entry.lifetime(control)
+}
+
+// revalidated 返回按 304 响应头更新后的新条目 (RFC 9111 4.3.4), 原条目不变.
+func (entry *CachedResponse) revalidated(header http.Header, requestTime, responseTime time.Time) *CachedResponse {
+	updated := *entry
+	updated.Header = entry.Header.Clone()
+	for name, values := range header {
+		switch name {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding", "Content-Range":
+			continue
+		}
+		updated.Header[name] = values
+	}
+	updated.RequestTime = requestTime
+	updated.ResponseTime = responseTime
+	return &updated
+}
+
+// response 用缓存内容构造返回给调用方的响应, 按 RFC 9111 4.2.3 把 Age 设为当前年龄 (秒).
+func (entry *CachedResponse) response(req *http.Request, now time.Time) *http.Response {
+	header := entry.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(entry.age(now)/time.Second), 10))
+	header.Set(CacheHeader, "1")
+	return &http.Response{
+		Status:        entry.Status,
+		StatusCode:    entry.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
+
+// cacheBody 边读边复制 body, 读完后交给 store: 读到 EOF, 或关闭时已读字节数等于
+// Content-Length (调用方按长度读完不再读 EOF). 中途关闭或超过 maxCacheBodySize 不缓存.
+type cacheBody struct {
+	io.ReadCloser
+	buf           bytes.Buffer
+	contentLength int64 // 响应的 Content-Length, -1 未知
+	overflow      bool
+	store         func(body []byte)
+}
+
+func (b *cacheBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.overflow && n > 0 {
+		if b.buf.Len()+n > maxCacheBodySize {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF {
+		b.commit()
+	}
+	return n, err
+}
+
+func (b *cacheBody) Close() error {
+	if b.contentLength >= 0 && int64(b.buf.Len()) == b.contentLength {
+		b.commit()
+	}
+	b.store = nil
+	return b.ReadCloser.Close()
+}
+
+func (b *cacheBody) commit() {
+	if !b.overflow && b.store != nil {
+		b.store(bytes.Clone(b.buf.Bytes()))
+	}
+	b.store = nil
+}
@@ -0,0 +1,252 @@
+package net
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func cachedGet(t *testing.T, h *HTTP, url string, headers http.Header) (string, bool) {
+	t.Helper()
+	res, err := h.Request(context.Background(), url, headers, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	fromCache := res.Header.Get(CacheHeader) == "1"
+	text, err := res.Text()
+	if err != nil {
+		t.Fatalf("read body failed: %v", err)
+	}
+	return text, fromCache
+}
+
+func TestHTTPCacheMaxAgeAndRevalidation(t *testing.T) {
+	var calls, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = w.Write([]byte("fresh"))
+		case "/etag":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Cache-Control", "no-cache")
+			_, _ = w.Write([]byte("tagged"))
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+			_, _ = w.Write([]byte("secret"))
+		}
+	}))
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	storage := NewMemoryCache(0)
+	h.ConfigureCache(storage)
+
+	if text, hit := cachedGet(t, h, server.URL+"/fresh", nil); text != "fresh" || hit {
+		t.Fatalf("first /fresh = %q, hit=%v", text, hit)
+	}
+	if text, hit := cachedGet(t, h, server.URL+"/fresh", nil); text != "fresh" || !hit {
+		t.Fatalf("second /fresh = %q, hit=%v; want cached", text, hit)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("server calls = %d, want 1", calls.Load())
+	}
+	if _, hit := cachedGet(t, h, server.URL+"/fresh", http.Header{"Cache-Control": {"no-cache"}}); hit {
+		t.Fatal("request no-cache must not be served from cache without validation")
+	}
+
+	calls.Store(0)
+	cachedGet(t, h, server.URL+"/etag", nil)
+	if text, hit := cachedGet(t, h, server.URL+"/etag", nil); text != "tagged" || !hit {
+		t.Fatalf("revalidated /etag = %q, hit=%v", text, hit)
+	}
+	if calls.Load() != 2 || notModified.Load() != 1 {
+		t.Fatalf("calls = %d, 304s = %d; want 2 and 1", calls.Load(), notModified.Load())
+	}
+
+	cachedGet(t, h, server.URL+"/nostore", nil)
+	if _, ok := storage.Get(server.URL + "/nostore"); ok {
+		t.Fatal("no-store response was cached")
+	}
+
+	res, err := h.RequestMethod(context.Background(), server.URL+"/fresh", http.MethodDelete, nil, nil)
+	if err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	_ = res.Close()
+	if _, ok := storage.Get(server.URL + "/fresh"); ok {
+		t.Fatal("successful DELETE did not invalidate the cache entry")
+	}
+}
+
+func TestHTTPCacheVary(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = w.Write([]byte("lang=" + r.Header.Get("Accept-Language")))
+	}))
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	h.ConfigureCache(NewMemoryCache(0))
+
+	en := http.Header{"Accept-Language": {"en"}}
+	cachedGet(t, h, server.URL, en)
+	if text, hit := cachedGet(t, h, server.URL, en); !hit || text != "lang=en" {
+		t.Fatalf("same Vary value = %q, hit=%v; want cached", text, hit)
+	}
+	if text, hit := cachedGet(t, h, server.URL, http.Header{"Accept-Language": {"fr"}}); hit || text != "lang=fr" {
+		t.Fatalf("different Vary value = %q, hit=%v; want miss", text, hit)
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("server calls = %d, want 2", calls.Load())
+	}
+}
+
+func TestCachedResponseFreshness(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	entry := &CachedResponse{
+		Header: http.Header{
+			"Date":    {now.Format(http.TimeFormat)},
+			"Expires": {now.Add(time.Minute).Format(http.TimeFormat)},
+			"Age":     {"30"},
+		},
+		RequestTime:  now,
+		ResponseTime: now,
+	}
+	if !entry.fresh(nil, now.Add(20*time.Second)) {
+		t.Fatal("entry aged 50s with 60s lifetime should be fresh")
+	}
+	if entry.fresh(nil, now.Add(31*time.Second)) {
+		t.Fatal("entry aged 61s with 60s lifetime should be stale")
+	}
+	if entry.fresh(map[string]string{"max-age": "10"}, now) {
+		t.Fatal("request max-age=10 must reject an entry aged 30s")
+	}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryCache(10)
+	entry := func(body string) *CachedResponse { return &CachedResponse{Body: []byte(body)} }
+	cache.Set("a", entry("aaaa"))
+	cache.Set("b", entry("bbbb"))
+	cache.Get("a")
+	cache.Set("c", entry("cccc"))
+	if _, ok := cache.Get("b"); ok {
+		t.Fatal("least recently used entry b was not evicted")
+	}
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("recently used entry a was evicted")
+	}
+	cache.Set("big", entry(strings.Repeat("x", 11)))
+	if _, ok := cache.Get("big"); ok || cache.Len() != 2 {
+		t.Fatalf("oversized entry stored or evicted others: len=%d", cache.Len())
+	}
+}
+
+func TestDiskCacheRoundTrip(t *testing.T) {
+	cache, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDiskCache failed: %v", err)
+	}
+	want := &CachedResponse{
+		StatusCode: 200,
+		Status:     "200 OK",
+		Header:     http.Header{"Etag": {`"v1"`}},
+		Body:       []byte("body"),
+		Vary:       http.Header{"Accept-Language": {"en"}},
+	}
+	cache.Set("https://example.com/a", want)
+	got, ok := cache.Get("https://example.com/a")
+	if !ok || string(got.Body) != "body" || got.Header.Get("ETag") != `"v1"` || got.Vary.Get("Accept-Language") != "en" {
+		t.Fatalf("Get = %+v, %v", got, ok)
+	}
+	cache.Delete("https://example.com/a")
+	if _, ok := cache.Get("https://example.com/a"); ok {
+		t.Fatal("entry still present after Delete")
+	}
+}
+
+func TestHTTPCacheSkipsUnusableAndSetsAge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/zero":
+			w.Header().Set("Cache-Control", "max-age=0")
+		case "/expired":
+			w.Header().Set("Expires", "Thu, 01 Jan 1970 00:00:00 GMT")
+		case "/aged":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Age", "10")
+		}
+		_, _ = w.Write([]byte("body"))
+	}))
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	storage := NewMemoryCache(0)
+	h.ConfigureCache(storage)
+
+	for _, path := range []string{"/zero", "/expired"} {
+		cachedGet(t, h, server.URL+path, nil)
+		if _, ok := storage.Get(server.URL + path); ok {
+			t.Fatalf("%s: response without lifetime or validator was cached", path)
+		}
+	}
+
+	cachedGet(t, h, server.URL+"/aged", nil)
+	res, err := h.Request(context.Background(), server.URL+"/aged", nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer res.Close()
+	if age, _ := strconv.Atoi(res.Header.Get("Age")); res.Header.Get(CacheHeader) != "1" || age < 10 {
+		t.Fatalf("cached Age = %q, want >= 10", res.Header.Get("Age"))
+	}
+}
+
+func TestCacheBodyStoresOnCloseAtContentLength(t *testing.T) {
+	var stored []byte
+	newBody := func() *cacheBody {
+		stored = nil
+		return &cacheBody{
+			ReadCloser:    io.NopCloser(strings.NewReader("body")),
+			contentLength: 4,
+			store:         func(body []byte) { stored = body },
+		}
+	}
+	// 按 Content-Length 读满后直接关闭, 不再读到 EOF.
+	body := newBody()
+	if _, err := io.ReadFull(body, make([]byte, 4)); err != nil {
+		t.Fatal(err)
+	}
+	_ = body.Close()
+	if string(stored) != "body" {
+		t.Fatalf("stored = %q, want body read up to Content-Length", stored)
+	}
+
+	body = newBody()
+	if _, err := io.ReadFull(body, make([]byte, 2)); err != nil {
+		t.Fatal(err)
+	}
+	_ = body.Close()
+	if stored != nil {
+		t.Fatalf("partially read body was stored: %q", stored)
+	}
+}
@@ -0,0 +1,164 @@
+package net
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemoryCache 是按 body 字节数限制容量的内存 LRU CacheStorage.
+type MemoryCache struct {
+	maxBytes int64
+
+	locker  sync.Mutex
+	size    int64
+	order   *list.List // 队首为最近使用
+	entries map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CachedResponse
+}
+
+// NewMemoryCache 创建内存 LRU 缓存, 超过 maxBytes (按 body 字节计) 时淘汰最久未用的条目.
+// maxBytes <= 0 表示不限.
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (cache *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	cache.locker.Lock()
+	defer cache.locker.Unlock()
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	return element.Value.(*memoryCacheItem).entry, true
+}
+
+func (cache *MemoryCache) Set(key string, entry *CachedResponse) {
+	cache.locker.Lock()
+	defer cache.locker.Unlock()
+	if element, ok := cache.entries[key]; ok {
+		cache.removeLocked(element)
+	}
+	if cache.maxBytes > 0 && int64(len(entry.Body)) > cache.maxBytes {
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(&memoryCacheItem{key: key, entry: entry})
+	cache.size += int64(len(entry.Body))
+	for cache.maxBytes > 0 && cache.size > cache.maxBytes {
+		cache.removeLocked(cache.order.Back())
+	}
+}
+
+func (cache *MemoryCache) Delete(key string) {
+	cache.locker.Lock()
+	defer cache.locker.Unlock()
+	if element, ok := cache.entries[key]; ok {
+		cache.removeLocked(element)
+	}
+}
+
+// Len 返回当前条目数.
+func (cache *MemoryCache) Len() int {
+	cache.locker.Lock()
+	defer cache.locker.Unlock()
+	return len(cache.entries)
+}
+
+func (cache *MemoryCache) removeLocked(element *list.Element) {
+	item := cache.order.Remove(element).(*memoryCacheItem)
+	delete(cache.entries, item.key)
+	cache.size -= int64(len(item.entry.Body))
+}
+
+// DiskCache 是以目录存放的 CacheStorage, 每个条目一个 JSON 文件 (文件名为 key 的 SHA-256).
+// 不限容量, 需要时由调用方清理目录. 读写失败按未命中处理并记 warn.
+type DiskCache struct {
+	dir    string
+	locker sync.RWMutex
+}
+
+// diskCacheFile 是磁盘上的条目格式, 带上 key 防止哈希碰撞时取错.
+type diskCacheFile struct {
+	Key   string
+	Entry *CachedResponse
+}
+
+// NewDiskCache 创建以 dir 为目录的磁盘缓存, 目录不存在时创建.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (cache *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(cache.dir, hex.EncodeToString(sum[:]))
+}
+
+func (cache *DiskCache) Get(key string) (*CachedResponse, bool) {
+	cache.locker.RLock()
+	data, err := os.ReadFile(cache.path(key))
+	cache.locker.RUnlock()
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("net.DiskCache read failed", slog.Any("err", err))
+		}
+		return nil, false
+	}
+	var file diskCacheFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Key != key || file.Entry == nil {
+		return nil, false
+	}
+	return file.Entry, true
+}
+
+func (cache *DiskCache) Set(key string, entry *CachedResponse) {
+	data, err := json.Marshal(&diskCacheFile{Key: key, Entry: entry})
+	if err != nil {
+		slog.Warn("net.DiskCache encode failed", slog.Any("err", err))
+		return
+	}
+	cache.locker.Lock()
+	defer cache.locker.Unlock()
+	// 先写临时文件再 rename, 避免并发读到写了一半的条目.
+	target := cache.path(key)
+	temp, err := os.CreateTemp(cache.dir, ".tmp-*")
+	if err != nil {
+		slog.Warn("net.DiskCache write failed", slog.Any("err", err))
+		return
+	}
+	_, err = temp.Write(data)
+	err = errors.Join(err, temp.Close())
+	if err == nil {
+		err = os.Rename(temp.Name(), target)
+	}
+	if err != nil {
+		_ = os.Remove(temp.Name())
+		slog.Warn("net.DiskCache write failed", slog.Any("err", err))
+	}
+}
+
+func (cache *DiskCache) Delete(key string) {
+	cache.locker.Lock()
+	defer cache.locker.Unlock()
+	if err := os.Remove(cache.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("net.DiskCache delete failed", slog.Any("err", err))
+	}
+}
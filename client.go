@@ -4,10 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"iter"
 	"log/slog"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/zdypro888/net/metrics"
 )
 
 // ErrNotConnected: Client 从未通过 Reset/ResetUnsafe 建立过连接.
@@ -25,12 +28,26 @@ const DefaultAsyncTimeout = 30 * time.Second
 
 // ClientStats 给运维查 net.Client 健康度.
 type ClientStats struct {
-	AsynchanLen   int    // 当前 asynchan 队列长度 (高 = caller 投递快/asyncGo 处理慢)
-	AsynchanCap   int    // asynchan 容量
-	HeartCount    uint64 // 心跳累计次数 (跨 Reset 归零)
-	AsyncTimeouts uint64 // async 入队等待超时 (DefaultAsyncTimeout 兜底或 caller deadline 到期) 的累计次数; 不含 caller 主动取消
-	LastError     error  // 最近一次 lastError 快照
-	Closed        bool   // 是否已 Close
+	AsynchanLen   int           // 当前 asynchan (PriorityNormal) 队列长度 (高 = caller 投递快/asyncGo 处理慢)
+	HighLen       int           // 当前 PriorityHigh 队列长度 (容量同 AsynchanCap)
+	LowLen        int           // 当前 PriorityLow 队列长度 (容量同 AsynchanCap)
+	AsynchanCap   int           // asynchan 容量
+	DispatchLen   int           // SetHandleDispatch 开启时所有 worker 队列中待 Handle 的消息数
+	DispatchCap   int           // SetHandleDispatch 开启时所有 worker 队列的总容量 (未开启为 0)
+	InFlight      int64         // 当前等待响应的请求数 (SetMaxInFlight 限制的对象)
+	InFlightPeak  int64         // InFlight 历史最高值 (跨 Reset 保留)
+	HeartCount    uint64        // 心跳累计次数 (跨 Reset 归零)
+	RTT           time.Duration // 心跳往返时延的滑动平均 (需实现 ConnHeartAck; 无样本为 0, 跨 Reset 归零)
+	LastRTT       time.Duration // 最近一次心跳往返时延
+	LastRead      time.Time     // 最近一次从连接读到消息的时间 (未连接为零值)
+	RateLimited   uint64        // 因 SetRateLimit 令牌不足而推迟写出的累计次数
+	WriteBatches  uint64        // 经 BatchWriter 合并写出的累计次数
+	DedupShared   uint64        // SetDedup 开启时加入已在途的相同请求、未单独发送的累计次数
+	DedupHits     uint64        // SetDedup 缓存命中的累计次数
+	AsyncTimeouts uint64        // async 入队等待超时 (DefaultAsyncTimeout 兜底或 caller deadline 到期) 的累计次数; 不含 caller 主动取消
+	Reconnects    uint64        // Supervise 模式下首次连接之后重新建立连接的累计次数
+	LastError     error         // 最近一次 lastError 快照
+	Closed        bool          // 是否已 Close
 }
 
 // Client 是一个支持请求-响应模式的多路复用网络客户端。
@@ -60,8 +77,24 @@ type Client[M any, T Conn[M]] struct {
 	heartTime  time.Time // 下次心跳时间
 	bufferSize int
 
-	asynchan chan *asynRequest[M, T]
-	stopChan chan struct{} // 停止信号; 由 stopOnce 保证只 close 一次
+	// 活性检测 (见 liveness.go). readIdleTimeout 在 Lock 下写, ResetUnsafe 取快照交给
+	// asyncGo; rtt / lastRTT / lastRead 由 asyncGo / receiveGo 原子更新, Stats 原子读.
+	readIdleTimeout time.Duration
+	rtt             atomic.Int64
+	lastRTT         atomic.Int64
+	lastRead        atomic.Int64
+
+	// 并发 Handle 分发配置 (见 dispatch.go), Lock 下写; dispatcher 是当前连接的
+	// worker 池, ResetUnsafe 创建, Stats 在 RLock 下读.
+	dispatchWorkers   int
+	dispatchQueueSize int
+	dispatchKey       func(M) string
+	dispatcher        *handleDispatcher[M]
+
+	asynchan chan *asynRequest[M, T] // PriorityNormal 发送队列
+	highchan chan *asynRequest[M, T] // PriorityHigh 发送队列 (见 priority.go), 与 asynchan 同生命周期
+	lowchan  chan *asynRequest[M, T] // PriorityLow 发送队列
+	stopChan chan struct{}           // 停止信号; 由 stopOnce 保证只 close 一次
 
 	// handleCancel 取消 *仅* 传给 conn.Handle 的 handleCtx (cctx 的子 ctx). D-P1-1 修复:
 	// asyncGo 主循环里 conn.Handle 是同步调用, 用户 Handle 若长时间阻塞 (例如往满通道写)
@@ -83,9 +116,51 @@ type Client[M any, T Conn[M]] struct {
 	// 必须加: 多 goroutine 关闭同一 chan 是经典 Go 反模式, sync.Once 是唯一 idiomatic 方案.
 	stopOnce sync.Once
 
+	// resetSignal 在每次 ResetUnsafe 时 close 并换新, 让 PendingWaitReconnect 模式下
+	// 等待重连的 Request / Write 感知"新连接已就绪". 在 Lock 下换, RLock 下读.
+	resetSignal chan struct{}
+	// supervise 状态 (见 supervisor.go), 均在 Lock 下读写; pendingPolicy 在 RLock 下读.
+	superviseCancel context.CancelFunc
+	superviseDone   chan struct{}
+	pendingPolicy   PendingPolicy
+
+	// 拦截器链 (见 interceptor.go). copy-on-write: Add* 在 Lock 下换新 slice,
+	// 调用路径原子读取, *Unsafe 不持锁也可安全读.
+	unaryInterceptors atomic.Pointer[[]UnaryInterceptor[M]]
+	writeInterceptors atomic.Pointer[[]WriteInterceptor[M]]
+
+	// 在途请求限制 (见 inflight.go). inflightLimit 由 SetMaxInFlight 在 Lock 下换新,
+	// acquire 路径不持锁原子读取.
+	inflightLimit atomic.Pointer[inflightLimiter]
+	inflight      atomic.Int64
+	inflightPeak  atomic.Int64
+
+	// 优先级队列调度 (见 priority.go), nil 为 PriorityStrict.
+	prioritySchedule atomic.Pointer[prioritySchedule]
+
+	// 发送限速与合并写 (见 ratelimit.go). rateLimit 由 SetRateLimit 换新, 令牌桶只由
+	// asyncGo 修改.
+	rateLimit      atomic.Pointer[rateLimiter]
+	writeBatchSize atomic.Int64
+	rateLimited    atomic.Uint64
+	throttling     atomic.Bool // 有请求在等待令牌 (已出 asynchan), Shutdown 据此继续等待
+	writeBatches   atomic.Uint64
+
+	// 旁路捕获 (见 tap.go) 与观测回调 (见 observe.go), Set* 换新, 读写协程原子读.
+	tap      atomic.Pointer[func(TapEvent[M])]
+	observer atomic.Pointer[metrics.Observer]
+
+	// Request 去重 (见 dedup.go), SetDedup 整体换新.
+	deduper     atomic.Pointer[deduper[M]]
+	dedupShared atomic.Uint64
+	dedupHits   atomic.Uint64
+
 	// 观察性 (OPS-3 Stats).
 	asyncTimeouts atomic.Uint64
+	reconnects    atomic.Uint64
 	closed        atomic.Bool
+	draining      atomic.Int32 // 进行中的 Shutdown 数, 非 0 时 async 拒绝新的发送
+	drained       drainSignal  // 在途请求归零 / asyncGo 处理完一轮时唤醒 Shutdown
 }
 
 type AsyncCommand int
@@ -93,17 +168,19 @@ type AsyncCommand int
 const (
 	AsyncCommandSend AsyncCommand = iota + 1
 	AsyncCommandCallback
+	asyncCommandPending // 内部: 在 asyncGo 中访问等待队列 (见 pending.go)
 )
 
 type asynRequest[M any, T Conn[M]] struct {
 	Command  AsyncCommand
 	Callback func(ctx context.Context, conn T)
 	Message  *asyncMessage[M]
+	pending  func(ctx context.Context, conn T, notifys map[any]*asyncMessage[M])
 }
 
 // NewClient 创建并启动一个新的 Client。
 func NewClient[M any, T Conn[M]]() *Client[M, T] {
-	client := &Client[M, T]{bufferSize: DefaultBufferSize}
+	client := &Client[M, T]{bufferSize: DefaultBufferSize, resetSignal: make(chan struct{})}
 	return client
 }
 
@@ -157,6 +234,11 @@ func (client *Client[M, T]) ResetUnsafe(ctx context.Context, conn T) {
 	client.stopOnce = sync.Once{}
 	client.closed.Store(false)
 	client.setLastError(nil)
+	// 唤醒等待重连的 caller (PendingWaitReconnect), 并为下一次断线准备新信号.
+	if client.resetSignal != nil {
+		close(client.resetSignal)
+	}
+	client.resetSignal = make(chan struct{})
 
 	// 创建新的通道
 	stopChan := make(chan struct{})
@@ -167,6 +249,9 @@ func (client *Client[M, T]) ResetUnsafe(ctx context.Context, conn T) {
 	}
 	asynchan := make(chan *asynRequest[M, T], bufSize)
 	client.asynchan = asynchan
+	client.highchan = make(chan *asynRequest[M, T], bufSize)
+	client.lowchan = make(chan *asynRequest[M, T], bufSize)
+	lanes := [laneCount]<-chan *asynRequest[M, T]{laneHigh: client.highchan, laneNormal: asynchan, laneLow: client.lowchan}
 	recvchan := make(chan M, bufSize)
 	cctx, cancel := context.WithCancel(ctx)
 	// handleCtx 是 cctx 的子 ctx, 仅用于 conn.Handle (D-P1-1). CloseUnsafe cancel 它
@@ -177,6 +262,11 @@ func (client *Client[M, T]) ResetUnsafe(ctx context.Context, conn T) {
 
 	// 心跳计数随会话归零, 避免新连接拿到上一次会话累计的 count.
 	client.heartCount.Store(0)
+	// RTT 随会话归零; lastRead 从连接建立算起, 空闲检测不会立刻命中.
+	client.rtt.Store(0)
+	client.lastRTT.Store(0)
+	client.lastRead.Store(time.Now().UnixNano())
+	readIdleTimeout := client.readIdleTimeout
 	// 初始化心跳时间
 	if heartConn, ok := any(conn).(ConnHeart[M]); ok {
 		_, heartTime, _ := heartConn.Heart(true, 0)
@@ -185,10 +275,21 @@ func (client *Client[M, T]) ResetUnsafe(ctx context.Context, conn T) {
 		client.heartTime = time.Now().Add(60 * time.Second)
 	}
 
+	// Handle worker 池挂在 handleCtx 上, 由 asyncGo 退出尾段关闭并等待.
+	var dispatcher *handleDispatcher[M]
+	if client.dispatchWorkers > 0 {
+		queueSize := client.dispatchQueueSize
+		if queueSize <= 0 {
+			queueSize = bufSize
+		}
+		dispatcher = newHandleDispatcher(handleCtx, conn.Handle, client.dispatchWorkers, queueSize, client.dispatchKey)
+	}
+	client.dispatcher = dispatcher
+
 	// 启动工作协程. 用 Go 1.25 WaitGroup.Go 自动 Add(1)/Done, 避免显式
 	// Add/Done 配对错位的经典坑.
 	client.waiter.Go(func() {
-		client.asyncGo(cctx, handleCtx, cancel, handleCancel, conn, asynchan, recvchan)
+		client.asyncGo(cctx, handleCtx, cancel, handleCancel, conn, dispatcher, readIdleTimeout, lanes, recvchan)
 	})
 	client.waiter.Go(func() { client.receiveGo(cctx, conn, recvchan) })
 }
@@ -220,22 +321,29 @@ func (client *Client[M, T]) CloseUnsafe() {
 		client.handleCancel()
 	}
 	if client.asynchan != nil {
-		// asynchan 只可以在 locker 保护下关闭
+		// asynchan 只可以在 locker 保护下关闭; 优先级队列随之关闭.
 		close(client.asynchan)
-		client.asynchan = nil
+		close(client.highchan)
+		close(client.lowchan)
+		client.asynchan, client.highchan, client.lowchan = nil, nil, nil
 	}
 }
 
 // Close 关闭 Client 并释放所有资源。
-// 会等待所有 goroutine 退出后返回。
+// 会等待所有 goroutine 退出后返回。Supervise 模式下同时停止重连并等待 supervisor 退出。
 // 返回连接期间最后发生的错误（如果有）。
 func (client *Client[M, T]) Close() error {
 	client.locker.Lock()
-	defer client.locker.Unlock()
+	superviseDone := client.stopSuperviseLocked()
 	client.CloseUnsafe()
 	// 等待所有 goroutine 退出
 	client.waiter.Wait()
 	err := client.getLastError()
+	client.locker.Unlock()
+	// supervisor 退出路径要取 Lock, 必须在释放锁之后等.
+	if superviseDone != nil {
+		<-superviseDone
+	}
 	return err
 }
 
@@ -244,16 +352,33 @@ func (client *Client[M, T]) Close() error {
 func (client *Client[M, T]) Stats() ClientStats {
 	stats := ClientStats{
 		HeartCount:    client.heartCount.Load(),
+		RTT:           time.Duration(client.rtt.Load()),
+		LastRTT:       time.Duration(client.lastRTT.Load()),
 		AsyncTimeouts: client.asyncTimeouts.Load(),
+		RateLimited:   client.rateLimited.Load(),
+		WriteBatches:  client.writeBatches.Load(),
+		DedupShared:   client.dedupShared.Load(),
+		DedupHits:     client.dedupHits.Load(),
+		Reconnects:    client.reconnects.Load(),
+		InFlight:      client.inflight.Load(),
+		InFlightPeak:  client.inflightPeak.Load(),
 		Closed:        client.closed.Load(),
 	}
 	client.locker.RLock()
 	if client.asynchan != nil {
 		stats.AsynchanLen = len(client.asynchan)
 		stats.AsynchanCap = cap(client.asynchan)
+		stats.HighLen = len(client.highchan)
+		stats.LowLen = len(client.lowchan)
+	}
+	if client.dispatcher != nil {
+		stats.DispatchLen, stats.DispatchCap = client.dispatcher.queueLen()
 	}
 	stats.LastError = client.getLastError()
 	client.locker.RUnlock()
+	if lastRead := client.lastRead.Load(); lastRead != 0 {
+		stats.LastRead = time.Unix(0, lastRead)
+	}
 	return stats
 }
 
@@ -271,6 +396,8 @@ func (client *Client[M, T]) receiveGo(ctx context.Context, conn T, recvchan chan
 			}
 			break
 		}
+		client.lastRead.Store(time.Now().UnixNano())
+		client.traffic(TapRead, data)
 		select {
 		case recvchan <- data:
 		case <-ctx.Done():
@@ -284,16 +411,121 @@ func (client *Client[M, T]) receiveGo(ctx context.Context, conn T, recvchan chan
 // asyncGo 是异步处理协程，负责：
 // 1. 处理发送队列（sendchan）中的请求
 // 2. 处理接收队列（recvchan）中的响应
-// 3. 匹配请求和响应（通过 Notify.Id）
-// 4. 分发未匹配的消息到 Handle
-func (client *Client[M, T]) asyncGo(ctx context.Context, handleCtx context.Context, cancel context.CancelFunc, handleCancel context.CancelFunc, conn T, asynchan <-chan *asynRequest[M, T], recvchan <-chan M) {
-	// asyncNotifys 存储等待响应的请求，key 是 Notify.Id()
+// 3. 匹配请求和响应（通过 Notify.Id, 批量消息通过 NotifyBatchMessage.Ids）; Stream 请求可匹配多条, 直到 StreamEnd
+// 4. 分发未匹配的消息到 Handle (dispatcher 非 nil 时交给 worker 池)
+// 5. 心跳发送、心跳应答的 RTT 统计与读空闲检测 (readIdleTimeout > 0 时)
+func (client *Client[M, T]) asyncGo(ctx context.Context, handleCtx context.Context, cancel context.CancelFunc, handleCancel context.CancelFunc, conn T, dispatcher *handleDispatcher[M], readIdleTimeout time.Duration, laneChans [laneCount]<-chan *asynRequest[M, T], recvchan <-chan M) {
+	// 连接即会话: 用同一个 Observer 成对上报, 不受期间 SetObserver 影响.
+	if observer := client.loadObserver(); observer != nil {
+		observer.SessionOpen(observerComponent)
+		defer observer.SessionClose(observerComponent)
+	}
+	// asyncNotifys 存储等待响应的请求，key 是 Notify.Id() (批量消息的每个 Ids 各占一项, 指向同一请求)
 	asyncNotifys := make(map[any]*asyncMessage[M])
 	var zeroM M
 	running := true
 	heartTimer := time.NewTimer(time.Until(client.heartTime))
 	defer heartTimer.Stop()
+	ackConn, hasAck := any(conn).(ConnHeartAck[M])
+	var heartSentAt time.Time // 最近一次尚未收到应答的心跳发送时间
+	// 读空闲检测: 未开启时 idleChan 为 nil, select 永不命中.
+	var idleTimer *time.Timer
+	var idleChan <-chan time.Time
+	if readIdleTimeout > 0 {
+		idleTimer = time.NewTimer(readIdleTimeout)
+		defer idleTimer.Stop()
+		idleChan = idleTimer.C
+	}
+	// 优先级发送队列 (见 priority.go): select 取出的请求先暂存, 再按调度策略挑选执行.
+	lanes := &sendLanes[M, T]{chans: laneChans, schedule: &client.prioritySchedule}
+	// 发送限速与合并写 (见 ratelimit.go). throttled 是等待令牌的请求: 等待期间不再取
+	// 新请求 (保持顺序), 接收与心跳照常处理.
+	batchConn, canBatch := any(conn).(BatchWriter[M])
+	var throttled *asynRequest[M, T]
+	var throttleTimer *time.Timer
+	var throttleChan <-chan time.Time
+	var throttleDone <-chan struct{}
+	defer func() {
+		if throttleTimer != nil {
+			throttleTimer.Stop()
+		}
+	}()
+	// register 处理写出结果: 成功的 Notify 请求登记到等待队列, 失败的立即通知 caller.
+	register := func(message *asyncMessage[M], err error) {
+		if !message.Notify {
+			return
+		}
+		if err != nil {
+			message.teardown.Store(true)
+			message.Response(zeroM, err)
+			return
+		}
+		if ids := notifyIDs(message.Data); len(ids) > 0 {
+			// 注册到等待队列
+			for _, notifyId := range ids {
+				asyncNotifys[notifyId] = message
+			}
+			return
+		}
+		// 没有实现 Notify 接口，无法匹配响应
+		message.Response(zeroM, fmt.Errorf("message does not implement Notify interface"))
+	}
+	// process 执行一条队列命令, 返回 false 表示写失败 asyncGo 应退出.
+	var process func(asyncall *asynRequest[M, T]) bool
+	process = func(asyncall *asynRequest[M, T]) bool {
+		switch asyncall.Command {
+		case AsyncCommandSend:
+			if delay := client.reserveWrite(asyncall.Message.Data); delay > 0 {
+				client.rateLimited.Add(1)
+				if throttleTimer == nil {
+					throttleTimer = time.NewTimer(delay)
+				} else {
+					throttleTimer.Reset(delay)
+				}
+				throttled, throttleChan, throttleDone = asyncall, throttleTimer.C, handleCtx.Done()
+				client.throttling.Store(true)
+				return true
+			}
+			messages := []*asyncMessage[M]{asyncall.Message}
+			var next *asynRequest[M, T]
+			if canBatch {
+				messages, next = client.collectBatch(lanes.next, messages)
+			}
+			// 写入数据到连接
+			err := client.writeMessages(ctx, conn, batchConn, messages)
+			for _, message := range messages {
+				register(message, err)
+			}
+			if err != nil {
+				client.setLastError(err)
+				if next != nil && next.Command == AsyncCommandSend {
+					register(next.Message, err)
+				}
+				return false
+			}
+			if next != nil {
+				return process(next)
+			}
+		case AsyncCommandCallback:
+			if asyncall.Callback != nil {
+				asyncall.Callback(ctx, conn)
+			}
+		case asyncCommandPending:
+			asyncall.pending(ctx, conn, asyncNotifys)
+		}
+		return true
+	}
 	for running {
+		// 有暂存请求时不再从队列取 (ready 分支继续执行), 等待令牌时两者都暂停.
+		var highchan, sendchan, lowchan <-chan *asynRequest[M, T]
+		var stashReady <-chan struct{}
+		if throttled == nil {
+			if lanes.stashed != nil {
+				stashReady = readyChan
+			} else {
+				highchan, sendchan, lowchan = laneChans[laneHigh], laneChans[laneNormal], laneChans[laneLow]
+			}
+		}
 		select {
 		case <-ctx.Done():
 			// context 被取消
@@ -304,74 +536,68 @@ func (client *Client[M, T]) asyncGo(ctx context.Context, handleCtx context.Conte
 			if !ok {
 				// recvchan 已关闭，receiveGo 已退出
 				running = false
+			} else if hasAck && ackConn.HeartAck(recv) {
+				// 心跳应答: 只用于 RTT, 不参与请求匹配. 无对应心跳 (重复应答) 时忽略.
+				if !heartSentAt.IsZero() {
+					client.observeRTT(time.Since(heartSentAt))
+					heartSentAt = time.Time{}
+				}
 			} else {
 				// 尝试匹配请求
 				foundNotify := false
-				if notify, ok := any(recv).(NotifyMessage); ok {
-					if notifyId, ok := notify.Id(); ok {
-						if asyncRequest, ok := asyncNotifys[notifyId]; ok {
-							// 找到匹配的请求，发送响应
-							asyncRequest.Response(recv, nil)
-							delete(asyncNotifys, notifyId)
-							foundNotify = true
+				if asyncRequest, ok := matchNotify(asyncNotifys, recv); ok {
+					// 找到匹配的请求，发送响应. Stream 请求在流结束
+					// (StreamEnd / 消费者离开) 前保留在等待队列.
+					if asyncRequest.stream != nil {
+						if !asyncRequest.deliver(handleCtx, recv) {
+							unregisterNotify(asyncNotifys, asyncRequest)
 						}
+					} else {
+						asyncRequest.Response(recv, nil)
+						unregisterNotify(asyncNotifys, asyncRequest)
 					}
+					foundNotify = true
 				}
 				if !foundNotify {
 					// 无匹配请求，作为服务端推送处理. 用 handleCtx (Close 时被 cancel)
 					// 而非 ctx: 让阻塞的用户 Handle 在 Close 发起时能解阻塞 (D-P1-1).
-					conn.Handle(handleCtx, recv)
-				}
-			}
-		case asyncall, ok := <-asynchan:
-			// 处理发送请求
-			if !ok {
-				// sendchan 已关闭，Client 正在关闭
-				running = false
-			} else {
-				switch asyncall.Command {
-				case AsyncCommandSend:
-					asyncRequest := asyncall.Message
-					// 写入数据到连接
-					err := conn.Write(ctx, asyncRequest.Data)
-					if asyncRequest.Notify {
-						if err != nil {
-							asyncRequest.Response(zeroM, err)
-						} else {
-							// 注册到等待队列
-							if notify, ok := any(asyncRequest.Data).(NotifyMessage); ok {
-								if notifyId, ok := notify.Id(); ok {
-									// 注册到等待队列
-									asyncNotifys[notifyId] = asyncRequest
-									asyncRequest = nil
-								}
-							}
-							if asyncRequest != nil {
-								// 没有实现 Notify 接口，无法匹配响应
-								asyncRequest.Response(zeroM, fmt.Errorf("message does not implement Notify interface"))
-							}
-						}
-					}
-					if err != nil {
-						client.setLastError(err)
-						running = false
-					}
-				case AsyncCommandCallback:
-					if asyncall.Callback != nil {
-						asyncall.Callback(ctx, conn)
+					if dispatcher != nil {
+						dispatcher.dispatch(handleCtx, recv)
+					} else {
+						conn.Handle(handleCtx, recv)
 					}
 				}
-
 			}
+		case asyncall, ok := <-highchan:
+			running = ok && process(lanes.stash(laneHigh, asyncall))
+		case asyncall, ok := <-sendchan:
+			// 处理发送请求. 队列已关闭说明 Client 正在关闭.
+			running = ok && process(lanes.stash(laneNormal, asyncall))
+		case asyncall, ok := <-lowchan:
+			running = ok && process(lanes.stash(laneLow, asyncall))
+		case <-stashReady:
+			running = process(lanes.next())
+		case <-throttleChan:
+			// 令牌已补足, 恢复从发送队列取消息.
+			asyncall := throttled
+			throttled, throttleChan, throttleDone = nil, nil, nil
+			client.throttling.Store(false)
+			running = process(asyncall)
+		case <-throttleDone:
+			// 等待令牌期间不读发送队列, 靠 handleCtx 感知 Close / Reset.
+			running = false
 		case <-heartTimer.C:
 			// 处理心跳
 			if heartConn, ok := any(conn).(ConnHeart[M]); ok {
 				var heartData M
 				var heartHasData bool
 				if heartData, client.heartTime, heartHasData = heartConn.Heart(false, client.heartCount.Add(1)); heartHasData {
+					client.traffic(TapWrite, heartData)
 					if err := conn.Write(ctx, heartData); err != nil {
 						client.setLastError(err)
 						running = false
+					} else if hasAck {
+						heartSentAt = time.Now()
 					}
 				}
 			} else {
@@ -385,6 +611,20 @@ func (client *Client[M, T]) asyncGo(ctx context.Context, handleCtx context.Conte
 				delay = time.Second
 			}
 			heartTimer.Reset(delay)
+		case <-idleChan:
+			// receiveGo 每读到一条消息刷新 lastRead; 到期时按最近一次读取重新计算剩余时间.
+			idle := time.Since(time.Unix(0, client.lastRead.Load()))
+			if idle >= readIdleTimeout {
+				slog.Warn("net client closing idle connection", slog.Duration("idle", idle))
+				client.setLastError(ErrReadIdleTimeout)
+				running = false
+			} else {
+				idleTimer.Reset(readIdleTimeout - idle)
+			}
+		}
+		if client.draining.Load() > 0 {
+			// 队列被取走一条或限速结束, 让 Shutdown 重新检查是否排空.
+			client.drained.notify()
 		}
 		if len(asyncNotifys) > 100 {
 			// 清理已取消的请求，防止内存泄漏.
@@ -409,16 +649,24 @@ func (client *Client[M, T]) asyncGo(ctx context.Context, handleCtx context.Conte
 	// 显式调用是为了清晰 + 释放 context 资源, 幂等).
 	handleCancel()
 	cancel()
+	// worker 池在 asyncGo 返回前排空, Close 的 waiter.Wait 因此也覆盖 worker.
+	if dispatcher != nil {
+		dispatcher.close()
+	}
 
 	// 处理 recvchan 中残留的数据，尝试匹配响应
 	for recv := range recvchan {
-		if notify, ok := any(recv).(NotifyMessage); ok {
-			if notifyId, ok := notify.Id(); ok {
-				if asyncRequest, found := asyncNotifys[notifyId]; found {
-					asyncRequest.Response(recv, nil)
-					delete(asyncNotifys, notifyId)
+		if asyncRequest, found := matchNotify(asyncNotifys, recv); found {
+			if asyncRequest.stream != nil {
+				// handleCtx 已 cancel: deliver 只在 stream 有空位时投递, 不阻塞退出尾段;
+				// 流未结束的留给下方统一以 lastErr 关闭.
+				if !asyncRequest.deliver(handleCtx, recv) {
+					unregisterNotify(asyncNotifys, asyncRequest)
 				}
+				continue
 			}
+			asyncRequest.Response(recv, nil)
+			unregisterNotify(asyncNotifys, asyncRequest)
 		}
 	}
 	lastErr := client.getLastError()
@@ -433,31 +681,44 @@ func (client *Client[M, T]) asyncGo(ctx context.Context, handleCtx context.Conte
 	// 注意: asynchan close 责任在 CloseUnsafe (Wlock 内), 这里只 drain 已入队的;
 	// 若 asynchan 未 close (Reset 路径 / ctx-cancel 路径), 用 select+default 拉空,
 	// caller 后续 send 会走 stopChan 分支拿到 ErrConnectionClosed.
-drainLoop:
-	for {
-		select {
-		case req, ok := <-asynchan:
-			if !ok {
-				break drainLoop
+	// 暂存与等待令牌的请求已从队列取出, 与排空的请求同样处理.
+	failPending := func(req *asynRequest[M, T]) {
+		if req != nil && req.Command == AsyncCommandSend && req.Message != nil {
+			if !req.Message.canceled.Load() {
+				req.Message.teardown.Store(true)
+				req.Message.Response(zeroM, lastErr)
 			}
-			if req.Command == AsyncCommandSend && req.Message != nil {
-				if !req.Message.canceled.Load() {
-					req.Message.Response(zeroM, lastErr)
+		}
+	}
+	failPending(throttled)
+	failPending(lanes.stashed)
+	client.throttling.Store(false)
+	for _, asynchan := range laneChans {
+	drainLoop:
+		for {
+			select {
+			case req, ok := <-asynchan:
+				if !ok {
+					break drainLoop
 				}
+				failPending(req)
+			default:
+				break drainLoop
 			}
-		default:
-			break drainLoop
 		}
 	}
 
 	// 通知所有未匹配的请求：连接已关闭. 跳过已 canceled 的 (caller 已经从 ctx.Done 走了,
-	// 通知反而是 double-touch).
+	// 通知反而是 double-touch). 随即移除请求的全部 id, 批量请求的其余 id 不会再被遍历到.
+	notifyRemaining := 0
 	for _, asyncRequest := range asyncNotifys {
+		notifyRemaining++
 		if !asyncRequest.canceled.Load() {
+			asyncRequest.teardown.Store(true)
 			asyncRequest.Response(zeroM, lastErr)
 		}
+		unregisterNotify(asyncNotifys, asyncRequest)
 	}
-	notifyRemaining := len(asyncNotifys)
 
 	// 通知 Write/Request 连接已关闭. signalStop 用 sync.Once, 与 CloseUnsafe 已经
 	// signalStop 过的场景幂等. OPS-2: 记一行 warn 让运维知道 client 因什么退出.
@@ -467,6 +728,54 @@ drainLoop:
 	client.signalStop()
 }
 
+// notifyIDs 返回 data 参与请求-响应匹配的 id: 实现 NotifyBatchMessage 且 Ids 非空时
+// 为 Ids, 否则为 NotifyMessage.Id. 不可匹配时返回 nil.
+func notifyIDs(data any) []any {
+	if batch, ok := data.(NotifyBatchMessage); ok {
+		if ids := batch.Ids(); len(ids) > 0 {
+			return ids
+		}
+	}
+	if notify, ok := data.(NotifyMessage); ok {
+		if notifyId, ok := notify.Id(); ok {
+			return []any{notifyId}
+		}
+	}
+	return nil
+}
+
+// matchNotify 按 recv 的任一 id 查找等待中的请求.
+func matchNotify[M any](notifys map[any]*asyncMessage[M], recv M) (*asyncMessage[M], bool) {
+	for _, notifyId := range notifyIDs(recv) {
+		if message, ok := notifys[notifyId]; ok {
+			return message, true
+		}
+	}
+	return nil, false
+}
+
+// unregisterNotify 从等待队列移除 message 登记的全部 id. 只删除仍指向 message 的项,
+// 不误删之后以相同 id 登记的其它请求.
+func unregisterNotify[M any](notifys map[any]*asyncMessage[M], message *asyncMessage[M]) {
+	for _, notifyId := range notifyIDs(message.Data) {
+		if notifys[notifyId] == message {
+			delete(notifys, notifyId)
+		}
+	}
+}
+
+// laneFor 按 ctx 携带的优先级选择发送队列. 调用方持有锁或是 single owner.
+func (client *Client[M, T]) laneFor(ctx context.Context) chan *asynRequest[M, T] {
+	switch priorityFromContext(ctx).lane() {
+	case laneHigh:
+		return client.highchan
+	case laneLow:
+		return client.lowchan
+	default:
+		return client.asynchan
+	}
+}
+
 func (client *Client[M, T]) async(ctx context.Context, request *asynRequest[M, T]) error {
 	if client.asynchan == nil {
 		// stopChan 在首次 ResetUnsafe 时创建: 仍为 nil 说明从未连接过;
@@ -477,6 +786,13 @@ func (client *Client[M, T]) async(ctx context.Context, request *asynRequest[M, T
 		}
 		return ErrConnectionClosed
 	}
+	if request.Command == AsyncCommandSend {
+		if client.draining.Load() > 0 {
+			return ErrShuttingDown
+		}
+		// 入队前写入, chan 发送保证 asyncGo 读到.
+		request.Message.enqueued = time.Now()
+	}
 	// RUN-6: caller 传 ctx 无 deadline 时强加 DefaultAsyncTimeout 兜底, 防止
 	// asynchan 满 + asyncGo 卡死 = 永久阻塞. 不影响有 deadline 的 caller.
 	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
@@ -492,7 +808,7 @@ func (client *Client[M, T]) async(ctx context.Context, request *asynRequest[M, T
 			client.asyncTimeouts.Add(1)
 		}
 		return ctx.Err()
-	case client.asynchan <- request:
+	case client.laneFor(ctx) <- request:
 		return nil
 	case <-client.stopChan:
 		return ErrConnectionClosed
@@ -510,7 +826,17 @@ type asyncMessage[M any] struct {
 	Notify   bool                    // 是否需要等待响应
 	callback func(resp M, err error) // 可选的回调函数
 	canceled atomic.Bool             // 是否已取消 (RequestUnsafe ctx.Done 时 store true)
+	teardown atomic.Bool             // 因连接断开 (写失败 / asyncGo 退出尾段) 而失败, 供 PendingWaitReconnect 判断是否重发
 	waiter   chan *messageError[M]
+	inflight *inflightSlot // 占用的在途名额 (Notify 请求), 结束时 release
+	enqueued time.Time     // 进入发送队列的时间, async 写入后只读 (PendingRequests)
+
+	// stream 非 nil 表示 Client.Stream 请求: 同一 Id 的多条响应依次投递, 只由 asyncGo
+	// 关闭 (StreamEnd / 发现 quit / Response 出错). quit 由消费者离开时 close (quitOnce),
+	// 让阻塞在投递上的 asyncGo 解锁.
+	stream   chan *messageError[M]
+	quit     chan struct{}
+	quitOnce sync.Once
 }
 
 // Response 由 asyncGo 单 goroutine 调用. waiter chan 在 asyncMessage(notify=true)
@@ -519,6 +845,17 @@ type asyncMessage[M any] struct {
 // BUG-7: 旧 Canceled() 有 close(waiter) side-effect, 已删除; 现在 Response 是
 // "唯一关闭 waiter 的入口", 不会与其它路径 double close.
 func (request *asyncMessage[M]) Response(resp M, err error) {
+	request.inflight.release()
+	if request.stream != nil {
+		// Stream 只在出错 (写失败 / 连接关闭 / 未实现 Notify) 时经由 Response 结束;
+		// 正常响应走 deliver. 满时丢弃错误值, 消费者读到 close 按 ErrConnectionClosed 处理.
+		select {
+		case request.stream <- &messageError[M]{Response: resp, Error: err}:
+		default:
+		}
+		close(request.stream)
+		return
+	}
 	if request.callback != nil {
 		go request.callback(resp, err)
 	}
@@ -531,12 +868,69 @@ func (request *asyncMessage[M]) Response(resp M, err error) {
 	}
 }
 
+// deliver 把一条匹配到的响应投递给 Stream 消费者, 由 asyncGo 单 goroutine 调用.
+// 返回 false 表示流已结束 (StreamEnd 或消费者已离开), 调用方应从等待队列删除.
+// stream 满时阻塞等消费者 (与 Handle 阻塞同款背压), ctx (handleCtx) 取消时放弃投递
+// 但保留条目, 由退出尾段统一关闭.
+func (request *asyncMessage[M]) deliver(ctx context.Context, resp M) bool {
+	item := &messageError[M]{Response: resp}
+	select {
+	case request.stream <- item:
+	default:
+		select {
+		case request.stream <- item:
+		case <-request.quit:
+			close(request.stream)
+			request.inflight.release()
+			return false
+		case <-ctx.Done():
+			return true
+		}
+	}
+	if end, ok := any(resp).(StreamMessage); ok && end.StreamEnd() {
+		close(request.stream)
+		request.inflight.release()
+		return false
+	}
+	return true
+}
+
+// stop 由 Stream 消费者离开时调用: 标记 canceled (清理 / 退出尾段跳过), 归还在途名额
+// 并 close quit.
+func (request *asyncMessage[M]) stop() {
+	request.quitOnce.Do(func() {
+		request.canceled.Store(true)
+		request.inflight.release()
+		close(request.quit)
+	})
+}
+
 // RequestCallbackUnsafe 发送数据到连接(设置是否需要响应)
 // 注意：此方法不持有锁，调用方需自行确保并发安全。
+// 注册了 UnaryInterceptor 时, 拦截器链在独立 goroutine 中执行, 本方法阻塞到链调用 next
+// 完成入队 (或短路返回); callback 收到链的最终结果.
 func (client *Client[M, T]) RequestCallbackUnsafe(ctx context.Context, data M, callback func(resp M, err error)) error {
-	message := &asyncMessage[M]{Data: data, Notify: true, callback: callback}
-	request := &asynRequest[M, T]{Command: AsyncCommandSend, Message: message}
-	if err := client.async(ctx, request); err != nil {
+	if done := client.observeRequest(); done != nil {
+		userCallback := callback
+		callback = func(resp M, err error) {
+			done(err)
+			if userCallback != nil {
+				userCallback(resp, err)
+			}
+		}
+	}
+	if interceptors := client.unaryInterceptors.Load(); interceptors != nil {
+		return client.requestCallbackIntercepted(ctx, data, callback, *interceptors)
+	}
+	slot, err := client.acquireInFlight(ctx)
+	if err == nil {
+		message := &asyncMessage[M]{Data: data, Notify: true, callback: callback, inflight: slot}
+		request := &asynRequest[M, T]{Command: AsyncCommandSend, Message: message}
+		if err = client.async(ctx, request); err != nil {
+			slot.release()
+		}
+	}
+	if err != nil {
 		if callback != nil {
 			var zeroM M
 			go callback(zeroM, err)
@@ -547,14 +941,16 @@ func (client *Client[M, T]) RequestCallbackUnsafe(ctx context.Context, data M, c
 }
 
 // asyncMessage 发送数据到连接(设置是否需要响应)
+// notify 请求的 slot 由调用方事先 acquireInFlight 取得, 入队失败时在此归还.
 // 注意：此方法不持有锁，调用方需自行确保并发安全。
-func (client *Client[M, T]) asyncMessage(ctx context.Context, data M, notify bool) (*asyncMessage[M], error) {
-	message := &asyncMessage[M]{Data: data, Notify: notify}
+func (client *Client[M, T]) asyncMessage(ctx context.Context, data M, notify bool, slot *inflightSlot) (*asyncMessage[M], error) {
+	message := &asyncMessage[M]{Data: data, Notify: notify, inflight: slot}
 	if notify {
 		message.waiter = make(chan *messageError[M], 1)
 	}
 	request := &asynRequest[M, T]{Command: AsyncCommandSend, Message: message}
 	if err := client.async(ctx, request); err != nil {
+		slot.release()
 		if notify {
 			close(message.waiter)
 		}
@@ -566,30 +962,54 @@ func (client *Client[M, T]) asyncMessage(ctx context.Context, data M, notify boo
 // WriteUnsafe 发送数据到连接(不需要响应)
 // 注意：此方法不持有锁，调用方需自行确保并发安全。
 func (client *Client[M, T]) WriteUnsafe(ctx context.Context, data M) error {
-	_, err := client.asyncMessage(ctx, data, false)
-	return err
+	return client.interceptWrite(ctx, data, func(ctx context.Context, data M) error {
+		_, err := client.asyncMessage(ctx, data, false, nil)
+		return err
+	})
 }
 
 // Write 发送数据到连接(不需要响应)
 // 线程安全，可并发调用。
+// Supervise + PendingWaitReconnect 模式下, 断线期间会等待下一条连接再入队.
 func (client *Client[M, T]) Write(ctx context.Context, data M) error {
-	client.locker.RLock()
-	defer client.locker.RUnlock()
-	return client.WriteUnsafe(ctx, data)
+	return client.interceptWrite(ctx, data, client.write)
+}
+
+// write 是 Write 去掉拦截器后的部分; 重连等待在拦截器内层, 拦截器每次调用只执行一次.
+func (client *Client[M, T]) write(ctx context.Context, data M) error {
+	for {
+		client.locker.RLock()
+		wait := client.pendingPolicy == PendingWaitReconnect
+		resetSignal := client.resetSignal
+		_, err := client.asyncMessage(ctx, data, false, nil)
+		client.locker.RUnlock()
+		if err == nil || !wait || !isDisconnected(err) {
+			return err
+		}
+		if err := waitReconnect(ctx, resetSignal); err != nil {
+			return err
+		}
+	}
 }
 
 // RequestUnsafe 发送数据到连接, 等待响应.
 // *注意* 次方法会阻塞直到收到响应或发生错误, 所以不可以在 Handle 回调中调用此方法.
 // 注意：此方法不持有锁，调用方需自行确保并发安全。
 func (client *Client[M, T]) RequestUnsafe(ctx context.Context, data M) (M, error) {
-	message, err := client.asyncMessage(ctx, data, true)
-	if err != nil {
+	return client.interceptUnary(ctx, data, func(ctx context.Context, data M) (M, error) {
 		var zeroM M
-		return zeroM, err
-	}
-	// 等待逻辑与 Request 完全一致, 复用 waitResponse 避免两份 select 漂移
-	// (canceled 标记 / waiter close 语义二者必须一致, 单点维护更安全).
-	return client.waitResponse(ctx, message)
+		slot, err := client.acquireInFlight(ctx)
+		if err != nil {
+			return zeroM, err
+		}
+		message, err := client.asyncMessage(ctx, data, true, slot)
+		if err != nil {
+			return zeroM, err
+		}
+		// 等待逻辑与 Request 完全一致, 复用 waitResponse 避免两份 select 漂移
+		// (canceled 标记 / waiter close 语义二者必须一致, 单点维护更安全).
+		return client.waitResponse(ctx, message)
+	})
 }
 
 // Request 发送数据到连接, 等待响应.
@@ -600,14 +1020,40 @@ func (client *Client[M, T]) RequestUnsafe(ctx context.Context, data M) (M, error
 // 在 RLock 外. 原因: 等响应可能阻塞数秒~数分钟, 持 RLock 会让并发的 Close (Wlock)
 // 一直等不到 RLock 释放, 造成 Close 永远 hang. 而入队 / waiter 之间用 stopChan
 // 与 message.canceled 的内置同步, 不需要锁保护.
+//
+// Supervise + PendingWaitReconnect 模式下, 入队时无连接或等待响应期间连接断开,
+// 都会等待下一条连接后重新发送, 直到 ctx 结束.
 func (client *Client[M, T]) Request(ctx context.Context, data M) (M, error) {
-	client.locker.RLock()
-	message, err := client.asyncMessage(ctx, data, true)
-	client.locker.RUnlock()
-	if err != nil {
-		return *new(M), err
+	return client.interceptUnary(ctx, data, client.dedupRequest(client.request))
+}
+
+// request 是 Request 去掉拦截器后的部分; 重连重发在拦截器内层, 拦截器每次调用只执行一次.
+func (client *Client[M, T]) request(ctx context.Context, data M) (M, error) {
+	for {
+		// 名额在 RLock 外获取: InFlightBlock 的等待不能拖住并发的 Close.
+		slot, err := client.acquireInFlight(ctx)
+		if err != nil {
+			return *new(M), err
+		}
+		client.locker.RLock()
+		wait := client.pendingPolicy == PendingWaitReconnect
+		resetSignal := client.resetSignal
+		message, err := client.asyncMessage(ctx, data, true, slot)
+		client.locker.RUnlock()
+		if err != nil {
+			if !wait || !isDisconnected(err) {
+				return *new(M), err
+			}
+		} else {
+			resp, err := client.waitResponse(ctx, message)
+			if !wait || err == nil || !message.teardown.Load() {
+				return resp, err
+			}
+		}
+		if err := waitReconnect(ctx, resetSignal); err != nil {
+			return *new(M), err
+		}
 	}
-	return client.waitResponse(ctx, message)
 }
 
 // waitResponse 等 asyncGo 通过 Response 或 stopChan 关闭通知; 不持锁.
@@ -616,6 +1062,7 @@ func (client *Client[M, T]) waitResponse(ctx context.Context, message *asyncMess
 	select {
 	case <-ctx.Done():
 		message.canceled.Store(true)
+		message.inflight.release()
 		return zeroM, ctx.Err()
 	case resp, ok := <-message.waiter:
 		if !ok {
@@ -625,6 +1072,73 @@ func (client *Client[M, T]) waitResponse(ctx context.Context, message *asyncMess
 	}
 }
 
+// Stream 发送数据到连接, 返回同一 Id 的响应流. 迭代器依次产出响应, 直到某条响应
+// 实现 StreamMessage 且 StreamEnd 返回 true (该条仍会产出); 出错时产出 (zero, err)
+// 后结束: ctx 结束返回 ctx.Err(), 连接断开返回 lastError (通常是 ErrConnectionClosed).
+// 迭代器只能消费一次; 提前 break 会通知 asyncGo 释放该请求.
+// *注意* 同 Request, 不可以在 Handle 回调中迭代. caller 必须迭代 (或取消 ctx),
+// 否则请求会在等待队列中保留到连接关闭.
+// 线程安全，可并发调用。
+func (client *Client[M, T]) Stream(ctx context.Context, data M) (iter.Seq2[M, error], error) {
+	slot, err := client.acquireInFlight(ctx)
+	if err != nil {
+		return nil, err
+	}
+	client.locker.RLock()
+	message, err := client.asyncStream(ctx, data, slot)
+	client.locker.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return func(yield func(M, error) bool) {
+		defer message.stop()
+		var zeroM M
+		for {
+			select {
+			case <-ctx.Done():
+				yield(zeroM, ctx.Err())
+				return
+			case resp, ok := <-message.stream:
+				if !ok {
+					yield(zeroM, ErrConnectionClosed)
+					return
+				}
+				if resp.Error != nil {
+					yield(zeroM, resp.Error)
+					return
+				}
+				if !yield(resp.Response, nil) {
+					return
+				}
+				if end, ok := any(resp.Response).(StreamMessage); ok && end.StreamEnd() {
+					return
+				}
+			}
+		}
+	}, nil
+}
+
+// asyncStream 入队一个 Stream 请求. 调用方必须在入队阶段持有 RLock.
+func (client *Client[M, T]) asyncStream(ctx context.Context, data M, slot *inflightSlot) (*asyncMessage[M], error) {
+	bufSize := client.bufferSize
+	if bufSize <= 0 {
+		bufSize = DefaultBufferSize
+	}
+	message := &asyncMessage[M]{
+		Data:     data,
+		Notify:   true,
+		stream:   make(chan *messageError[M], bufSize),
+		quit:     make(chan struct{}),
+		inflight: slot,
+	}
+	request := &asynRequest[M, T]{Command: AsyncCommandSend, Message: message}
+	if err := client.async(ctx, request); err != nil {
+		slot.release()
+		return nil, err
+	}
+	return message, nil
+}
+
 // AsyncCallUnsafe 异步执行回调函数，传入当前连接。
 // 回调在 asyncGo 协程中执行，保证回调内部线程安全。
 // 注意：此方法不持有锁，调用方需自行确保并发安全。
@@ -11,6 +11,11 @@ import (
 type testMessage struct {
 	id    string
 	value string
+	end   bool
+}
+
+func (m testMessage) StreamEnd() bool {
+	return m.end
 }
 
 func (m testMessage) Id() (any, bool) {
@@ -445,3 +450,134 @@ func TestClientAsyncTimeoutsNotIncrementedByCallerCancel(t *testing.T) {
 		t.Fatalf("AsyncTimeouts after caller cancel = %d, want %d (canceled write must not increment)", got, baseline)
 	}
 }
+
+func TestClientStreamYieldsUntilEnd(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	seq, err := client.Stream(ctx, testMessage{id: "stream-1", value: "request"})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	go func() {
+		req := <-conn.writeCh
+		conn.readCh <- testMessage{id: req.id, value: "part-1"}
+		conn.readCh <- testMessage{id: req.id, value: "part-2"}
+		conn.readCh <- testMessage{id: req.id, value: "done", end: true}
+		// 流结束后同 Id 的消息不再匹配, 应当落到 Handle.
+		conn.readCh <- testMessage{id: req.id, value: "late"}
+	}()
+
+	var values []string
+	for resp, err := range seq {
+		if err != nil {
+			t.Fatalf("stream yielded error: %v", err)
+		}
+		values = append(values, resp.value)
+	}
+	if len(values) != 3 || values[0] != "part-1" || values[1] != "part-2" || values[2] != "done" {
+		t.Fatalf("stream values = %v", values)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		conn.mu.Lock()
+		handled := len(conn.handled)
+		conn.mu.Unlock()
+		if handled == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("late message handled = %d, want 1", handled)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestClientStreamEndsWhenConnectionCloses(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	seq, err := client.Stream(ctx, testMessage{id: "stream-2", value: "request"})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	go func() {
+		req := <-conn.writeCh
+		conn.readCh <- testMessage{id: req.id, value: "part-1"}
+	}()
+
+	var values []string
+	var lastErr error
+	for resp, err := range seq {
+		if err != nil {
+			lastErr = err
+			break
+		}
+		values = append(values, resp.value)
+		checkClose(t, "conn", func() error { return conn.Close(context.Background()) })
+	}
+	if len(values) != 1 || values[0] != "part-1" {
+		t.Fatalf("stream values = %v", values)
+	}
+	if !errors.Is(lastErr, ErrConnectionClosed) {
+		t.Fatalf("stream err = %v, want ErrConnectionClosed", lastErr)
+	}
+}
+
+func TestClientStreamBreakReleasesRequest(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	seq, err := client.Stream(ctx, testMessage{id: "stream-3", value: "request"})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	go func() {
+		req := <-conn.writeCh
+		for i := 0; i < DefaultBufferSize*2; i++ {
+			select {
+			case conn.readCh <- testMessage{id: req.id, value: "part"}:
+			case <-conn.closed:
+				return
+			}
+		}
+	}()
+	for _, err := range seq {
+		if err != nil {
+			t.Fatalf("stream yielded error: %v", err)
+		}
+		break
+	}
+	// 消费者离开后 asyncGo 不能阻塞在投递上: 后续 Request 仍然可用.
+	go func() {
+		for req := range conn.writeCh {
+			if req.id == "req-after" {
+				conn.readCh <- testMessage{id: req.id, value: "response"}
+				return
+			}
+		}
+	}()
+	resp, err := client.Request(ctx, testMessage{id: "req-after", value: "request"})
+	if err != nil {
+		t.Fatalf("Request after stream break failed: %v", err)
+	}
+	if resp.value != "response" {
+		t.Fatalf("unexpected response: %#v", resp)
+	}
+}
@@ -36,6 +36,15 @@ type ConnHeart[T any] interface {
 	Heart(connect bool, count uint64) (T, time.Time, bool)
 }
 
+// ConnHeartAck 是可选接口, 与 ConnHeart 配合让 Client 测量往返时延:
+// 收到的消息被 HeartAck 认定为心跳应答时, Client 以最近一次心跳的发送时间计算 RTT
+// (见 ClientStats.RTT), 该消息不再参与请求匹配, 也不交给 Handle. 只跟踪最近一次心跳:
+// 应答晚于下一次心跳到达时按下一次心跳计算.
+type ConnHeartAck[T any] interface {
+	// HeartAck 报告 data 是否是对本端心跳的应答. 在唯一的处理协程中调用.
+	HeartAck(data T) bool
+}
+
 // NotifyMessage 定义了可用于请求-响应匹配的消息接口
 type NotifyMessage interface {
 	// Id 返回消息的唯一标识符。
@@ -43,3 +52,19 @@ type NotifyMessage interface {
 	// 返回值必须是可比较的类型（用于 map key）。
 	Id() (any, bool)
 }
+
+// NotifyBatchMessage 是可选接口, 与 NotifyMessage 配合用于批量消息的匹配:
+// 请求以 Ids 中的每个 id 登记同一个等待者, 收到的消息只要有一个 id 命中即投递给该请求,
+// 投递后该请求的所有 id 一并移除. 收到的消息同样按 Ids 查找. Ids 为空时退回 Id.
+type NotifyBatchMessage interface {
+	// Ids 返回消息携带的全部可匹配 id, 每个都必须是可比较的类型.
+	Ids() []any
+}
+
+// StreamMessage 是可选接口, 与 NotifyMessage 配合用于 Client.Stream:
+// 同一 Id 的响应会持续投递给 Stream 的消费者, 直到某条响应的 StreamEnd 返回 true.
+// 未实现该接口的消息永远不会结束 Stream, 只能由 ctx 取消或连接关闭终止.
+type StreamMessage interface {
+	// StreamEnd 报告本条消息是否是流的最后一条 (本条仍会投递给消费者).
+	StreamEnd() bool
+}
@@ -0,0 +1,142 @@
+package net
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SetDedup 开启 Request 去重 (single-flight): key 相同的并发 Request 共用一次线上往返,
+// 得到同一个响应 (含对端返回的 Id, 即首个请求的 Id); key 返回 "" 的请求不去重.
+// ttl > 0 时成功的响应再缓存 ttl, 期间相同 key 的 Request 直接返回缓存, 错误不缓存.
+// key 为 nil 关闭去重并清空缓存.
+//
+// 只用于幂等请求, key 需忽略请求 Id 等每次不同的字段. 共享的响应不应被调用方修改
+// (M 为指针时尤其注意). 去重位于拦截器与 SetObserver 内侧: 每个调用方仍各自经过拦截器,
+// 按各自 ctx 返回; 往返沿用首个请求 ctx 的值 (优先级等), 所有等待者都离开时才取消.
+// 只作用于 Request; RequestUnsafe / RequestCallback / Stream 不去重. 可随时调用, 立即生效.
+func (client *Client[M, T]) SetDedup(key func(M) string, ttl time.Duration) {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	if key == nil {
+		client.deduper.Store(nil)
+		return
+	}
+	client.deduper.Store(&deduper[M]{
+		key:   key,
+		ttl:   ttl,
+		calls: make(map[string]*dedupCall[M]),
+		cache: make(map[string]dedupEntry[M]),
+	})
+}
+
+// deduper 是一次 SetDedup 的去重状态, SetDedup 整体换新.
+type deduper[M any] struct {
+	key func(M) string
+	ttl time.Duration
+
+	locker    sync.Mutex
+	calls     map[string]*dedupCall[M]
+	cache     map[string]dedupEntry[M]
+	nextSweep time.Time
+}
+
+// dedupCall 是一次共享的往返. resp / err 在 done 关闭前写入, 之后只读.
+type dedupCall[M any] struct {
+	done    chan struct{}
+	resp    M
+	err     error
+	waiters int // 仍在等待的调用方数, 归零时 cancel 往返
+	cancel  context.CancelFunc
+}
+
+type dedupEntry[M any] struct {
+	resp    M
+	expires time.Time
+}
+
+// dedupRequest 返回按 SetDedup 去重后调用 final 的 UnaryInvoker, 未开启时直接返回 final.
+func (client *Client[M, T]) dedupRequest(final UnaryInvoker[M]) UnaryInvoker[M] {
+	if client.deduper.Load() == nil {
+		return final
+	}
+	return func(ctx context.Context, data M) (M, error) {
+		dedup := client.deduper.Load()
+		if dedup == nil {
+			return final(ctx, data)
+		}
+		key := dedup.key(data)
+		if key == "" {
+			return final(ctx, data)
+		}
+		return client.dedupDo(ctx, dedup, key, data, final)
+	}
+}
+
+func (client *Client[M, T]) dedupDo(ctx context.Context, dedup *deduper[M], key string, data M, final UnaryInvoker[M]) (M, error) {
+	dedup.locker.Lock()
+	if entry, ok := dedup.cache[key]; ok {
+		if time.Now().Before(entry.expires) {
+			dedup.locker.Unlock()
+			client.dedupHits.Add(1)
+			return entry.resp, nil
+		}
+		delete(dedup.cache, key)
+	}
+	call, ok := dedup.calls[key]
+	if ok {
+		call.waiters++
+		client.dedupShared.Add(1)
+	} else {
+		// 往返不随首个调用方的 ctx 结束: 其它调用方可能还在等. 所有等待者离开时 cancel.
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &dedupCall[M]{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		dedup.calls[key] = call
+		go func() {
+			resp, err := final(callCtx, data)
+			cancel()
+			dedup.finish(key, call, resp, err)
+		}()
+	}
+	dedup.locker.Unlock()
+
+	select {
+	case <-call.done:
+		return call.resp, call.err
+	case <-ctx.Done():
+		dedup.locker.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			call.cancel()
+			if dedup.calls[key] == call {
+				delete(dedup.calls, key)
+			}
+		}
+		dedup.locker.Unlock()
+		return *new(M), ctx.Err()
+	}
+}
+
+func (dedup *deduper[M]) finish(key string, call *dedupCall[M], resp M, err error) {
+	dedup.locker.Lock()
+	defer dedup.locker.Unlock()
+	call.resp, call.err = resp, err
+	close(call.done)
+	if dedup.calls[key] == call {
+		delete(dedup.calls, key)
+	}
+	if err != nil || dedup.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	// 过期条目在查找时删除; 未再被查找的 key 按 ttl 周期顺带清理, 避免缓存只增不减.
+	if now.After(dedup.nextSweep) {
+		for cached, entry := range dedup.cache {
+			if !now.Before(entry.expires) {
+				delete(dedup.cache, cached)
+			}
+		}
+		dedup.nextSweep = now.Add(dedup.ttl)
+	}
+	dedup.cache[key] = dedupEntry[M]{resp: resp, expires: now.Add(dedup.ttl)}
+}
@@ -0,0 +1,119 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func waitStat(t *testing.T, name string, get func() uint64, want uint64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for get() < want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s = %d, want %d", name, get(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestClientDedupSharesRoundTrip(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.SetDedup(func(m testMessage) string { return m.value }, 0)
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	const callers = 5
+	var wg sync.WaitGroup
+	results := make(chan testMessage, callers)
+	for i := range callers {
+		wg.Go(func() {
+			resp, err := client.Request(ctx, testMessage{id: string(rune('a' + i)), value: "query"})
+			if err != nil {
+				t.Errorf("Request failed: %v", err)
+				return
+			}
+			results <- resp
+		})
+	}
+	req := <-conn.writeCh
+	waitStat(t, "DedupShared", func() uint64 { return client.Stats().DedupShared }, callers-1)
+	conn.readCh <- testMessage{id: req.id, value: "answer"}
+	wg.Wait()
+	close(results)
+	for resp := range results {
+		if resp.id != req.id || resp.value != "answer" {
+			t.Fatalf("unexpected shared response: %#v", resp)
+		}
+	}
+	select {
+	case extra := <-conn.writeCh:
+		t.Fatalf("duplicate request went on the wire: %#v", extra)
+	default:
+	}
+}
+
+func TestClientDedupCacheAndAbandonedLeader(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.SetDedup(func(m testMessage) string { return m.value }, time.Minute)
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// 首个调用方超时离开, 往返继续为仍在等待的调用方服务.
+	leaderCtx, leaderCancel := context.WithCancel(ctx)
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := client.Request(leaderCtx, testMessage{id: "1", value: "query"})
+		leaderErr <- err
+	}()
+	req := <-conn.writeCh
+	follower := make(chan error, 1)
+	go func() {
+		resp, err := client.Request(ctx, testMessage{id: "2", value: "query"})
+		if err == nil && resp.value != "answer" {
+			err = errors.New("unexpected response " + resp.value)
+		}
+		follower <- err
+	}()
+	waitStat(t, "DedupShared", func() uint64 { return client.Stats().DedupShared }, 1)
+	leaderCancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("leader err = %v, want canceled", err)
+	}
+	conn.readCh <- testMessage{id: req.id, value: "answer"}
+	if err := <-follower; err != nil {
+		t.Fatalf("follower failed: %v", err)
+	}
+
+	// 缓存命中: 不再发送.
+	resp, err := client.Request(ctx, testMessage{id: "3", value: "query"})
+	if err != nil || resp.value != "answer" {
+		t.Fatalf("cached Request: resp=%#v err=%v", resp, err)
+	}
+	if hits := client.Stats().DedupHits; hits != 1 {
+		t.Fatalf("DedupHits = %d, want 1", hits)
+	}
+	select {
+	case extra := <-conn.writeCh:
+		t.Fatalf("cached request went on the wire: %#v", extra)
+	default:
+	}
+
+	// key 为空的请求不去重.
+	client.SetDedup(func(m testMessage) string { return "" }, time.Minute)
+	go func() {
+		req := <-conn.writeCh
+		conn.readCh <- testMessage{id: req.id, value: "fresh"}
+	}()
+	if resp, err := client.Request(ctx, testMessage{id: "4", value: "query"}); err != nil || resp.value != "fresh" {
+		t.Fatalf("undeduplicated Request: resp=%#v err=%v", resp, err)
+	}
+}
@@ -0,0 +1,90 @@
+package net
+
+import (
+	"context"
+	"hash/maphash"
+	"log/slog"
+	"sync"
+)
+
+// SetHandleDispatch 开启并发 Handle 分发: 未匹配到请求的消息不再在 asyncGo 内同步调用
+// conn.Handle, 而是交给 workers 个 worker 协程执行, 慢 Handle 不再卡住请求-响应匹配与心跳.
+//
+// key 相同的消息进入同一个 worker 队列, 保证同 key 按接收顺序 Handle; key 为 nil 时
+// 轮流分配, 不保证任何顺序. queueSize 是每个 worker 的队列容量 (<=0 使用 bufferSize),
+// 队列满时 asyncGo 阻塞等待 (背压同同步 Handle), Close 会解除阻塞.
+// workers <= 0 关闭分发, 恢复同步 Handle.
+//
+// *注意* 开启后 Handle 会被多个协程并发调用, 实现必须并发安全.
+// 必须在 Reset 前调用; 已连接后修改不会影响当前连接.
+func (client *Client[M, T]) SetHandleDispatch(workers, queueSize int, key func(M) string) {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	client.dispatchWorkers = workers
+	client.dispatchQueueSize = queueSize
+	client.dispatchKey = key
+}
+
+// handleDispatcher 是一次连接 (ResetUnsafe) 的 Handle worker 池. dispatch 只由 asyncGo
+// 调用, close 在 asyncGo 退出尾段调用, 等所有 worker 排空队列后返回.
+type handleDispatcher[M any] struct {
+	queues []chan M
+	key    func(M) string
+	seed   maphash.Seed
+	next   int // key == nil 时轮流分配的游标, 仅 asyncGo 读写
+	waiter sync.WaitGroup
+}
+
+func newHandleDispatcher[M any](ctx context.Context, handle func(ctx context.Context, msg M), workers, queueSize int, key func(M) string) *handleDispatcher[M] {
+	dispatcher := &handleDispatcher[M]{
+		queues: make([]chan M, workers),
+		key:    key,
+		seed:   maphash.MakeSeed(),
+	}
+	for i := range dispatcher.queues {
+		queue := make(chan M, queueSize)
+		dispatcher.queues[i] = queue
+		dispatcher.waiter.Go(func() {
+			for msg := range queue {
+				handle(ctx, msg)
+			}
+		})
+	}
+	return dispatcher
+}
+
+// dispatch 把消息投递到对应 worker 队列. 队列满时阻塞, ctx (handleCtx) 取消时放弃并返回 false.
+func (dispatcher *handleDispatcher[M]) dispatch(ctx context.Context, msg M) bool {
+	var index int
+	if dispatcher.key != nil {
+		index = int(maphash.String(dispatcher.seed, dispatcher.key(msg)) % uint64(len(dispatcher.queues)))
+	} else {
+		index = dispatcher.next
+		dispatcher.next = (dispatcher.next + 1) % len(dispatcher.queues)
+	}
+	select {
+	case dispatcher.queues[index] <- msg:
+		return true
+	case <-ctx.Done():
+		slog.Debug("net.Client handle dispatch dropped message on close", slog.Int("worker", index))
+		return false
+	}
+}
+
+// close 关闭所有队列并等待 worker 退出. worker 会先 Handle 完已入队的消息
+// (此时 ctx 已取消, 遵守 ctx 的 Handle 会很快返回).
+func (dispatcher *handleDispatcher[M]) close() {
+	for _, queue := range dispatcher.queues {
+		close(queue)
+	}
+	dispatcher.waiter.Wait()
+}
+
+// queueLen 返回所有 worker 队列的总长度与总容量.
+func (dispatcher *handleDispatcher[M]) queueLen() (length, capacity int) {
+	for _, queue := range dispatcher.queues {
+		length += len(queue)
+		capacity += cap(queue)
+	}
+	return length, capacity
+}
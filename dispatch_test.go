@@ -0,0 +1,116 @@
+package net
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dispatchConn 是 Handle 可定制的 fakeConn.
+type dispatchConn struct {
+	*fakeConn
+	handle func(ctx context.Context, msg testMessage)
+}
+
+func (c *dispatchConn) Handle(ctx context.Context, msg testMessage) {
+	c.handle(ctx, msg)
+}
+
+func TestClientHandleDispatchSlowKeyDoesNotBlockRequests(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var fast []string
+	fastDone := make(chan struct{})
+	conn := &dispatchConn{fakeConn: newFakeConn()}
+	conn.handle = func(ctx context.Context, msg testMessage) {
+		if msg.value == "slow" {
+			select {
+			case <-release:
+			case <-ctx.Done():
+			}
+			return
+		}
+		mu.Lock()
+		fast = append(fast, msg.value)
+		if len(fast) == 3 {
+			close(fastDone)
+		}
+		mu.Unlock()
+	}
+
+	client := NewClient[testMessage, *dispatchConn]()
+	client.SetHandleDispatch(64, 4, func(msg testMessage) string {
+		if msg.value == "slow" {
+			return "slow"
+		}
+		return "fast"
+	})
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	conn.readCh <- testMessage{value: "slow"}
+	for _, value := range []string{"fast-1", "fast-2", "fast-3"} {
+		conn.readCh <- testMessage{value: value}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go func() {
+		req := <-conn.writeCh
+		conn.readCh <- testMessage{id: req.id, value: "response"}
+	}()
+	if _, err := client.Request(ctx, testMessage{id: "req-1", value: "request"}); err != nil {
+		t.Fatalf("Request blocked behind slow Handle: %v", err)
+	}
+	// 两个 key 可能哈希到同一 worker, 放行慢消息后再校验同 key 顺序.
+	close(release)
+
+	select {
+	case <-fastDone:
+	case <-ctx.Done():
+		t.Fatal("fast key messages were not handled")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for i, want := range []string{"fast-1", "fast-2", "fast-3"} {
+		if fast[i] != want {
+			t.Fatalf("fast order = %v", fast)
+		}
+	}
+	if stats := client.Stats(); stats.DispatchCap != 64*4 {
+		t.Fatalf("DispatchCap = %d, want %d", stats.DispatchCap, 64*4)
+	}
+}
+
+func TestClientHandleDispatchCloseUnblocksWorkers(t *testing.T) {
+	conn := &dispatchConn{fakeConn: newFakeConn()}
+	started := make(chan struct{}, 1)
+	conn.handle = func(ctx context.Context, msg testMessage) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+	}
+	client := NewClient[testMessage, *dispatchConn]()
+	client.SetHandleDispatch(1, 1, nil)
+	client.Reset(context.Background(), conn)
+
+	// 第一条卡在 worker, 第二条占满队列, 第三条让 asyncGo 阻塞在 dispatch 上.
+	for _, value := range []string{"a", "b", "c"} {
+		conn.readCh <- testMessage{value: value}
+	}
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		checkClose(t, "client", client.Close)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked behind dispatcher")
+	}
+}
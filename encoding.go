@@ -0,0 +1,175 @@
+package net
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncoding 是 HTTP.RequestMethod 在 caller 未设置 Accept-Encoding 时自动带上的值,
+// 与 newContentDecoder 能解码的编码一致.
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// ErrUnsupportedContentEncoding 表示响应的 Content-Encoding 含 Response 无法解码的编码.
+var ErrUnsupportedContentEncoding = errors.New("unsupported content encoding")
+
+// newContentDecoder 按 Content-Encoding 逐层解码 body. 多个编码 ("gzip, br") 按应用
+// 顺序列出, 解码时逆序进行. 返回的 closers 按创建顺序排列, 出错时已创建的解码器已释放.
+func newContentDecoder(body io.Reader, contentEncoding string) (io.Reader, []func() error, error) {
+	codings := contentCodings(contentEncoding)
+	reader := body
+	var closers []func() error
+	for _, coding := range slices.Backward(codings) {
+		next, closer, err := newCodingReader(reader, coding)
+		if err != nil {
+			closeDecoders(closers)
+			return nil, nil, err
+		}
+		reader = next
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+	}
+	return reader, closers, nil
+}
+
+// contentCodings 按应用顺序列出 Content-Encoding 中的编码 (小写, 去掉 identity).
+func contentCodings(contentEncoding string) []string {
+	var codings []string
+	for coding := range strings.SplitSeq(contentEncoding, ",") {
+		if coding = strings.ToLower(strings.TrimSpace(coding)); coding != "" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+	return codings
+}
+
+func supportedCoding(coding string) bool {
+	switch coding {
+	case "gzip", "x-gzip", "br", "deflate", "zstd":
+		return true
+	}
+	return false
+}
+
+func newCodingReader(reader io.Reader, coding string) (io.Reader, func() error, error) {
+	switch coding {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gz, gz.Close, nil
+	case "br":
+		return brotli.NewReader(reader), nil, nil
+	case "deflate":
+		// RFC 9110 的 deflate 是 zlib 封装, 但不少服务端直接发 raw deflate, 按 zlib 头区分.
+		buffered := bufio.NewReader(reader)
+		if header, err := buffered.Peek(2); err == nil && isZlibHeader(header) {
+			zr, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, nil, err
+			}
+			return zr, zr.Close, nil
+		}
+		fr := flate.NewReader(buffered)
+		return fr, fr.Close, nil
+	case "zstd":
+		zr, err := zstd.NewReader(reader, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, func() error {
+			zr.Close()
+			return nil
+		}, nil
+	}
+	return nil, nil, fmt.Errorf("%w: %q", ErrUnsupportedContentEncoding, coding)
+}
+
+// setAcceptEncoding 在 caller 未指定时补上 acceptEncoding, 返回是否补了.
+// 显式设置 Accept-Encoding 后 transport 不再自动加 gzip 并透明解压, 补上时由
+// decodeResponseBody 接替这一步; caller 自己设置时响应原样返回, 由 Response.Read 解码.
+func setAcceptEncoding(request *http.Request) bool {
+	if request.Header.Get("Accept-Encoding") != "" {
+		return false
+	}
+	request.Header.Set("Accept-Encoding", acceptEncoding)
+	return true
+}
+
+// decodeResponseBody 就地解码 res.Body, 做法与 transport 透明解压 gzip 一致: 去掉
+// Content-Encoding / Content-Length 并置 Uncompressed. 这样 OnResponse 与直接读 res.Body
+// 的调用方拿到的仍是解码后的内容, 与未自动补 Accept-Encoding 时相同.
+// 含无法解码的编码时原样保留, 由 Response.Read 返回 ErrUnsupportedContentEncoding.
+func decodeResponseBody(res *http.Response) {
+	contentEncoding := res.Header.Get("Content-Encoding")
+	codings := contentCodings(contentEncoding)
+	if len(codings) == 0 {
+		return
+	}
+	for _, coding := range codings {
+		if !supportedCoding(coding) {
+			return
+		}
+	}
+	res.Body = &decodedBody{body: res.Body, contentEncoding: contentEncoding}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+}
+
+// decodedBody 在首次 Read 时创建解码器, Close 时释放解码器与底层 body.
+type decodedBody struct {
+	body            io.ReadCloser
+	contentEncoding string
+	reader          io.Reader
+	closers         []func() error
+	err             error
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.reader == nil {
+		if b.err != nil {
+			return 0, b.err
+		}
+		reader, closers, err := newContentDecoder(b.body, b.contentEncoding)
+		if err != nil {
+			b.err = err
+			return 0, err
+		}
+		b.reader, b.closers = reader, closers
+	}
+	return b.reader.Read(p)
+}
+
+func (b *decodedBody) Close() error {
+	err := closeDecoders(b.closers)
+	b.closers = nil
+	return errors.Join(err, b.body.Close())
+}
+
+// isZlibHeader 按 RFC 1950 判断 CMF/FLG: CM=8 (deflate) 且 CMF*256+FLG 是 31 的倍数.
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
+// closeDecoders 逆序释放解码器 (外层先于内层).
+func closeDecoders(closers []func() error) error {
+	var err error
+	for _, closer := range slices.Backward(closers) {
+		err = errors.Join(err, closer())
+	}
+	return err
+}
@@ -0,0 +1,152 @@
+package net
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+func encodeBody(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = fw
+	case "zstd":
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w = zw
+	default:
+		t.Fatalf("unknown coding %q", coding)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestResponseDecodesContentEncodings(t *testing.T) {
+	plain := bytes.Repeat([]byte("hello encoding "), 64)
+	cases := []struct {
+		header string
+		chain  []string // 按应用顺序编码
+	}{
+		{"gzip", []string{"gzip"}},
+		{"br", []string{"br"}},
+		{"deflate", []string{"deflate"}},
+		{"deflate", []string{"raw-deflate"}},
+		{"zstd", []string{"zstd"}},
+		{"gzip, br", []string{"gzip", "br"}},
+		{"zstd, identity, gzip", []string{"zstd", "gzip"}},
+	}
+	for _, tc := range cases {
+		body := plain
+		for _, coding := range tc.chain {
+			body = encodeBody(t, coding, body)
+		}
+		var gotAccept string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotAccept = r.Header.Get("Accept-Encoding")
+			w.Header().Set("Content-Encoding", tc.header)
+			_, _ = w.Write(body)
+		}))
+		h := NewHTTP(nil)
+		res, err := h.Request(context.Background(), server.URL, nil, nil)
+		if err != nil {
+			t.Fatalf("%v: request failed: %v", tc.chain, err)
+		}
+		data, err := res.Data()
+		if err != nil {
+			t.Fatalf("%v: Data failed: %v", tc.chain, err)
+		}
+		if !bytes.Equal(data, plain) {
+			t.Fatalf("%v: decoded body mismatch", tc.chain)
+		}
+		if gotAccept != acceptEncoding {
+			t.Fatalf("Accept-Encoding = %q, want %q", gotAccept, acceptEncoding)
+		}
+		if res.Header.Get("Content-Encoding") != "" || !res.Uncompressed {
+			t.Fatalf("%v: body not decoded before return: Content-Encoding = %q", tc.chain, res.Header.Get("Content-Encoding"))
+		}
+		h.Dispose()
+		server.Close()
+	}
+}
+
+func TestResponseUnsupportedContentEncoding(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("caller Accept-Encoding overwritten: %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "compress")
+		_, _ = w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	res, err := h.Request(context.Background(), server.URL, http.Header{"Accept-Encoding": {"gzip"}}, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if _, err := res.Data(); !errors.Is(err, ErrUnsupportedContentEncoding) {
+		t.Fatalf("Data err = %v, want ErrUnsupportedContentEncoding", err)
+	}
+}
+
+func TestOnResponseSeesDecodedBody(t *testing.T) {
+	plain := []byte("decoded for callbacks")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "zstd")
+		_, _ = w.Write(encodeBody(t, "zstd", plain))
+	}))
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	var seen []byte
+	h.ConfigureOnResponse(func(ctx context.Context, req *http.Request, res *http.Response, err error) (*http.Response, error, bool) {
+		if err == nil {
+			seen, err = io.ReadAll(res.Body)
+			res.Body = io.NopCloser(bytes.NewReader(seen))
+		}
+		return res, err, false
+	})
+	res, err := h.Request(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	data, err := res.Data()
+	if err != nil {
+		t.Fatalf("Data failed: %v", err)
+	}
+	if !bytes.Equal(seen, plain) || !bytes.Equal(data, plain) {
+		t.Fatalf("OnResponse saw %q, Data = %q; want %q", seen, data, plain)
+	}
+}
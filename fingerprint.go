@@ -0,0 +1,367 @@
+package net
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/net/http2"
+)
+
+// ClientProfile 描述一个浏览器式客户端指纹:
+//   - TLS ClientHello (密码套件顺序、扩展及其顺序、椭圆曲线等) 取自 utls 的 HelloID 预设, ALPN 可单独覆盖;
+//   - HTTP/2 连接开头的 SETTINGS (按 Settings 的顺序发送) 与 WINDOW_UPDATE, 以及 HEADERS 帧的优先级;
+//   - 伪头 (:method / :authority / :scheme / :path) 的顺序与普通请求头的默认顺序.
+//
+// 请求头按 WithHeaderOrder 给出的顺序发送, 未列出的头按 HeaderOrder, 其余按名称排序.
+type ClientProfile struct {
+	Name    string
+	HelloID utls.ClientHelloID
+	// ALPN 覆盖 ClientHello 里的 ALPN 扩展; nil 时用 TLSClientConfig.NextProtos, 也为空则沿用
+	// HelloID 预设. 不含 "h2" 时只走 HTTP/1.1.
+	ALPN []string
+
+	// Settings 是 HTTP/2 连接开头 SETTINGS 帧的内容, 按顺序发送. 其中 HEADER_TABLE_SIZE /
+	// INITIAL_WINDOW_SIZE / MAX_FRAME_SIZE / MAX_HEADER_LIST_SIZE 同时决定本端的接收参数.
+	Settings []http2.Setting
+	// ConnectionFlow 是 SETTINGS 之后连接级 WINDOW_UPDATE 的增量, 0 不发送.
+	ConnectionFlow uint32
+	// HeaderPriority 非 nil 时每个请求的 HEADERS 帧带上该优先级 (RFC 7540 5.3).
+	HeaderPriority *http2.PriorityParam
+	// PseudoHeaderOrder 是 HTTP/2 伪头顺序, 取 ":method" / ":authority" / ":scheme" / ":path",
+	// 缺少的项按这一默认顺序补在末尾.
+	PseudoHeaderOrder []string
+	// HeaderOrder 是未经 WithHeaderOrder 指定时普通请求头的顺序 (不区分大小写).
+	HeaderOrder []string
+}
+
+// 内置指纹, 取值参照对应浏览器当前版本的 ClientHello、HTTP/2 SETTINGS 与请求头顺序.
+var (
+	ProfileChrome = &ClientProfile{
+		Name:    "chrome",
+		HelloID: utls.HelloChrome_Auto,
+		Settings: []http2.Setting{
+			{ID: http2.SettingHeaderTableSize, Val: 65536},
+			{ID: http2.SettingEnablePush, Val: 0},
+			{ID: http2.SettingInitialWindowSize, Val: 6291456},
+			{ID: http2.SettingMaxHeaderListSize, Val: 262144},
+		},
+		ConnectionFlow:    15663105,
+		HeaderPriority:    &http2.PriorityParam{Exclusive: true, Weight: 255},
+		PseudoHeaderOrder: []string{":method", ":authority", ":scheme", ":path"},
+		HeaderOrder: []string{
+			"cache-control", "sec-ch-ua", "sec-ch-ua-mobile", "sec-ch-ua-platform",
+			"upgrade-insecure-requests", "user-agent", "content-type", "accept", "origin",
+			"sec-fetch-site", "sec-fetch-mode", "sec-fetch-user", "sec-fetch-dest", "referer",
+			"accept-encoding", "accept-language", "cookie", "priority",
+		},
+	}
+	ProfileFirefox = &ClientProfile{
+		Name:    "firefox",
+		HelloID: utls.HelloFirefox_Auto,
+		Settings: []http2.Setting{
+			{ID: http2.SettingHeaderTableSize, Val: 65536},
+			{ID: http2.SettingEnablePush, Val: 0},
+			{ID: http2.SettingInitialWindowSize, Val: 131072},
+			{ID: http2.SettingMaxFrameSize, Val: 16384},
+		},
+		ConnectionFlow:    12517377,
+		HeaderPriority:    &http2.PriorityParam{Weight: 41},
+		PseudoHeaderOrder: []string{":method", ":path", ":authority", ":scheme"},
+		HeaderOrder: []string{
+			"user-agent", "accept", "accept-language", "accept-encoding", "content-type", "origin",
+			"referer", "cookie", "upgrade-insecure-requests", "sec-fetch-dest", "sec-fetch-mode",
+			"sec-fetch-site", "sec-fetch-user", "priority", "te",
+		},
+	}
+	ProfileSafari = &ClientProfile{
+		Name:    "safari",
+		HelloID: utls.HelloSafari_Auto,
+		Settings: []http2.Setting{
+			{ID: http2.SettingEnablePush, Val: 0},
+			{ID: http2.SettingMaxConcurrentStreams, Val: 100},
+			{ID: http2.SettingInitialWindowSize, Val: 2097152},
+			{ID: settingNoRFC7540Priorities, Val: 1},
+		},
+		ConnectionFlow:    10420225,
+		PseudoHeaderOrder: []string{":method", ":scheme", ":path", ":authority"},
+		HeaderOrder: []string{
+			"content-type", "accept", "sec-fetch-site", "origin", "cookie", "sec-fetch-dest",
+			"accept-language", "sec-fetch-mode", "user-agent", "referer", "accept-encoding", "priority",
+		},
+	}
+	ProfileIOS = &ClientProfile{
+		Name:              "ios",
+		HelloID:           utls.HelloIOS_Auto,
+		Settings:          ProfileSafari.Settings,
+		ConnectionFlow:    ProfileSafari.ConnectionFlow,
+		PseudoHeaderOrder: ProfileSafari.PseudoHeaderOrder,
+		HeaderOrder:       ProfileSafari.HeaderOrder,
+	}
+)
+
+// settingNoRFC7540Priorities 是 RFC 9218 的 SETTINGS_NO_RFC7540_PRIORITIES.
+const settingNoRFC7540Priorities http2.SettingID = 0x9
+
+type headerOrderContextKey struct{}
+
+// WithHeaderOrder 返回携带请求头发送顺序的 ctx (名称不区分大小写). 经 ConfigureClientProfile
+// 的指纹 transport 发出的请求按该顺序写请求头, 未列出的头排在后面; 没有指纹时不起作用.
+// http.Header 是 map, 本身不保留顺序, 需要固定顺序时用它指定.
+func WithHeaderOrder(ctx context.Context, names ...string) context.Context {
+	return context.WithValue(ctx, headerOrderContextKey{}, names)
+}
+
+func headerOrderFromContext(ctx context.Context) []string {
+	names, _ := ctx.Value(headerOrderContextKey{}).([]string)
+	return names
+}
+
+// spec 返回按 ALPN 与 TLS 版本范围调整后的 ClientHello 规格. config 为 nil 时只应用 profile.ALPN.
+func (profile *ClientProfile) spec(config *tls.Config) (*utls.ClientHelloSpec, error) {
+	spec, err := utls.UTLSIdToSpec(profile.HelloID)
+	if err != nil {
+		return nil, err
+	}
+	alpn := profile.ALPN
+	if alpn == nil && config != nil {
+		alpn = config.NextProtos
+	}
+	if alpn != nil {
+		for _, extension := range spec.Extensions {
+			if extension, ok := extension.(*utls.ALPNExtension); ok {
+				extension.AlpnProtocols = slices.Clone(alpn)
+			}
+		}
+	}
+	if config != nil && (config.MinVersion != 0 || config.MaxVersion != 0) {
+		if err := narrowVersions(&spec, config.MinVersion, config.MaxVersion); err != nil {
+			return nil, fmt.Errorf("client profile %s: %w", profile.Name, err)
+		}
+	}
+	return &spec, nil
+}
+
+// narrowVersions 按 [low, high] (0 表示不限) 收窄 ClientHello 的 TLS 版本: supported_versions
+// 扩展去掉范围外的版本 (保留 GREASE), 并显式设置 TLSVersMin / TLSVersMax.
+func narrowVersions(spec *utls.ClientHelloSpec, low, high uint16) error {
+	var versMin, versMax uint16
+	found := false
+	for _, extension := range spec.Extensions {
+		extension, ok := extension.(*utls.SupportedVersionsExtension)
+		if !ok {
+			continue
+		}
+		found = true
+		versions := make([]uint16, 0, len(extension.Versions))
+		for _, version := range extension.Versions {
+			if isGREASEVersion(version) {
+				versions = append(versions, version)
+			} else if versionInRange(version, low, high) {
+				versions = append(versions, version)
+				if versMin == 0 || version < versMin {
+					versMin = version
+				}
+				versMax = max(versMax, version)
+			}
+		}
+		extension.Versions = versions
+	}
+	if !found {
+		// 没有 supported_versions 的 (TLS 1.2 及以下) 预设, 直接与预设范围求交.
+		versMin, versMax = cmp.Or(spec.TLSVersMin, utls.VersionTLS10), cmp.Or(spec.TLSVersMax, utls.VersionTLS12)
+		versMin = max(versMin, low)
+		if high != 0 {
+			versMax = min(versMax, high)
+		}
+	}
+	if versMin == 0 || versMin > versMax {
+		return fmt.Errorf("no TLS version within [%#x, %#x]", low, high)
+	}
+	spec.TLSVersMin, spec.TLSVersMax = versMin, versMax
+	return nil
+}
+
+func isGREASEVersion(version uint16) bool {
+	return version&0x0f0f == 0x0a0a && version>>8 == version&0xff
+}
+
+func versionInRange(version, low, high uint16) bool {
+	return (low == 0 || version >= low) && (high == 0 || version <= high)
+}
+
+// pseudoHeaderOrder 返回补全后的伪头顺序.
+func (profile *ClientProfile) pseudoHeaderOrder() []string {
+	order := make([]string, 0, 4)
+	for _, name := range profile.PseudoHeaderOrder {
+		name = strings.ToLower(name)
+		switch name {
+		case ":method", ":authority", ":scheme", ":path":
+			if !slices.Contains(order, name) {
+				order = append(order, name)
+			}
+		}
+	}
+	for _, name := range []string{":method", ":authority", ":scheme", ":path"} {
+		if !slices.Contains(order, name) {
+			order = append(order, name)
+		}
+	}
+	return order
+}
+
+// ConfigureClientProfile 用 profile 的指纹发起请求, nil 恢复 Go 默认 transport.
+// 指纹 transport 自带 HTTP/1.1 与 HTTP/2 实现 (以便控制 SETTINGS、伪头与请求头顺序), 超时、
+// 连接数与 TLSClientConfig (证书校验、客户端证书、版本范围、NextProtos) 取自底层 transport.
+// 代理同样沿用: ConfigureProxyDial 的拨号直接用于建连; ConfigureProxy / ConfigureDebug 的
+// URL 代理对 https 请求先建隧道 (http(s) 代理走 CONNECT) 再做指纹握手, http 请求按绝对 URI
+// 交给 http(s) 代理转发. HTTPOptions.Hosts 中有独立 transport 的 host 各自基于其 transport
+// 应用同一 profile. QUIC (NewHTTP3) 不支持.
+//
+// 指纹 transport 本身不做透明 gzip 解压 (由 HTTP 按自动补上的 Accept-Encoding 解码), 不自动补
+// User-Agent, 不发送请求 trailer, 也不支持 Expect: 100-continue、协议升级与 HTTP/2 server push.
+func (h *HTTP) ConfigureClientProfile(profile *ClientProfile) error {
+	base, ok := h.transport.(*http.Transport)
+	if !ok {
+		return errors.New("quic protocol can not set client profile")
+	}
+	if profile != nil {
+		var err error
+		h.eachTransport(func(transport http.RoundTripper, _ dialFunc) {
+			if transport, ok := transport.(*http.Transport); ok && err == nil {
+				_, err = profile.spec(transport.TLSClientConfig)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	h.closeFingerprints()
+	if profile != nil {
+		h.fingerprint = newFingerprintTransport(base, profile)
+		for _, route := range h.routes {
+			if transport, ok := route.transport.(*http.Transport); ok {
+				route.fingerprint = newFingerprintTransport(transport, profile)
+			}
+		}
+	}
+	h.applyTransport()
+	return nil
+}
+
+// closeFingerprints 关闭并清除 ConfigureClientProfile 建立的指纹 transport.
+func (h *HTTP) closeFingerprints() {
+	if h.fingerprint != nil {
+		h.fingerprint.CloseIdleConnections()
+		h.fingerprint = nil
+	}
+	for _, route := range h.routes {
+		if route.fingerprint != nil {
+			route.fingerprint.CloseIdleConnections()
+			route.fingerprint = nil
+		}
+	}
+}
+
+// handshakeFingerprint 在已建立的 conn 上用 profile 的 ClientHello 完成 TLS 握手, 失败时关闭 conn.
+func handshakeFingerprint(ctx context.Context, conn net.Conn, config *tls.Config, profile *ClientProfile, addr string) (*utls.UConn, error) {
+	spec, err := profile.spec(config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	uconfig := utlsConfig(config)
+	if uconfig.ServerName == "" {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			uconfig.ServerName = host
+		}
+	}
+	uconn := utls.UClient(conn, uconfig, utls.HelloCustom)
+	if err := uconn.ApplyPreset(spec); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := uconn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return uconn, nil
+}
+
+// utlsConfig 把 tls.Config 中客户端用得到的字段复制到 utls.Config. 版本范围与 NextProtos
+// 已在 spec 中体现到 ClientHello 里, 这里照样复制以便 utls 校验服务端的选择.
+func utlsConfig(config *tls.Config) *utls.Config {
+	uconfig := &utls.Config{}
+	if config == nil {
+		return uconfig
+	}
+	uconfig.ServerName = config.ServerName
+	uconfig.InsecureSkipVerify = config.InsecureSkipVerify
+	uconfig.RootCAs = config.RootCAs
+	uconfig.VerifyPeerCertificate = config.VerifyPeerCertificate
+	uconfig.KeyLogWriter = config.KeyLogWriter
+	uconfig.MinVersion = config.MinVersion
+	uconfig.MaxVersion = config.MaxVersion
+	uconfig.NextProtos = slices.Clone(config.NextProtos)
+	for _, certificate := range config.Certificates {
+		uconfig.Certificates = append(uconfig.Certificates, utlsCertificate(certificate))
+	}
+	if config.GetClientCertificate != nil {
+		getClientCertificate := config.GetClientCertificate
+		uconfig.GetClientCertificate = func(info *utls.CertificateRequestInfo) (*utls.Certificate, error) {
+			schemes := make([]tls.SignatureScheme, len(info.SignatureSchemes))
+			for i, scheme := range info.SignatureSchemes {
+				schemes[i] = tls.SignatureScheme(scheme)
+			}
+			certificate, err := getClientCertificate(&tls.CertificateRequestInfo{
+				AcceptableCAs:    info.AcceptableCAs,
+				SignatureSchemes: schemes,
+				Version:          info.Version,
+			})
+			if err != nil || certificate == nil {
+				return nil, err
+			}
+			converted := utlsCertificate(*certificate)
+			return &converted, nil
+		}
+	}
+	return uconfig
+}
+
+func utlsCertificate(certificate tls.Certificate) utls.Certificate {
+	converted := utls.Certificate{
+		Certificate:                 certificate.Certificate,
+		PrivateKey:                  certificate.PrivateKey,
+		OCSPStaple:                  certificate.OCSPStaple,
+		SignedCertificateTimestamps: certificate.SignedCertificateTimestamps,
+		Leaf:                        certificate.Leaf,
+	}
+	for _, scheme := range certificate.SupportedSignatureAlgorithms {
+		converted.SupportedSignatureAlgorithms = append(converted.SupportedSignatureAlgorithms, utls.SignatureScheme(scheme))
+	}
+	return converted
+}
+
+// connectionState 把 utls 的连接状态转成 tls.ConnectionState, 填入 http.Response.TLS.
+func connectionState(conn *utls.UConn) *tls.ConnectionState {
+	state := conn.ConnectionState()
+	return &tls.ConnectionState{
+		Version:                     state.Version,
+		HandshakeComplete:           state.HandshakeComplete,
+		DidResume:                   state.DidResume,
+		CipherSuite:                 state.CipherSuite,
+		NegotiatedProtocol:          state.NegotiatedProtocol,
+		ServerName:                  state.ServerName,
+		PeerCertificates:            state.PeerCertificates,
+		VerifiedChains:              state.VerifiedChains,
+		SignedCertificateTimestamps: state.SignedCertificateTimestamps,
+		OCSPResponse:                state.OCSPResponse,
+	}
+}
@@ -0,0 +1,453 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	gonet "net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// isGREASE 报告 v 是否为 RFC 8701 的 GREASE 值 (0x?a?a), Go 默认握手从不发送.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func newFingerprintServer(t *testing.T) (*httptest.Server, func() (*tls.ClientHelloInfo, int)) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		hello *tls.ClientHelloInfo
+		proto int
+	)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proto = r.ProtoMajor
+		mu.Unlock()
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	config := server.TLS
+	config.GetConfigForClient = func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+		mu.Lock()
+		hello = info
+		mu.Unlock()
+		return nil, nil
+	}
+	return server, func() (*tls.ClientHelloInfo, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return hello, proto
+	}
+}
+
+func TestConfigureClientProfileChromeUsesHTTP2AndGREASE(t *testing.T) {
+	server, observed := newFingerprintServer(t)
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	var dials int
+	countingDial := func(ctx context.Context, network, addr string) (gonet.Conn, error) {
+		dials++
+		return (&gonet.Dialer{Timeout: 5 * time.Second}).DialContext(ctx, network, addr)
+	}
+	if err := h.ConfigureProxyDial(countingDial, false); err != nil {
+		t.Fatalf("ConfigureProxyDial failed: %v", err)
+	}
+	if err := h.ConfigureClientProfile(ProfileChrome); err != nil {
+		t.Fatalf("ConfigureClientProfile failed: %v", err)
+	}
+	res, err := h.Request(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if _, err := res.Data(); err != nil {
+		t.Fatalf("read body failed: %v", err)
+	}
+	hello, proto := observed()
+	if proto != 2 {
+		t.Fatalf("server saw HTTP/%d, want HTTP/2", proto)
+	}
+	if hello == nil || len(hello.CipherSuites) == 0 || !isGREASE(hello.CipherSuites[0]) {
+		t.Fatalf("ClientHello does not look like Chrome: %+v", hello)
+	}
+	if dials != 1 {
+		t.Fatalf("proxy dials = %d, want 1 (fingerprint must use the configured dial)", dials)
+	}
+}
+
+func TestConfigureClientProfileALPNOverrideAndReset(t *testing.T) {
+	server, observed := newFingerprintServer(t)
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	profile := *ProfileFirefox
+	profile.ALPN = []string{"http/1.1"}
+	if err := h.ConfigureClientProfile(&profile); err != nil {
+		t.Fatalf("ConfigureClientProfile failed: %v", err)
+	}
+	res, err := h.Request(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if _, err := res.Data(); err != nil {
+		t.Fatalf("read body failed: %v", err)
+	}
+	if hello, proto := observed(); proto != 1 || len(hello.SupportedProtos) != 1 {
+		t.Fatalf("proto = %d, ALPN = %v; want HTTP/1.1 only", proto, hello.SupportedProtos)
+	}
+
+	if err := h.ConfigureClientProfile(nil); err != nil {
+		t.Fatalf("ConfigureClientProfile(nil) failed: %v", err)
+	}
+	if h.client.Transport != h.transport {
+		t.Fatalf("client transport = %T, want the base transport after reset", h.client.Transport)
+	}
+	if err := NewHTTP3(nil).ConfigureClientProfile(ProfileChrome); err == nil {
+		t.Fatal("ConfigureClientProfile on HTTP3 should fail")
+	}
+}
+
+// h2Observation 是原始 HTTP/2 服务端看到的客户端连接开头与第一个请求.
+type h2Observation struct {
+	settings []http2.Setting
+	flow     uint32
+	priority http2.PriorityParam
+	fields   []string
+}
+
+// newRawH2Server 启动一个只协商 h2 的 TLS 服务端, 用 Framer 记录帧与请求头的原始顺序,
+// 每个请求回 200 "ok".
+func newRawH2Server(t *testing.T) (*httptest.Server, <-chan h2Observation) {
+	t.Helper()
+	observed := make(chan h2Observation, 1)
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{NextProtos: []string{"h2"}}
+	server.Config.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){
+		"h2": func(_ *http.Server, conn *tls.Conn, _ http.Handler) {
+			defer conn.Close()
+			if _, err := io.ReadFull(conn, make([]byte, len(http2.ClientPreface))); err != nil {
+				return
+			}
+			framer := http2.NewFramer(conn, conn)
+			framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+			var obs h2Observation
+			for {
+				frame, err := framer.ReadFrame()
+				if err != nil {
+					return
+				}
+				switch frame := frame.(type) {
+				case *http2.SettingsFrame:
+					if frame.IsAck() {
+						continue
+					}
+					_ = frame.ForeachSetting(func(setting http2.Setting) error {
+						obs.settings = append(obs.settings, setting)
+						return nil
+					})
+					_ = framer.WriteSettings()
+					_ = framer.WriteSettingsAck()
+				case *http2.WindowUpdateFrame:
+					if frame.StreamID == 0 {
+						obs.flow = frame.Increment
+					}
+				case *http2.MetaHeadersFrame:
+					obs.priority = frame.Priority
+					for _, field := range frame.Fields {
+						obs.fields = append(obs.fields, field.Name)
+					}
+					select {
+					case observed <- obs:
+					default:
+					}
+					var block bytes.Buffer
+					encoder := hpack.NewEncoder(&block)
+					_ = encoder.WriteField(hpack.HeaderField{Name: ":status", Value: "200"})
+					_ = encoder.WriteField(hpack.HeaderField{Name: "content-length", Value: "2"})
+					_ = framer.WriteHeaders(http2.HeadersFrameParam{StreamID: frame.StreamID, BlockFragment: block.Bytes(), EndHeaders: true})
+					_ = framer.WriteData(frame.StreamID, true, []byte("ok"))
+				}
+			}
+		},
+	}
+	server.StartTLS()
+	return server, observed
+}
+
+func TestClientProfileHTTP2FrameAndHeaderOrder(t *testing.T) {
+	server, observed := newRawH2Server(t)
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	if err := h.ConfigureClientProfile(ProfileChrome); err != nil {
+		t.Fatalf("ConfigureClientProfile failed: %v", err)
+	}
+	ctx := WithHeaderOrder(context.Background(), "X-B", "x-a")
+	headers := http.Header{"Accept": {"*/*"}, "X-A": {"a"}, "X-B": {"b"}, "User-Agent": {"ua"}, "Connection": {"keep-alive"}}
+	res, err := h.Request(ctx, server.URL, headers, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if text, err := res.Text(); err != nil || text != "ok" || res.StatusCode != http.StatusOK {
+		t.Fatalf("response = %d %q, %v", res.StatusCode, text, err)
+	}
+
+	obs := <-observed
+	if !slices.Equal(obs.settings, ProfileChrome.Settings) {
+		t.Fatalf("SETTINGS = %v, want %v", obs.settings, ProfileChrome.Settings)
+	}
+	if obs.flow != ProfileChrome.ConnectionFlow {
+		t.Fatalf("connection WINDOW_UPDATE = %d, want %d", obs.flow, ProfileChrome.ConnectionFlow)
+	}
+	if obs.priority != *ProfileChrome.HeaderPriority {
+		t.Fatalf("HEADERS priority = %+v, want %+v", obs.priority, *ProfileChrome.HeaderPriority)
+	}
+	want := []string{":method", ":authority", ":scheme", ":path", "x-b", "x-a", "user-agent", "accept", "accept-encoding"}
+	if !slices.Equal(obs.fields, want) {
+		t.Fatalf("header order = %v, want %v", obs.fields, want)
+	}
+}
+
+func TestClientProfileHTTP1HeaderOrder(t *testing.T) {
+	listener, err := gonet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	lines := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		var request []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line = strings.TrimRight(line, "\r\n"); line == "" {
+				break
+			}
+			request = append(request, line)
+		}
+		lines <- request
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+		_, _ = reader.ReadByte()
+	}()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	if err := h.ConfigureClientProfile(ProfileFirefox); err != nil {
+		t.Fatalf("ConfigureClientProfile failed: %v", err)
+	}
+	ctx := WithHeaderOrder(context.Background(), "x-custom")
+	headers := http.Header{"Accept": {"*/*"}, "User-Agent": {"ua"}, "X-Custom": {"1"}, "Accept-Language": {"en"}}
+	res, err := h.Request(ctx, "http://"+listener.Addr().String()+"/path?q=1", headers, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if text, err := res.Text(); err != nil || text != "ok" {
+		t.Fatalf("response = %q, %v", text, err)
+	}
+	want := []string{
+		"GET /path?q=1 HTTP/1.1",
+		"Host: " + listener.Addr().String(),
+		"X-Custom: 1",
+		"User-Agent: ua",
+		"Accept: */*",
+		"Accept-Language: en",
+		"Accept-Encoding: " + acceptEncoding,
+	}
+	if got := <-lines; !slices.Equal(got, want) {
+		t.Fatalf("request head = %q, want %q", got, want)
+	}
+}
+
+func TestClientProfileHTTP2RequestBody(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			http.Error(w, "want HTTP/2", http.StatusBadRequest)
+			return
+		}
+		_, _ = io.Copy(w, r.Body)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	if err := h.ConfigureClientProfile(ProfileSafari); err != nil {
+		t.Fatalf("ConfigureClientProfile failed: %v", err)
+	}
+	// 超过默认 64KiB 窗口, 覆盖双向流控.
+	body := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	for range 2 {
+		res, err := h.Request(context.Background(), server.URL, nil, bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		data, err := res.Data()
+		if err != nil || res.StatusCode != http.StatusOK || !bytes.Equal(data, body) {
+			t.Fatalf("echo = %d, %d bytes, %v; want %d bytes", res.StatusCode, len(data), err, len(body))
+		}
+	}
+}
+
+func TestClientProfileKeepsClientTLSSettings(t *testing.T) {
+	type seen struct {
+		version uint16
+		certs   int
+	}
+	observed := make(chan seen, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		observed <- seen{r.TLS.Version, len(r.TLS.PeerCertificates)}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	config := DefaultTLSConfig()
+	config.MaxVersion = tls.VersionTLS12
+	config.Certificates = server.TLS.Certificates
+	h := NewHTTP(config)
+	defer h.Dispose()
+	if err := h.ConfigureClientProfile(ProfileChrome); err != nil {
+		t.Fatalf("ConfigureClientProfile failed: %v", err)
+	}
+	res, err := h.Request(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	_ = res.Close()
+	if got := <-observed; got.version != tls.VersionTLS12 || got.certs != 1 {
+		t.Fatalf("server saw version %#x with %d client certs; want TLS 1.2 with 1", got.version, got.certs)
+	}
+	if res.TLS == nil || res.TLS.Version != tls.VersionTLS12 {
+		t.Fatalf("Response.TLS = %+v, want TLS 1.2 state", res.TLS)
+	}
+}
+
+// newTestProxy 启动一个 HTTP 代理: CONNECT 建立到目标的隧道, 其余请求直接回 "proxied".
+// 返回的函数给出代理收到的请求行 (方法 + 目标).
+func newTestProxy(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if r.Method == http.MethodConnect {
+			lines = append(lines, r.Method+" "+r.Host)
+		} else {
+			lines = append(lines, r.Method+" "+r.URL.String())
+		}
+		mu.Unlock()
+		if r.Method != http.MethodConnect {
+			_, _ = io.WriteString(w, "proxied")
+			return
+		}
+		target, err := gonet.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+		conn, buffered, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go func() { _, _ = io.Copy(target, buffered) }()
+		_, _ = io.Copy(conn, target)
+	}))
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(lines)
+	}
+}
+
+func TestClientProfileUsesConfiguredProxy(t *testing.T) {
+	target, observed := newFingerprintServer(t)
+	defer target.Close()
+	proxyServer, proxied := newTestProxy(t)
+	defer proxyServer.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	if err := h.ConfigureClientProfile(ProfileChrome); err != nil {
+		t.Fatalf("ConfigureClientProfile failed: %v", err)
+	}
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	if err := h.ConfigureProxy(http.ProxyURL(proxyURL), false); err != nil {
+		t.Fatalf("ConfigureProxy failed: %v", err)
+	}
+
+	res, err := h.Request(context.Background(), target.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("https request failed: %v", err)
+	}
+	if _, err := res.Data(); err != nil {
+		t.Fatalf("read body failed: %v", err)
+	}
+	hello, proto := observed()
+	if proto != 2 || hello == nil || len(hello.CipherSuites) == 0 || !isGREASE(hello.CipherSuites[0]) {
+		t.Fatalf("tunnelled request: proto = %d, hello = %+v; want fingerprinted HTTP/2", proto, hello)
+	}
+
+	res, err = h.Request(context.Background(), "http://origin.invalid/path", nil, nil)
+	if err != nil {
+		t.Fatalf("http request failed: %v", err)
+	}
+	if text, err := res.Text(); err != nil || text != "proxied" {
+		t.Fatalf("http response = %q, %v; want it served by the proxy", text, err)
+	}
+	want := []string{"CONNECT " + strings.TrimPrefix(target.URL, "https://"), "GET http://origin.invalid/path"}
+	if got := proxied(); !slices.Equal(got, want) {
+		t.Fatalf("proxy saw %q, want %q", got, want)
+	}
+}
+
+func TestClientProfileAppliesToHostTransports(t *testing.T) {
+	server, observed := newFingerprintServer(t)
+	defer server.Close()
+
+	h := NewHTTPWithOptions(&HTTPOptions{Hosts: []HostOptions{{Pattern: "127.0.0.1", TLSHandshakeTimeout: 5 * time.Second}}})
+	defer h.Dispose()
+	if err := h.ConfigureClientProfile(ProfileChrome); err != nil {
+		t.Fatalf("ConfigureClientProfile failed: %v", err)
+	}
+	res, err := h.Request(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if _, err := res.Data(); err != nil {
+		t.Fatalf("read body failed: %v", err)
+	}
+	if hello, _ := observed(); hello == nil || len(hello.CipherSuites) == 0 || !isGREASE(hello.CipherSuites[0]) {
+		t.Fatalf("host with its own transport sent a non-fingerprinted ClientHello: %+v", hello)
+	}
+}
@@ -0,0 +1,826 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// HTTP/2 协议默认值 (RFC 9113 6.5.2).
+const (
+	h2DefaultWindow       = 65535
+	h2DefaultFrameSize    = 16384
+	h2DefaultHeaderTable  = 4096
+	h2DefaultHeaderList   = 10 << 20
+	h2MaxConcurrentStream = 1000 // 对端未声明 MAX_CONCURRENT_STREAMS 时的上限
+)
+
+var (
+	errH2StreamClosed = errors.New("fingerprint: http2 stream closed")
+	errH2BodyClosed   = errors.New("fingerprint: http2 response body closed")
+	errH2Timeout      = errors.New("fingerprint: timeout awaiting response headers")
+	// errH2NoStream: 连接不能再开流, 请求尚未发出, 请求体未被读取.
+	errH2NoStream = fmt.Errorf("%w: no stream available", errConnUnusable)
+)
+
+// h2ClientConn 是一条带指纹的 HTTP/2 连接. 建连时按 profile 发送 SETTINGS 与 WINDOW_UPDATE,
+// 请求的 HEADERS 按 profile 的伪头顺序与请求头顺序编码.
+type h2ClientConn struct {
+	t    *fingerprintTransport
+	conn net.Conn
+	tls  *tls.ConnectionState
+	key  string // fingerprintTransport.h2 中的键
+
+	// wmu 串行化帧写入, 保护 bw / framer 的写端 / henc / hbuf.
+	wmu    sync.Mutex
+	bw     *bufio.Writer
+	framer *http2.Framer
+	henc   *hpack.Encoder
+	hbuf   bytes.Buffer
+
+	mu   sync.Mutex
+	cond *sync.Cond // 发送窗口变化、流结束、连接关闭时广播
+	// streams 是进行中的流, 流在收完响应或被重置后移除.
+	streams       map[uint32]*h2Stream
+	nextStreamID  uint32
+	goAway        bool  // 收到 GOAWAY, 不再接新请求
+	err           error // 连接已关闭时的原因
+	maxConcurrent uint32
+	maxFrameSize  uint32 // 对端的 MAX_FRAME_SIZE
+	peerWindow    int32  // 对端的 INITIAL_WINDOW_SIZE, 新流的发送窗口
+	sendWindow    int64  // 连接级发送窗口
+	streamWindow  uint32 // 本端的 INITIAL_WINDOW_SIZE
+	connWindow    uint32 // 本端的连接级接收窗口
+	connUnacked   uint32 // 已交给调用方但尚未用 WINDOW_UPDATE 归还的连接级字节数
+	idleSince     time.Time
+}
+
+func newH2ClientConn(t *fingerprintTransport, conn net.Conn, state *tls.ConnectionState, key string) (*h2ClientConn, error) {
+	cc := &h2ClientConn{
+		t:             t,
+		conn:          conn,
+		tls:           state,
+		key:           key,
+		bw:            bufio.NewWriterSize(conn, 32<<10),
+		streams:       make(map[uint32]*h2Stream),
+		nextStreamID:  1,
+		maxConcurrent: h2MaxConcurrentStream,
+		maxFrameSize:  h2DefaultFrameSize,
+		peerWindow:    h2DefaultWindow,
+		sendWindow:    h2DefaultWindow,
+		streamWindow:  h2DefaultWindow,
+		connWindow:    h2DefaultWindow + t.profile.ConnectionFlow,
+		idleSince:     time.Now(),
+	}
+	cc.cond = sync.NewCond(&cc.mu)
+	cc.henc = hpack.NewEncoder(&cc.hbuf)
+	cc.framer = http2.NewFramer(cc.bw, conn)
+	headerTable, headerList := uint32(h2DefaultHeaderTable), uint32(h2DefaultHeaderList)
+	for _, setting := range t.profile.Settings {
+		switch setting.ID {
+		case http2.SettingHeaderTableSize:
+			headerTable = setting.Val
+		case http2.SettingInitialWindowSize:
+			cc.streamWindow = setting.Val
+		case http2.SettingMaxHeaderListSize:
+			headerList = setting.Val
+		}
+	}
+	cc.framer.ReadMetaHeaders = hpack.NewDecoder(headerTable, nil)
+	cc.framer.MaxHeaderListSize = headerList
+
+	_, _ = cc.bw.WriteString(http2.ClientPreface)
+	_ = cc.framer.WriteSettings(t.profile.Settings...)
+	if t.profile.ConnectionFlow > 0 {
+		_ = cc.framer.WriteWindowUpdate(0, t.profile.ConnectionFlow)
+	}
+	if err := cc.bw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	go cc.readLoop()
+	return cc, nil
+}
+
+// canTakeNewRequest 报告连接能否再开一个流.
+func (cc *h2ClientConn) canTakeNewRequest() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.err != nil || cc.goAway || cc.nextStreamID >= 1<<31 || uint32(len(cc.streams)) >= cc.maxConcurrent {
+		return false
+	}
+	if timeout := cc.t.base.IdleConnTimeout; timeout > 0 && len(cc.streams) == 0 && time.Since(cc.idleSince) > timeout {
+		return false
+	}
+	return true
+}
+
+// closeIfIdle 在没有进行中的流时关闭连接.
+func (cc *h2ClientConn) closeIfIdle() {
+	cc.mu.Lock()
+	if len(cc.streams) > 0 || cc.err != nil {
+		cc.mu.Unlock()
+		return
+	}
+	cc.err = errors.New("fingerprint: http2 connection closed as idle")
+	cc.mu.Unlock()
+	_ = cc.conn.Close()
+	cc.t.removeH2(cc)
+}
+
+// closeWithError 关闭连接并以 err 结束所有进行中的流.
+func (cc *h2ClientConn) closeWithError(err error) {
+	cc.mu.Lock()
+	if cc.err != nil {
+		cc.mu.Unlock()
+		return
+	}
+	cc.err = err
+	streams := make([]*h2Stream, 0, len(cc.streams))
+	for _, cs := range cc.streams {
+		streams = append(streams, cs)
+	}
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	_ = cc.conn.Close()
+	cc.t.removeH2(cc)
+	for _, cs := range streams {
+		cs.finish(err)
+	}
+}
+
+// writeFrames 在 wmu 下执行 fn 并 flush, 写失败时关闭连接.
+func (cc *h2ClientConn) writeFrames(fn func() error) error {
+	cc.wmu.Lock()
+	err := fn()
+	if err == nil {
+		err = cc.bw.Flush()
+	}
+	cc.wmu.Unlock()
+	if err != nil {
+		cc.closeWithError(err)
+	}
+	return err
+}
+
+// h2Stream 是一个进行中的请求.
+type h2Stream struct {
+	cc   *h2ClientConn
+	id   uint32
+	req  *http.Request
+	resc chan *http.Response // 收到响应头时送出一次
+	body *h2Pipe
+
+	// 以下字段由 cc.mu 保护.
+	res         *http.Response
+	sendWindow  int64
+	recvUnacked uint32
+	done        bool
+	err         error
+	stopCtx     func() bool
+	donec       chan struct{}
+}
+
+// roundTrip 在 cc 上开一个流发出 req, 等待响应头. 返回 errH2NoStream 时 req 未被改动.
+func (cc *h2ClientConn) roundTrip(req *http.Request) (*http.Response, error) {
+	cs, err := cc.openStream(req)
+	if err != nil {
+		if err != errH2NoStream {
+			closeRequestBody(req)
+		}
+		return nil, err
+	}
+	var timeout <-chan time.Time
+	if d := cc.t.base.ResponseHeaderTimeout; d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case res := <-cs.resc:
+		return res, nil
+	case <-cs.donec:
+		select {
+		case res := <-cs.resc:
+			return res, nil
+		default:
+		}
+		return nil, cs.error()
+	case <-timeout:
+		cs.abort(errH2Timeout)
+		return nil, errH2Timeout
+	}
+}
+
+// openStream 分配流 id 并写出 HEADERS, 有请求体时另起 goroutine 发送.
+func (cc *h2ClientConn) openStream(req *http.Request) (*h2Stream, error) {
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	cc.wmu.Lock()
+	cc.mu.Lock()
+	if cc.err != nil || cc.goAway || cc.nextStreamID >= 1<<31 || uint32(len(cc.streams)) >= cc.maxConcurrent {
+		cc.mu.Unlock()
+		cc.wmu.Unlock()
+		return nil, errH2NoStream
+	}
+	cs := &h2Stream{
+		cc:         cc,
+		id:         cc.nextStreamID,
+		req:        req,
+		resc:       make(chan *http.Response, 1),
+		sendWindow: int64(cc.peerWindow),
+		donec:      make(chan struct{}),
+	}
+	cs.body = &h2Pipe{onRead: cs.refund}
+	cs.body.cond.L = &cs.body.mu
+	cc.nextStreamID += 2
+	cc.streams[cs.id] = cs
+	maxFrameSize := cc.maxFrameSize
+	cc.mu.Unlock()
+
+	block := cc.encodeHeaders(req)
+	err := cc.writeHeaders(cs.id, !hasBody, block, maxFrameSize)
+	if err == nil {
+		err = cc.bw.Flush()
+	}
+	cc.wmu.Unlock()
+	if err != nil {
+		cc.closeWithError(err)
+		return nil, err
+	}
+	stop := context.AfterFunc(req.Context(), func() { cs.abort(req.Context().Err()) })
+	cc.mu.Lock()
+	if cs.done {
+		stop()
+	} else {
+		cs.stopCtx = stop
+	}
+	cc.mu.Unlock()
+	if hasBody {
+		go cs.writeBody()
+	}
+	return cs, nil
+}
+
+// encodeHeaders 按 profile 的伪头顺序与请求头顺序编码 HEADERS 块. 调用方持有 wmu.
+func (cc *h2ClientConn) encodeHeaders(req *http.Request) []byte {
+	profile := cc.t.profile
+	cc.hbuf.Reset()
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	for _, name := range profile.pseudoHeaderOrder() {
+		var value string
+		switch name {
+		case ":method":
+			value = method
+		case ":authority":
+			value = requestHost(req)
+		case ":scheme":
+			value = req.URL.Scheme
+		case ":path":
+			value = req.URL.RequestURI()
+		}
+		_ = cc.henc.WriteField(hpack.HeaderField{Name: name, Value: value})
+	}
+	header := make(http.Header, len(req.Header)+1)
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		switch lower {
+		case "host", "connection", "proxy-connection", "keep-alive", "transfer-encoding", "upgrade", "content-length":
+			continue
+		case "te":
+			if !httpguts.HeaderValuesContainsToken(values, "trailers") {
+				continue
+			}
+			values = []string{"trailers"}
+		}
+		header[name] = values
+	}
+	if length, _ := outgoingLength(req); length > 0 || (length == 0 && methodExpectsBody(method)) {
+		header["Content-Length"] = []string{strconv.FormatInt(length, 10)}
+	}
+	for _, field := range orderedHeader(header, headerOrderFromContext(req.Context()), profile.HeaderOrder) {
+		_ = cc.henc.WriteField(hpack.HeaderField{Name: strings.ToLower(field.name), Value: field.value})
+	}
+	return cc.hbuf.Bytes()
+}
+
+// writeHeaders 写 HEADERS 及必要的 CONTINUATION 帧. 调用方持有 wmu.
+func (cc *h2ClientConn) writeHeaders(id uint32, endStream bool, block []byte, maxFrameSize uint32) error {
+	first := true
+	for first || len(block) > 0 {
+		chunk := block
+		if uint32(len(chunk)) > maxFrameSize {
+			chunk = chunk[:maxFrameSize]
+		}
+		block = block[len(chunk):]
+		endHeaders := len(block) == 0
+		var err error
+		if first {
+			param := http2.HeadersFrameParam{StreamID: id, BlockFragment: chunk, EndStream: endStream, EndHeaders: endHeaders}
+			if priority := cc.t.profile.HeaderPriority; priority != nil {
+				param.Priority = *priority
+			}
+			err = cc.framer.WriteHeaders(param)
+			first = false
+		} else {
+			err = cc.framer.WriteContinuation(id, endHeaders, chunk)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBody 按流控窗口发送请求体, 结束时带 END_STREAM.
+func (cs *h2Stream) writeBody() {
+	cc := cs.cc
+	body := cs.req.Body
+	defer body.Close()
+	buf := make([]byte, h2DefaultFrameSize)
+	for {
+		n, rerr := body.Read(buf)
+		data := buf[:n]
+		for len(data) > 0 {
+			allowed, err := cs.awaitSendWindow(len(data))
+			if err != nil {
+				if cs.error() == errH2StreamClosed {
+					// 对端已完整响应, 通知其不再发送剩余的请求体.
+					_ = cc.writeFrames(func() error { return cc.framer.WriteRSTStream(cs.id, http2.ErrCodeCancel) })
+				}
+				return
+			}
+			chunk := data[:allowed]
+			data = data[allowed:]
+			if cc.writeFrames(func() error { return cc.framer.WriteData(cs.id, false, chunk) }) != nil {
+				return
+			}
+		}
+		if rerr == io.EOF {
+			_ = cc.writeFrames(func() error { return cc.framer.WriteData(cs.id, true, nil) })
+			return
+		}
+		if rerr != nil {
+			cs.abort(fmt.Errorf("%w: %w", errRequestBody, rerr))
+			return
+		}
+	}
+}
+
+// awaitSendWindow 等待连接与流的发送窗口, 返回本次可发送的字节数.
+func (cs *h2Stream) awaitSendWindow(n int) (int, error) {
+	cc := cs.cc
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for {
+		if cs.done {
+			return 0, errH2StreamClosed
+		}
+		if cc.err != nil {
+			return 0, cc.err
+		}
+		if cs.sendWindow > 0 && cc.sendWindow > 0 {
+			allowed := min(int64(n), cs.sendWindow, cc.sendWindow, int64(cc.maxFrameSize))
+			cs.sendWindow -= allowed
+			cc.sendWindow -= allowed
+			return int(allowed), nil
+		}
+		cc.cond.Wait()
+	}
+}
+
+// abort 以 err 结束流并向对端发送 RST_STREAM(CANCEL).
+func (cs *h2Stream) abort(err error) {
+	if cs.finish(err) {
+		_ = cs.cc.writeFrames(func() error { return cs.cc.framer.WriteRSTStream(cs.id, http2.ErrCodeCancel) })
+	}
+}
+
+// finish 把流标记为结束并从连接中移除, 未读完的响应体以 err 中断 (err 为 nil 表示正常结束).
+// 返回 false 表示流此前已结束.
+func (cs *h2Stream) finish(err error) bool {
+	cc := cs.cc
+	cc.mu.Lock()
+	if cs.done {
+		cc.mu.Unlock()
+		return false
+	}
+	cs.done = true
+	cs.err = err
+	delete(cc.streams, cs.id)
+	if len(cc.streams) == 0 {
+		cc.idleSince = time.Now()
+	}
+	stopCtx := cs.stopCtx
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	if stopCtx != nil {
+		stopCtx()
+	}
+	if err == nil {
+		cs.body.closeWithError(io.EOF)
+	} else {
+		cs.discard(err)
+	}
+	close(cs.donec)
+	return true
+}
+
+// discard 以 err 中断响应体, 并归还被丢弃数据占用的连接级窗口.
+func (cs *h2Stream) discard(err error) {
+	if n := cs.body.breakWithError(err); n > 0 {
+		cs.refund(n)
+	}
+}
+
+func (cs *h2Stream) error() error {
+	cs.cc.mu.Lock()
+	defer cs.cc.mu.Unlock()
+	if cs.err == nil {
+		return errH2StreamClosed
+	}
+	return cs.err
+}
+
+// refund 在 n 字节被读走或丢弃后归还接收窗口, 累计过半时发送 WINDOW_UPDATE.
+// 流已结束时只归还连接级窗口.
+func (cs *h2Stream) refund(n int) {
+	cc := cs.cc
+	cc.mu.Lock()
+	connIncr := cc.takeConnWindow(uint32(n))
+	var streamIncr uint32
+	if !cs.done {
+		cs.recvUnacked += uint32(n)
+		if cs.recvUnacked >= cc.streamWindow/2 {
+			streamIncr, cs.recvUnacked = cs.recvUnacked, 0
+		}
+	}
+	cc.mu.Unlock()
+	if connIncr == 0 && streamIncr == 0 {
+		return
+	}
+	_ = cc.writeFrames(func() error {
+		if connIncr > 0 {
+			if err := cc.framer.WriteWindowUpdate(0, connIncr); err != nil {
+				return err
+			}
+		}
+		if streamIncr > 0 {
+			return cc.framer.WriteWindowUpdate(cs.id, streamIncr)
+		}
+		return nil
+	})
+}
+
+// takeConnWindow 累计 n 字节待归还的连接级窗口, 过半时返回要发送的增量. 调用方持有 cc.mu.
+func (cc *h2ClientConn) takeConnWindow(n uint32) uint32 {
+	cc.connUnacked += n
+	if cc.connUnacked < cc.connWindow/2 {
+		return 0
+	}
+	incr := cc.connUnacked
+	cc.connUnacked = 0
+	return incr
+}
+
+func (cc *h2ClientConn) stream(id uint32) *h2Stream {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.streams[id]
+}
+
+// readLoop 读取并分派对端的帧, 连接出错时结束所有流.
+func (cc *h2ClientConn) readLoop() {
+	for {
+		frame, err := cc.framer.ReadFrame()
+		if err != nil {
+			if se, ok := errors.AsType[http2.StreamError](err); ok {
+				if cs := cc.stream(se.StreamID); cs != nil {
+					cs.abort(se)
+				}
+				continue
+			}
+			cc.closeWithError(err)
+			return
+		}
+		switch frame := frame.(type) {
+		case *http2.MetaHeadersFrame:
+			err = cc.onHeaders(frame)
+		case *http2.DataFrame:
+			err = cc.onData(frame)
+		case *http2.SettingsFrame:
+			err = cc.onSettings(frame)
+		case *http2.WindowUpdateFrame:
+			cc.onWindowUpdate(frame)
+		case *http2.RSTStreamFrame:
+			if cs := cc.stream(frame.StreamID); cs != nil {
+				err := error(http2.StreamError{StreamID: frame.StreamID, Code: frame.ErrCode})
+				if frame.ErrCode == http2.ErrCodeRefusedStream {
+					err = fmt.Errorf("%w: %v", errConnUnusable, err)
+				}
+				cs.finish(err)
+			}
+		case *http2.GoAwayFrame:
+			cc.onGoAway(frame)
+		case *http2.PingFrame:
+			if !frame.IsAck() {
+				data := frame.Data
+				err = cc.writeFrames(func() error { return cc.framer.WritePing(true, data) })
+			}
+		case *http2.PushPromiseFrame:
+			err = http2.ConnectionError(http2.ErrCodeProtocol)
+		}
+		if err != nil {
+			if ce, ok := errors.AsType[http2.ConnectionError](err); ok {
+				_ = cc.writeFrames(func() error { return cc.framer.WriteGoAway(0, http2.ErrCode(ce), nil) })
+			}
+			cc.closeWithError(err)
+			return
+		}
+	}
+}
+
+func (cc *h2ClientConn) onHeaders(frame *http2.MetaHeadersFrame) error {
+	cs := cc.stream(frame.StreamID)
+	if cs == nil {
+		return nil
+	}
+	cc.mu.Lock()
+	res := cs.res
+	cc.mu.Unlock()
+	if res != nil {
+		// 响应头之后的 HEADERS 是 trailer, 必须结束流.
+		if !frame.StreamEnded() {
+			cs.abort(http2.StreamError{StreamID: cs.id, Code: http2.ErrCodeProtocol})
+			return nil
+		}
+		trailer := make(http.Header)
+		for _, field := range frame.RegularFields() {
+			trailer.Add(http.CanonicalHeaderKey(field.Name), field.Value)
+		}
+		cs.body.setTrailer(res, trailer)
+		cs.finish(nil)
+		return nil
+	}
+	status := frame.PseudoValue("status")
+	code, err := strconv.Atoi(status)
+	if err != nil || len(status) != 3 {
+		cs.abort(fmt.Errorf("fingerprint: malformed response status %q", status))
+		return nil
+	}
+	if code >= 100 && code < 200 {
+		return nil
+	}
+	res = &http.Response{
+		Status:        status + " " + http.StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		Header:        make(http.Header),
+		ContentLength: -1,
+		Request:       cs.req,
+		TLS:           cc.tls,
+	}
+	for _, field := range frame.RegularFields() {
+		res.Header.Add(http.CanonicalHeaderKey(field.Name), field.Value)
+	}
+	for _, names := range res.Header["Trailer"] {
+		for name := range strings.SplitSeq(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				if res.Trailer == nil {
+					res.Trailer = make(http.Header)
+				}
+				res.Trailer[http.CanonicalHeaderKey(name)] = nil
+			}
+		}
+	}
+	if values := res.Header["Content-Length"]; len(values) == 1 {
+		if length, err := strconv.ParseInt(values[0], 10, 64); err == nil && length >= 0 {
+			res.ContentLength = length
+		}
+	}
+	if frame.StreamEnded() || cs.req.Method == http.MethodHead {
+		if frame.StreamEnded() && cs.req.Method != http.MethodHead {
+			res.ContentLength = 0
+		}
+		res.Body = http.NoBody
+	} else {
+		res.Body = &h2Body{cs: cs}
+	}
+	cc.mu.Lock()
+	cs.res = res
+	cc.mu.Unlock()
+	cs.resc <- res
+	if frame.StreamEnded() {
+		cs.finish(nil)
+	}
+	return nil
+}
+
+func (cc *h2ClientConn) onData(frame *http2.DataFrame) error {
+	cs := cc.stream(frame.StreamID)
+	length := frame.Length
+	data := frame.Data()
+	if cs == nil {
+		// 已结束的流: 数据直接丢弃, 归还连接级窗口.
+		cc.mu.Lock()
+		incr := cc.takeConnWindow(length)
+		cc.mu.Unlock()
+		if incr > 0 {
+			return cc.writeFrames(func() error { return cc.framer.WriteWindowUpdate(0, incr) })
+		}
+		return nil
+	}
+	cc.mu.Lock()
+	gotResponse := cs.res != nil
+	cc.mu.Unlock()
+	if !gotResponse {
+		cs.abort(http2.StreamError{StreamID: cs.id, Code: http2.ErrCodeProtocol})
+		return nil
+	}
+	if padding := int(length) - len(data); padding > 0 {
+		cs.refund(padding)
+	}
+	if len(data) > 0 && !cs.body.write(data) {
+		cs.refund(len(data))
+	}
+	if frame.StreamEnded() {
+		cs.finish(nil)
+	}
+	return nil
+}
+
+func (cc *h2ClientConn) onSettings(frame *http2.SettingsFrame) error {
+	if frame.IsAck() {
+		return nil
+	}
+	cc.mu.Lock()
+	err := frame.ForeachSetting(func(setting http2.Setting) error {
+		switch setting.ID {
+		case http2.SettingMaxConcurrentStreams:
+			cc.maxConcurrent = setting.Val
+		case http2.SettingInitialWindowSize:
+			delta := int64(setting.Val) - int64(cc.peerWindow)
+			for _, cs := range cc.streams {
+				cs.sendWindow += delta
+			}
+			cc.peerWindow = int32(setting.Val)
+		case http2.SettingMaxFrameSize:
+			cc.maxFrameSize = setting.Val
+		}
+		return nil
+	})
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return cc.writeFrames(func() error {
+		if value, ok := frame.Value(http2.SettingHeaderTableSize); ok {
+			cc.henc.SetMaxDynamicTableSizeLimit(value)
+		}
+		return cc.framer.WriteSettingsAck()
+	})
+}
+
+func (cc *h2ClientConn) onWindowUpdate(frame *http2.WindowUpdateFrame) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if frame.StreamID == 0 {
+		cc.sendWindow += int64(frame.Increment)
+	} else if cs := cc.streams[frame.StreamID]; cs != nil {
+		cs.sendWindow += int64(frame.Increment)
+	}
+	cc.cond.Broadcast()
+}
+
+// onGoAway 停止在连接上开新流; 对端未处理的流 (id 大于 LastStreamID) 可以换连接重发.
+func (cc *h2ClientConn) onGoAway(frame *http2.GoAwayFrame) {
+	cc.mu.Lock()
+	cc.goAway = true
+	var unprocessed []*h2Stream
+	for id, cs := range cc.streams {
+		if id > frame.LastStreamID {
+			unprocessed = append(unprocessed, cs)
+		}
+	}
+	remaining := len(cc.streams) - len(unprocessed)
+	cc.mu.Unlock()
+	cc.t.removeH2(cc)
+	for _, cs := range unprocessed {
+		cs.finish(fmt.Errorf("%w: server sent GOAWAY", errConnUnusable))
+	}
+	if remaining == 0 {
+		cc.closeWithError(fmt.Errorf("fingerprint: server sent GOAWAY (%v)", frame.ErrCode))
+	}
+}
+
+// h2Body 是响应体, 关闭时若流未结束则向对端发送 RST_STREAM.
+type h2Body struct {
+	cs *h2Stream
+}
+
+func (b *h2Body) Read(p []byte) (int, error) {
+	return b.cs.body.Read(p)
+}
+
+func (b *h2Body) Close() error {
+	b.cs.abort(errH2BodyClosed)
+	b.cs.discard(errH2BodyClosed)
+	return nil
+}
+
+// h2Pipe 缓存收到的 DATA, 调用方读走后经 onRead 归还流控窗口.
+type h2Pipe struct {
+	mu      sync.Mutex
+	cond    sync.Cond
+	buf     bytes.Buffer
+	err     error
+	trailer func()
+	onRead  func(n int)
+}
+
+func (p *h2Pipe) Read(b []byte) (int, error) {
+	p.mu.Lock()
+	for p.buf.Len() == 0 && p.err == nil {
+		p.cond.Wait()
+	}
+	if p.buf.Len() > 0 {
+		n, _ := p.buf.Read(b)
+		p.mu.Unlock()
+		p.onRead(n)
+		return n, nil
+	}
+	err, trailer := p.err, p.trailer
+	p.trailer = nil
+	p.mu.Unlock()
+	if trailer != nil {
+		trailer()
+	}
+	return 0, err
+}
+
+// write 缓存 data, 管道已结束时丢弃并返回 false.
+func (p *h2Pipe) write(data []byte) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return false
+	}
+	p.buf.Write(data)
+	p.cond.Signal()
+	return true
+}
+
+// setTrailer 在调用方读到 EOF 时把 trailer 填入 res.Trailer.
+func (p *h2Pipe) setTrailer(res *http.Response, trailer http.Header) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.trailer = func() {
+		if res.Trailer == nil {
+			res.Trailer = make(http.Header)
+		}
+		for name, values := range trailer {
+			res.Trailer[name] = values
+		}
+	}
+}
+
+// closeWithError 在缓存的数据读完后返回 err.
+func (p *h2Pipe) closeWithError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+		p.cond.Broadcast()
+	}
+}
+
+// breakWithError 丢弃缓存的数据并立即返回 err, 返回丢弃的字节数.
+func (p *h2Pipe) breakWithError(err error) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil || p.err == io.EOF {
+		p.err = err
+	}
+	n := p.buf.Len()
+	p.buf.Reset()
+	p.cond.Broadcast()
+	return n
+}
@@ -0,0 +1,565 @@
+package net
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// fingerprintTransport 是 ConfigureClientProfile 使用的 transport: TLS 握手用 profile 的
+// ClientHello, 按协商结果走自带的 HTTP/1.1 或 HTTP/2 实现, 以便控制帧与请求头的顺序.
+// 代理、拨号、TLSClientConfig 与超时在每次建连时从 base 读取, 与 ConfigureProxy /
+// ConfigureProxyDial 等 setter 保持一致.
+type fingerprintTransport struct {
+	base    *http.Transport
+	profile *ClientProfile
+
+	mu   sync.Mutex
+	h2   map[string][]*h2ClientConn // connectKey -> HTTP/2 连接
+	idle map[string][]*h1Conn       // connectKey -> 空闲的 HTTP/1.1 连接
+}
+
+// connectTarget 描述一条连接的去向: 目标 scheme / addr 与所经的代理 (nil 为直连).
+type connectTarget struct {
+	scheme, addr string
+	proxy        *url.URL
+}
+
+// forward 报告是否把请求以绝对 URI 直接交给 http(s) 代理转发 (http 目标), 而非建隧道.
+func (target connectTarget) forward() bool {
+	return target.proxy != nil && target.scheme == "http" && (target.proxy.Scheme == "http" || target.proxy.Scheme == "https")
+}
+
+// key 是连接池的键, 经不同代理的连接互不复用.
+func (target connectTarget) key() string {
+	if target.proxy == nil {
+		return target.scheme + "://" + target.addr
+	}
+	return target.proxy.String() + "|" + target.scheme + "://" + target.addr
+}
+
+func newFingerprintTransport(base *http.Transport, profile *ClientProfile) *fingerprintTransport {
+	return &fingerprintTransport{
+		base:    base,
+		profile: profile,
+		h2:      make(map[string][]*h2ClientConn),
+		idle:    make(map[string][]*h1Conn),
+	}
+}
+
+// errConnUnusable: 连接在请求被处理前已不可用, 可以换一条连接重发.
+var errConnUnusable = errors.New("fingerprint: connection no longer usable")
+
+func (t *fingerprintTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL == nil {
+		closeRequestBody(req)
+		return nil, errors.New("fingerprint: nil Request.URL")
+	}
+	scheme := req.URL.Scheme
+	if scheme != "http" && scheme != "https" {
+		closeRequestBody(req)
+		return nil, fmt.Errorf("fingerprint: unsupported protocol scheme %q", scheme)
+	}
+	if req.URL.Host == "" {
+		closeRequestBody(req)
+		return nil, errors.New("fingerprint: no Host in request URL")
+	}
+	if !httpguts.ValidHostHeader(requestHost(req)) {
+		closeRequestBody(req)
+		return nil, fmt.Errorf("fingerprint: invalid Host header %q", requestHost(req))
+	}
+	if err := validateRequestHeader(req.Header); err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	target := connectTarget{scheme: scheme, addr: canonicalAddr(req.URL)}
+	if t.base.Proxy != nil {
+		proxyURL, err := t.base.Proxy(req)
+		if err != nil {
+			closeRequestBody(req)
+			return nil, err
+		}
+		target.proxy = proxyURL
+	}
+	for attempt := 0; ; attempt++ {
+		res, err := t.roundTrip(req, target)
+		if err == nil || !errors.Is(err, errConnUnusable) || attempt >= 5 {
+			return res, err
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, err
+			}
+			body, berr := req.GetBody()
+			if berr != nil {
+				return nil, berr
+			}
+			clone := *req
+			clone.Body = body
+			req = &clone
+		}
+	}
+}
+
+func (t *fingerprintTransport) roundTrip(req *http.Request, target connectTarget) (*http.Response, error) {
+	key := target.key()
+	if target.scheme == "https" {
+		if cc := t.getH2(key); cc != nil {
+			if res, err := cc.roundTrip(req); err != errH2NoStream {
+				return res, err
+			}
+		}
+	}
+	if conn := t.getIdle(key); conn != nil {
+		return conn.roundTrip(req, true)
+	}
+	conn, state, err := t.dial(req.Context(), target)
+	if err != nil {
+		closeRequestBody(req)
+		return nil, err
+	}
+	if state != nil && state.NegotiatedProtocol == "h2" {
+		cc, err := newH2ClientConn(t, conn, state, key)
+		if err != nil {
+			closeRequestBody(req)
+			return nil, err
+		}
+		t.mu.Lock()
+		t.h2[key] = append(t.h2[key], cc)
+		t.mu.Unlock()
+		res, err := cc.roundTrip(req)
+		if err == errH2NoStream {
+			closeRequestBody(req)
+		}
+		return res, err
+	}
+	return t.newH1Conn(conn, state, target).roundTrip(req, false)
+}
+
+// dial 建立到 target 的连接, https 再做指纹握手. 直连与 http(s) 代理用 base 的 DialContext;
+// https 目标经代理时先建隧道 (http(s) 代理走 CONNECT, socks5 / ws 代理直接拨号).
+func (t *fingerprintTransport) dial(ctx context.Context, target connectTarget) (net.Conn, *tls.ConnectionState, error) {
+	dial := t.base.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	var conn net.Conn
+	var err error
+	switch {
+	case target.proxy == nil:
+		conn, err = dial(ctx, "tcp", target.addr)
+	case target.forward():
+		conn, err = t.dialProxy(ctx, dial, target.proxy)
+	default:
+		conn, err = (&Proxy{TLSConfig: t.base.TLSClientConfig}).dialURL(ctx, target.proxy, "tcp", target.addr)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if target.scheme == "http" {
+		return conn, nil, nil
+	}
+	if timeout := t.base.TLSHandshakeTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	uconn, err := handshakeFingerprint(ctx, conn, t.base.TLSClientConfig, t.profile, target.addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return uconn, connectionState(uconn), nil
+}
+
+// dialProxy 连到 http(s) 代理本身, 供转发 http 请求; https 代理先完成 TLS 握手.
+func (t *fingerprintTransport) dialProxy(ctx context.Context, dial dialFunc, proxyURL *url.URL) (net.Conn, error) {
+	conn, err := dial(ctx, "tcp", canonicalAddr(proxyURL))
+	if err != nil || proxyURL.Scheme != "https" {
+		return conn, err
+	}
+	config := t.base.TLSClientConfig
+	if config == nil {
+		config = DefaultTLSConfig()
+	}
+	config = config.Clone()
+	config.ServerName = proxyURL.Hostname()
+	config.NextProtos = nil
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func (t *fingerprintTransport) getH2(addr string) *h2ClientConn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, cc := range t.h2[addr] {
+		if cc.canTakeNewRequest() {
+			return cc
+		}
+	}
+	return nil
+}
+
+func (t *fingerprintTransport) removeH2(cc *h2ClientConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conns := slices.DeleteFunc(t.h2[cc.key], func(c *h2ClientConn) bool { return c == cc })
+	if len(conns) == 0 {
+		delete(t.h2, cc.key)
+	} else {
+		t.h2[cc.key] = conns
+	}
+}
+
+func (t *fingerprintTransport) getIdle(key string) *h1Conn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	conns := t.idle[key]
+	for len(conns) > 0 {
+		conn := conns[len(conns)-1]
+		conns = conns[:len(conns)-1]
+		if timeout := t.base.IdleConnTimeout; timeout > 0 && time.Since(conn.idleAt) > timeout {
+			_ = conn.conn.Close()
+			continue
+		}
+		t.idle[key] = conns
+		return conn
+	}
+	delete(t.idle, key)
+	return nil
+}
+
+// putIdle 归还可复用的 HTTP/1.1 连接, 超过 MaxIdleConnsPerHost 时关闭.
+func (t *fingerprintTransport) putIdle(conn *h1Conn) {
+	limit := t.base.MaxIdleConnsPerHost
+	if limit == 0 {
+		limit = http.DefaultMaxIdleConnsPerHost
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.base.DisableKeepAlives || len(t.idle[conn.key]) >= limit {
+		_ = conn.conn.Close()
+		return
+	}
+	conn.idleAt = time.Now()
+	t.idle[conn.key] = append(t.idle[conn.key], conn)
+}
+
+// CloseIdleConnections 关闭空闲的 HTTP/1.1 连接与没有进行中请求的 HTTP/2 连接.
+func (t *fingerprintTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	idle := t.idle
+	t.idle = make(map[string][]*h1Conn)
+	var conns []*h2ClientConn
+	for _, list := range t.h2 {
+		conns = append(conns, list...)
+	}
+	t.mu.Unlock()
+	for _, list := range idle {
+		for _, conn := range list {
+			_ = conn.conn.Close()
+		}
+	}
+	for _, cc := range conns {
+		cc.closeIfIdle()
+	}
+}
+
+// h1Conn 是一条 HTTP/1.1 连接, 同一时刻只承载一个请求.
+type h1Conn struct {
+	t      *fingerprintTransport
+	conn   net.Conn
+	tls    *tls.ConnectionState
+	key    string
+	proxy  *url.URL // 非 nil 时按绝对 URI 经该代理转发
+	br     *bufio.Reader
+	bw     *bufio.Writer
+	idleAt time.Time
+}
+
+func (t *fingerprintTransport) newH1Conn(conn net.Conn, state *tls.ConnectionState, target connectTarget) *h1Conn {
+	pc := &h1Conn{t: t, conn: conn, tls: state, key: target.key(), br: bufio.NewReader(conn), bw: bufio.NewWriter(conn)}
+	if target.forward() {
+		pc.proxy = target.proxy
+	}
+	return pc
+}
+
+// roundTrip 发出 req 并读取响应头. reused 表示连接取自空闲池.
+func (pc *h1Conn) roundTrip(req *http.Request, reused bool) (*http.Response, error) {
+	ctx := req.Context()
+	stop := context.AfterFunc(ctx, func() { _ = pc.conn.Close() })
+	fail := func(err error) (*http.Response, error) {
+		stop()
+		_ = pc.conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	// 复用的连接可能已被对端关闭: 收到任何响应字节前失败且请求可重放时换连接重发.
+	retryable := reused && isIdempotent(req)
+	unusable := func(err error) error {
+		if ne, ok := errors.AsType[net.Error](err); !retryable || errors.Is(err, errRequestBody) || (ok && ne.Timeout()) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", errConnUnusable, err)
+	}
+	if err := writeH1Request(pc.bw, req, pc.t.profile, pc.proxy); err != nil {
+		return fail(unusable(err))
+	}
+	if timeout := pc.t.base.ResponseHeaderTimeout; timeout > 0 {
+		_ = pc.conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+	var res *http.Response
+	for {
+		if retryable {
+			if _, err := pc.br.Peek(1); err != nil {
+				return fail(unusable(err))
+			}
+		}
+		var err error
+		if res, err = http.ReadResponse(pc.br, req); err != nil {
+			return fail(err)
+		}
+		if res.StatusCode >= 200 || res.StatusCode == http.StatusSwitchingProtocols {
+			break
+		}
+		retryable = false
+	}
+	_ = pc.conn.SetReadDeadline(time.Time{})
+	res.TLS = pc.tls
+	keepAlive := !res.Close && !req.Close && res.StatusCode != http.StatusSwitchingProtocols
+	release := func(reuse bool) {
+		if stop() && reuse {
+			pc.t.putIdle(pc)
+		} else {
+			_ = pc.conn.Close()
+		}
+	}
+	if res.Body == http.NoBody {
+		release(keepAlive)
+		return res, nil
+	}
+	res.Body = &h1Body{body: res.Body, release: release, keepAlive: keepAlive}
+	return res, nil
+}
+
+// h1Body 在响应体读到 EOF 时归还连接, 提前关闭时关闭连接.
+type h1Body struct {
+	body      io.ReadCloser
+	release   func(reuse bool)
+	keepAlive bool
+	once      sync.Once
+}
+
+func (b *h1Body) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if err == io.EOF {
+		b.once.Do(func() { b.release(b.keepAlive) })
+	} else if err != nil {
+		b.once.Do(func() { b.release(false) })
+	}
+	return n, err
+}
+
+func (b *h1Body) Close() error {
+	b.once.Do(func() { b.release(false) })
+	return b.body.Close()
+}
+
+// errRequestBody 标记读取请求体时的错误, 这类错误不换连接重发.
+var errRequestBody = errors.New("fingerprint: request body")
+
+// writeH1Request 写请求行、Host 与按顺序排列的请求头, 再写请求体 (长度未知时分块).
+// proxy 非 nil 时请求行用绝对 URI, 并在 caller 未设置时带上代理的 Proxy-Authorization.
+func writeH1Request(w *bufio.Writer, req *http.Request, profile *ClientProfile, proxy *url.URL) error {
+	method := cmp.Or(req.Method, http.MethodGet)
+	target := req.URL.RequestURI()
+	if method == http.MethodConnect && req.URL.Path == "" {
+		target = req.URL.Host
+	} else if proxy != nil {
+		absolute := *req.URL
+		absolute.User, absolute.Fragment, absolute.RawFragment = nil, "", ""
+		target = absolute.String()
+	}
+	fmt.Fprintf(w, "%s %s HTTP/1.1\r\nHost: %s\r\n", method, target, requestHost(req))
+	length, chunked := outgoingLength(req)
+	header := req.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Del("Host")
+	header.Del("Content-Length")
+	header.Del("Transfer-Encoding")
+	if chunked {
+		header.Set("Transfer-Encoding", "chunked")
+	} else if length > 0 || (length == 0 && methodExpectsBody(method)) {
+		header.Set("Content-Length", strconv.FormatInt(length, 10))
+	}
+	if req.Close {
+		header.Set("Connection", "close")
+	}
+	if proxy != nil && proxy.User != nil && header.Get("Proxy-Authorization") == "" {
+		password, _ := proxy.User.Password()
+		header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(proxy.User.Username()+":"+password)))
+	}
+	for _, field := range orderedHeader(header, headerOrderFromContext(req.Context()), profile.HeaderOrder) {
+		fmt.Fprintf(w, "%s: %s\r\n", field.name, field.value)
+	}
+	_, _ = w.WriteString("\r\n")
+	if err := w.Flush(); err != nil {
+		closeRequestBody(req)
+		return err
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	defer req.Body.Close()
+	var dst io.Writer = w
+	var chunkWriter io.WriteCloser
+	if chunked {
+		chunkWriter = httputil.NewChunkedWriter(w)
+		dst = chunkWriter
+	}
+	if _, err := io.Copy(dst, bodyReader{req.Body}); err != nil {
+		return err
+	}
+	if chunkWriter != nil {
+		if err := chunkWriter.Close(); err != nil {
+			return err
+		}
+		_, _ = w.WriteString("\r\n")
+	}
+	return w.Flush()
+}
+
+// bodyReader 给请求体的读取错误打上 errRequestBody.
+type bodyReader struct{ io.Reader }
+
+func (r bodyReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %w", errRequestBody, err)
+	}
+	return n, err
+}
+
+// headerField 是一个待发送的请求头.
+type headerField struct {
+	name, value string
+}
+
+// orderedHeader 按 orders 依次给出的名称 (不区分大小写) 排列 header, 未列出的按名称排序.
+// 同名多值保持原有顺序.
+func orderedHeader(header http.Header, orders ...[]string) []headerField {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) })
+	rank := make(map[string]int)
+	for _, order := range orders {
+		for _, name := range order {
+			name = strings.ToLower(name)
+			if _, ok := rank[name]; !ok {
+				rank[name] = len(rank)
+			}
+		}
+	}
+	slices.SortStableFunc(names, func(a, b string) int {
+		ra, oka := rank[strings.ToLower(a)]
+		rb, okb := rank[strings.ToLower(b)]
+		switch {
+		case oka && okb:
+			return ra - rb
+		case oka:
+			return -1
+		case okb:
+			return 1
+		}
+		return 0
+	})
+	fields := make([]headerField, 0, len(names))
+	for _, name := range names {
+		for _, value := range header[name] {
+			fields = append(fields, headerField{name: name, value: headerValueReplacer.Replace(value)})
+		}
+	}
+	return fields
+}
+
+var headerValueReplacer = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+func validateRequestHeader(header http.Header) error {
+	for name, values := range header {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("fingerprint: invalid header field name %q", name)
+		}
+		for _, value := range values {
+			if !httpguts.ValidHeaderFieldValue(value) {
+				return fmt.Errorf("fingerprint: invalid header field value for %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+// outgoingLength 返回请求体长度, 长度未知时 chunked 为 true (HTTP/1.1 分块发送).
+func outgoingLength(req *http.Request) (length int64, chunked bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return 0, false
+	}
+	if req.ContentLength > 0 {
+		return req.ContentLength, false
+	}
+	return -1, true
+}
+
+func methodExpectsBody(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
+
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// canonicalAddr 返回 URL 的 host:port, 缺省端口按 scheme 补齐.
+func canonicalAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
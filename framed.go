@@ -0,0 +1,243 @@
+package net
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrFrameTooLarge: 读到或要写出的帧超过 FramedConfig.MaxFrameSize.
+var ErrFrameTooLarge = fmt.Errorf("frame too large")
+
+// DefaultMaxFrameSize 是 FramedConn 单帧 (不含长度前缀) 的默认上限, 防异常长度前缀 OOM.
+const DefaultMaxFrameSize = 32 << 20
+
+// FramePrefix 是 FramedConn 的长度前缀格式.
+type FramePrefix int
+
+const (
+	// FramePrefixUvarint 用 unsigned varint (encoding/binary) 编码帧长度.
+	FramePrefixUvarint FramePrefix = iota
+	// FramePrefixUint32 用 4 字节大端编码帧长度.
+	FramePrefixUint32
+)
+
+// Frame 是 FramedConn 的消息信封. ID 非空时用于 Client 的请求-响应匹配 (NotifyMessage),
+// End 标记 Client.Stream 的最后一条 (StreamMessage), Heart 帧由 FramedConn 自行收发.
+type Frame[P any] struct {
+	ID      string `json:"i,omitempty"`
+	Payload P      `json:"p,omitempty"`
+	End     bool   `json:"e,omitempty"`
+	Heart   bool   `json:"h,omitempty"`
+}
+
+// NewRequestFrame 创建带随机 ID 的请求帧, 用于 Client.Request / Client.Stream.
+func NewRequestFrame[P any](payload P) *Frame[P] {
+	return &Frame[P]{ID: uuid.New().String(), Payload: payload}
+}
+
+// Id 实现 NotifyMessage.
+func (frame *Frame[P]) Id() (any, bool) {
+	if frame == nil || frame.ID == "" {
+		return nil, false
+	}
+	return frame.ID, true
+}
+
+// StreamEnd 实现 StreamMessage.
+func (frame *Frame[P]) StreamEnd() bool {
+	return frame != nil && frame.End
+}
+
+// FrameCodec 把信封 (恒为 *Frame[P]) 编码为一帧的字节, 以及反向解析. 实现必须并发安全.
+type FrameCodec interface {
+	Encode(v any) ([]byte, error)
+	Decode(data []byte, v any) error
+}
+
+// JSONFrameCodec 是默认 FrameCodec.
+type JSONFrameCodec struct{}
+
+// Encode 实现 FrameCodec.
+func (JSONFrameCodec) Encode(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Decode 实现 FrameCodec.
+func (JSONFrameCodec) Decode(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// FramedConfig 配置 FramedConn, 零值可用: varint 前缀 + JSON + 无心跳 + 无超时.
+type FramedConfig struct {
+	Prefix       FramePrefix
+	Codec        FrameCodec    // nil 使用 JSONFrameCodec
+	MaxFrameSize int           // <=0 使用 DefaultMaxFrameSize
+	HeartPeriod  time.Duration // >0 时每隔该间隔发送一个 Heart 帧
+	ReadTimeout  time.Duration // >0 时每次 Read 设置读空闲上限, 对端静默超过即断开 (应大于对端心跳间隔)
+	WriteTimeout time.Duration // >0 时每次 Write 设置写超时; ctx 的 deadline 更早时以 ctx 为准
+}
+
+// FramedConn 在任意 net.Conn (TCP / TLS / unix socket) 上实现 Conn[*Frame[P]] 与
+// ConnHeart[*Frame[P]]: 每帧是长度前缀 + codec 编码的 Frame. 交给 Client.Reset 使用,
+// 未匹配到请求的帧 (对端推送或请求) 交给 handler.
+type FramedConn[P any] struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	codec   FrameCodec
+	config  FramedConfig
+	handler func(ctx context.Context, frame *Frame[P])
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewFramedConn 包装 conn. handler 在 Client 的处理协程中调用, 可为 nil (丢弃推送);
+// config 为 nil 使用零值配置.
+func NewFramedConn[P any](conn net.Conn, handler func(ctx context.Context, frame *Frame[P]), config *FramedConfig) *FramedConn[P] {
+	var cfg FramedConfig
+	if config != nil {
+		cfg = *config
+	}
+	if cfg.Codec == nil {
+		cfg.Codec = JSONFrameCodec{}
+	}
+	if cfg.MaxFrameSize <= 0 {
+		cfg.MaxFrameSize = DefaultMaxFrameSize
+	}
+	return &FramedConn[P]{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		codec:   cfg.Codec,
+		config:  cfg,
+		handler: handler,
+	}
+}
+
+// NetConn 返回底层连接.
+func (c *FramedConn[P]) NetConn() net.Conn {
+	return c.conn
+}
+
+// Close 实现 Conn, 幂等.
+func (c *FramedConn[P]) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		c.closeErr = c.conn.Close()
+	})
+	return c.closeErr
+}
+
+// Read 实现 Conn. net.Conn 的读不观察 ctx, 由 Close 解除阻塞.
+func (c *FramedConn[P]) Read(ctx context.Context) (*Frame[P], error) {
+	if c.config.ReadTimeout > 0 {
+		if err := c.conn.SetReadDeadline(time.Now().Add(c.config.ReadTimeout)); err != nil {
+			return nil, err
+		}
+	}
+	size, err := c.readPrefix()
+	if err != nil {
+		return nil, err
+	}
+	if size > uint64(c.config.MaxFrameSize) {
+		return nil, fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, size, c.config.MaxFrameSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return nil, err
+	}
+	var frame Frame[P]
+	if err := c.codec.Decode(data, &frame); err != nil {
+		return nil, err
+	}
+	return &frame, nil
+}
+
+func (c *FramedConn[P]) readPrefix() (uint64, error) {
+	switch c.config.Prefix {
+	case FramePrefixUint32:
+		var prefix [4]byte
+		if _, err := io.ReadFull(c.reader, prefix[:]); err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint32(prefix[:])), nil
+	default:
+		return binary.ReadUvarint(c.reader)
+	}
+}
+
+// Write 实现 Conn, 并发安全: 前缀与帧体在一次 conn.Write 中写出.
+func (c *FramedConn[P]) Write(ctx context.Context, frame *Frame[P]) error {
+	data, err := c.codec.Encode(frame)
+	if err != nil {
+		return err
+	}
+	if len(data) > c.config.MaxFrameSize {
+		return fmt.Errorf("%w: %d > %d", ErrFrameTooLarge, len(data), c.config.MaxFrameSize)
+	}
+	var buf []byte
+	switch c.config.Prefix {
+	case FramePrefixUint32:
+		if uint64(len(data)) > uint64(^uint32(0)) {
+			return fmt.Errorf("%w: %d exceeds uint32 prefix", ErrFrameTooLarge, len(data))
+		}
+		buf = make([]byte, 4, 4+len(data))
+		binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	default:
+		buf = binary.AppendUvarint(make([]byte, 0, binary.MaxVarintLen64+len(data)), uint64(len(data)))
+	}
+	buf = append(buf, data...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	deadline, hasDeadline := ctx.Deadline()
+	if c.config.WriteTimeout > 0 {
+		if timeout := time.Now().Add(c.config.WriteTimeout); !hasDeadline || timeout.Before(deadline) {
+			deadline, hasDeadline = timeout, true
+		}
+	}
+	if hasDeadline {
+		if err := c.conn.SetWriteDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	_, err = c.conn.Write(buf)
+	if hasDeadline {
+		if clearErr := c.conn.SetWriteDeadline(time.Time{}); err == nil && clearErr != nil {
+			return clearErr
+		}
+	}
+	return err
+}
+
+// Heart 实现 ConnHeart. HeartPeriod<=0 时不发心跳, 只返回一个较远的下次检查时间.
+func (c *FramedConn[P]) Heart(connect bool, count uint64) (*Frame[P], time.Time, bool) {
+	if c.config.HeartPeriod <= 0 {
+		return nil, time.Now().Add(60 * time.Second), false
+	}
+	if connect {
+		return nil, time.Now().Add(c.config.HeartPeriod), false
+	}
+	return &Frame[P]{Heart: true}, time.Now().Add(c.config.HeartPeriod), true
+}
+
+// Handle 实现 Conn: 心跳帧直接丢弃, 其余交给 handler.
+func (c *FramedConn[P]) Handle(ctx context.Context, frame *Frame[P]) {
+	if frame == nil || frame.Heart {
+		return
+	}
+	if c.handler == nil {
+		slog.Debug("net FramedConn dropped unmatched frame", slog.String("id", frame.ID))
+		return
+	}
+	c.handler(ctx, frame)
+}
@@ -0,0 +1,79 @@
+package net
+
+import (
+	"context"
+	"errors"
+	gonet "net"
+	"testing"
+	"time"
+)
+
+func TestFramedConnClientRequestOverPipe(t *testing.T) {
+	for _, prefix := range []FramePrefix{FramePrefixUvarint, FramePrefixUint32} {
+		clientSide, serverSide := gonet.Pipe()
+		config := &FramedConfig{Prefix: prefix}
+
+		pushed := make(chan string, 1)
+		client := NewClient[*Frame[string], *FramedConn[string]]()
+		client.Reset(context.Background(), NewFramedConn(clientSide, func(ctx context.Context, frame *Frame[string]) {
+			pushed <- frame.Payload
+		}, config))
+
+		// 对端: 回显请求并先推送一条无 ID 的通知.
+		server := NewFramedConn[string](serverSide, nil, config)
+		go func() {
+			for {
+				req, err := server.Read(context.Background())
+				if err != nil {
+					return
+				}
+				if err := server.Write(context.Background(), &Frame[string]{Payload: "push"}); err != nil {
+					return
+				}
+				if err := server.Write(context.Background(), &Frame[string]{ID: req.ID, Payload: req.Payload + "-resp"}); err != nil {
+					return
+				}
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		resp, err := client.Request(ctx, NewRequestFrame("hello"))
+		if err != nil {
+			t.Fatalf("prefix %d: Request failed: %v", prefix, err)
+		}
+		if resp.Payload != "hello-resp" {
+			t.Fatalf("prefix %d: unexpected response: %#v", prefix, resp)
+		}
+		select {
+		case payload := <-pushed:
+			if payload != "push" {
+				t.Fatalf("prefix %d: unexpected push: %q", prefix, payload)
+			}
+		case <-ctx.Done():
+			t.Fatalf("prefix %d: push was not handled", prefix)
+		}
+		cancel()
+		checkClose(t, "client", client.Close)
+		checkClose(t, "server", func() error { return server.Close(context.Background()) })
+	}
+}
+
+func TestFramedConnRejectsOversizedFrame(t *testing.T) {
+	clientSide, serverSide := gonet.Pipe()
+	defer clientSide.Close()
+	defer serverSide.Close()
+	config := &FramedConfig{Prefix: FramePrefixUint32, MaxFrameSize: 16}
+	writer := NewFramedConn[string](clientSide, nil, config)
+	reader := NewFramedConn[string](serverSide, nil, config)
+
+	if err := writer.Write(context.Background(), &Frame[string]{Payload: "this payload is far too long"}); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("Write err = %v, want ErrFrameTooLarge", err)
+	}
+	go func() {
+		// 伪造一个超长的长度前缀.
+		_, _ = clientSide.Write([]byte{0x00, 0x10, 0x00, 0x00})
+	}()
+	if _, err := reader.Read(context.Background()); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("Read err = %v, want ErrFrameTooLarge", err)
+	}
+}
@@ -6,7 +6,9 @@ require (
 	github.com/andybalholm/brotli v1.2.1
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.18.0
 	github.com/quic-go/quic-go v0.60.0
+	github.com/refraction-networking/utls v1.8.2
 	github.com/zdypro888/utils v0.0.0-20260610033751-607c0b6eab68
 	golang.org/x/net v0.55.0
 	google.golang.org/protobuf v1.36.11
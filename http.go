@@ -2,95 +2,83 @@ package net
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"math/rand/v2"
 	"net"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
-	"github.com/andybalholm/brotli"
 	"github.com/quic-go/quic-go/http3"
 	"github.com/zdypro888/utils"
 	"golang.org/x/net/http2"
 )
 
 // DefaultRetryBackoff 是 HTTP.RequestMethod 默认的 retry 间隔.
-// 指数 + 抖动, 100ms * 2^attempt, capped 5s. attempt 从 0 起计数 (即 0 = 第一次重试).
+// 即 EqualJitterBackoff(200ms, 10s): 第 attempt 次重试前等待 [d/2, d], d = 200ms * 2^attempt,
+// 上限 10s. attempt 从 0 起计数 (即 0 = 第一次重试).
 // RUN-5 修复: 旧实现 retry 之间 0 sleep, 服务端 5xx 风暴时立即捶 N 次.
-// 暴露为变量便于 caller 用 HTTP.ConfigureRetryBackoff 覆盖.
+// 用 HTTP.ConfigureRetryBackoff 可换成其他曲线 (如旧的 ExponentialBackoff(100ms, 5s)).
 func DefaultRetryBackoff(attempt int) time.Duration {
-	if attempt < 0 {
-		attempt = 0
-	}
-	const maxShiftBeforeCap = 6 // 100ms<<6 = 6.4s, the first value capped to 5s.
-	var base time.Duration
-	if attempt >= maxShiftBeforeCap {
-		base = 5 * time.Second
-	} else {
-		base = time.Duration(100*(1<<uint(attempt))) * time.Millisecond
-	}
-	if base > 5*time.Second {
-		base = 5 * time.Second
-	}
-	// 加 0~25% jitter, 防止多 client 同步 retry 雷暴.
-	jitter := time.Duration(rand.Int64N(int64(base / 4)))
-	return base + jitter
+	return defaultRetryBackoff(attempt)
 }
 
+var defaultRetryBackoff = EqualJitterBackoff(200*time.Millisecond, 10*time.Second)
+
 var ErrContextNotContainHTTP = errors.New("context not contain http")
 
 // Response 请求返回
 type Response struct {
 	*http.Response
-	reader io.Reader
+	// MaxBodySize 是 Data / JSON / XML / Text 读 body 的上限, 由 HTTP.ConfigureMaxBodySize 带入.
+	// <= 0 时解码辅助用 DefaultMaxBodySize, Data 不限 (兼容旧行为).
+	MaxBodySize int64
+	reader      io.Reader
+	closers     []func() error // reader 各层解码器的释放函数, Close 时逆序调用
+	// Attempts 按顺序记录 RequestMethod 的每次尝试, 最后一项对应本响应.
+	Attempts []RetryAttempt
 }
 
 func (response *Response) Error() string {
 	return fmt.Sprintf("%s(%d)", response.Status, response.StatusCode)
 }
 
+// Read 读取按 Content-Encoding 解码后的 body, 支持 gzip / deflate / br / zstd 及其叠加.
+// Accept-Encoding 由 RequestMethod 自动补上时 body 已在返回前解码, Content-Encoding 已去掉;
+// caller 自己设置 Accept-Encoding 时 res.Body 为原始字节, 由这里解码.
+// 遇到无法解码的编码返回 ErrUnsupportedContentEncoding.
 func (response *Response) Read(p []byte) (int, error) {
 	if response.Body == nil {
 		return 0, io.EOF
 	}
 	if response.reader == nil {
-		switch response.Header.Get("Content-Encoding") {
-		case "gzip":
-			var err error
-			if response.reader, err = gzip.NewReader(response.Body); err != nil {
-				return 0, err
-			}
-		case "br":
-			response.reader = brotli.NewReader(response.Body)
-		default:
-			response.reader = response.Body
+		reader, closers, err := newContentDecoder(response.Body, response.Header.Get("Content-Encoding"))
+		if err != nil {
+			return 0, err
 		}
+		response.reader, response.closers = reader, closers
 	}
 	return response.reader.Read(p)
 }
 
 func (response *Response) Close() error {
 	if response.Body != nil {
-		var err error
-		if response.reader != nil {
-			if closer, ok := response.reader.(*gzip.Reader); ok {
-				err = closer.Close()
-			}
-		}
+		err := closeDecoders(response.closers)
+		response.closers = nil
 		return errors.Join(err, response.Body.Close())
 	}
 	return nil
 }
 
 func (res *Response) Data() (data []byte, err error) {
+	if res.MaxBodySize > 0 {
+		return res.readLimited(res.MaxBodySize)
+	}
 	defer func() {
 		err = errors.Join(err, res.Close())
 	}()
@@ -132,13 +120,21 @@ func safeErrorForLog(requestURL string, err error) string {
 type HTTP struct {
 	transport http.RoundTripper
 	client    *http.Client
-	// baseDial 是 NewHTTP 创建时的基础拨号函数 (20s 拨号超时, 响应 ctx 取消/deadline).
+	// baseDial 是 NewHTTP 创建时的基础拨号函数 (默认 20s 拨号超时, 响应 ctx 取消/deadline).
 	// ConfigureProxyClear / ConfigureProxyReset(未存代理拨号时) 恢复到它, 保证清除代理后
 	// 拨号超时语义不丢. NewHTTP3 (http3.Transport) 不走 DialContext, 该字段为 nil 且不被使用.
-	baseDial     func(ctx context.Context, network, addr string) (net.Conn, error)
+	baseDial func(ctx context.Context, network, addr string) (net.Conn, error)
+	// routes / router 来自 HTTPOptions.Hosts; 无覆盖时为空, client 直接使用 transport.
+	routes       []*hostRoute
+	router       *hostRouter
+	fingerprint  *fingerprintTransport // ConfigureClientProfile 设置的指纹 transport, nil 未启用
+	cache        *cacheTransport       // ConfigureCache 设置的缓存层, nil 未启用
 	proxyURL     func(*http.Request) (*url.URL, error)
 	proxyDial    func(ctx context.Context, network, addr string) (net.Conn, error)
 	retryBackoff func(attempt int) time.Duration
+	retryPolicy  RetryPolicy
+	middlewares  []Middleware
+	maxBodySize  int64
 	// OnResponse / AutoRetry 保留为导出字段以兼容下游 (iauth/imadrid 直接赋值);
 	// 推荐用 Configure* setter. 必须在调用 Request 前设置好, 之后只读.
 	OnResponse func(ctx context.Context, req *http.Request, res *http.Response, err error) (*http.Response, error, bool)
@@ -150,7 +146,9 @@ func (h *HTTP) ConfigureOnResponse(fn func(ctx context.Context, req *http.Reques
 	h.OnResponse = fn
 }
 
-// ConfigureAutoRetry 设置自动重试次数 (推荐用法).
+// ConfigureAutoRetry 设置总尝试次数 (推荐用法), n > 1 时按 ConfigureRetryPolicy 的策略重试.
+// 默认策略 DefaultRetryPolicy 只重试幂等请求; 旧版对 POST 等也在传输错误时重试,
+// 需要保留该行为时设置 ConfigureRetryPolicy(RetryOnError).
 func (h *HTTP) ConfigureAutoRetry(n int) {
 	h.AutoRetry = n
 }
@@ -179,53 +177,43 @@ func StrictTLSConfig() *tls.Config {
 	}
 }
 
+// NewHTTP 用默认超时创建 HTTP, 等价于 NewHTTPWithOptions(&HTTPOptions{TLSConfig: config}).
 func NewHTTP(config *tls.Config) *HTTP {
-	if config == nil {
-		config = DefaultTLSConfig()
-	}
-	// 基础拨号用 DialContext (而非废弃的 Transport.Dial): 拨号阶段同样响应
-	// ctx 取消与 deadline, 保留原 20s 拨号超时语义.
-	baseDial := (&net.Dialer{
-		Timeout: 20 * time.Second,
-	}).DialContext
-	transport := &http.Transport{
-		DialContext:           baseDial,
-		ResponseHeaderTimeout: 20 * time.Second,
-		ExpectContinueTimeout: 5 * time.Second,
-		TLSHandshakeTimeout:   30 * time.Second,
-		TLSClientConfig:       config,
-	}
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   120 * time.Second,
-	}
-	h := &HTTP{
-		transport: transport,
-		client:    client,
-		baseDial:  baseDial,
-	}
-	return h
+	return NewHTTPWithOptions(&HTTPOptions{TLSConfig: config})
 }
 
 func (h *HTTP) Dispose() {
-	switch transport := h.transport.(type) {
-	case *http.Transport:
-		transport.CloseIdleConnections()
-	case *http3.Transport:
-		if err := transport.Close(); err != nil {
-			slog.Warn("net.HTTP Dispose http3 transport close failed", slog.Any("err", err))
+	if h.fingerprint != nil {
+		h.fingerprint.CloseIdleConnections()
+	}
+	for _, route := range h.routes {
+		if route.fingerprint != nil {
+			route.fingerprint.CloseIdleConnections()
 		}
 	}
+	h.eachTransport(func(transport http.RoundTripper, _ dialFunc) {
+		switch transport := transport.(type) {
+		case *http.Transport:
+			transport.CloseIdleConnections()
+		case *http3.Transport:
+			if err := transport.Close(); err != nil {
+				slog.Warn("net.HTTP Dispose http3 transport close failed", slog.Any("err", err))
+			}
+		}
+	})
 }
 
 func (h *HTTP) ConfigureV2() error {
-	switch transport := h.transport.(type) {
-	case *http.Transport:
-		return http2.ConfigureTransport(transport)
-	case *http3.Transport:
-		return errors.New("quic protocol can not set to http2.0")
-	}
-	return nil
+	var err error
+	h.eachTransport(func(transport http.RoundTripper, _ dialFunc) {
+		switch transport := transport.(type) {
+		case *http.Transport:
+			err = errors.Join(err, http2.ConfigureTransport(transport))
+		case *http3.Transport:
+			err = errors.New("quic protocol can not set to http2.0")
+		}
+	})
+	return err
 }
 
 func (h *HTTP) ConfigureCookie(cookies http.CookieJar) {
@@ -236,13 +224,16 @@ func (h *HTTP) ConfigureProxy(proxy func(*http.Request) (*url.URL, error), store
 	if storeCache {
 		h.proxyURL = proxy
 	}
-	switch transport := h.transport.(type) {
-	case *http.Transport:
-		transport.Proxy = proxy
-	case *http3.Transport:
-		return errors.New("quic protocol can not set proxy")
-	}
-	return nil
+	var err error
+	h.eachTransport(func(transport http.RoundTripper, _ dialFunc) {
+		switch transport := transport.(type) {
+		case *http.Transport:
+			transport.Proxy = proxy
+		case *http3.Transport:
+			err = errors.New("quic protocol can not set proxy")
+		}
+	})
+	return err
 }
 
 func (h *HTTP) ConfigureDebug() error {
@@ -253,45 +244,55 @@ func (h *HTTP) ConfigureProxyDial(dialContext func(ctx context.Context, network,
 	if storeCache {
 		h.proxyDial = dialContext
 	}
-	switch transport := h.transport.(type) {
-	case *http.Transport:
-		if dialContext != nil {
-			transport.DialContext = dialContext
-		} else {
-			// 传 nil 表示去掉代理拨号: 回基础拨号, 不能让 transport 退到无超时的零值 Dialer.
-			transport.DialContext = h.baseDial
+	var err error
+	h.eachTransport(func(transport http.RoundTripper, baseDial dialFunc) {
+		switch transport := transport.(type) {
+		case *http.Transport:
+			if dialContext != nil {
+				transport.DialContext = dialContext
+			} else {
+				// 传 nil 表示去掉代理拨号: 回基础拨号, 不能让 transport 退到无超时的零值 Dialer.
+				transport.DialContext = baseDial
+			}
+		case *http3.Transport:
+			err = errors.New("quic protocol can not set proxy")
 		}
-	case *http3.Transport:
-		return errors.New("quic protocol can not set proxy")
-	}
-	return nil
+	})
+	return err
 }
 
-// ConfigureProxyClear 清除当前代理设置, 拨号恢复到 NewHTTP 的基础拨号 (保留 20s 拨号超时).
+// ConfigureProxyClear 清除当前代理设置, 拨号恢复到创建时的基础拨号 (保留拨号超时, 默认 20s).
 // 不清除 proxyURL/proxyDial 缓存, 之后可用 ConfigureProxyReset 还原.
 func (h *HTTP) ConfigureProxyClear() {
-	switch transport := h.transport.(type) {
-	case *http.Transport:
-		transport.Proxy = nil
-		transport.DialContext = h.baseDial
-	}
+	h.eachTransport(func(transport http.RoundTripper, baseDial dialFunc) {
+		if transport, ok := transport.(*http.Transport); ok {
+			transport.Proxy = nil
+			transport.DialContext = baseDial
+		}
+	})
 }
 
 // ConfigureProxyReset 还原到缓存的代理设置 (ConfigureProxy / ConfigureProxyDial 的
 // storeCache=true 路径). 未缓存代理拨号时回到基础拨号, 与 ConfigureProxyClear 行为一致.
 func (h *HTTP) ConfigureProxyReset() {
-	switch transport := h.transport.(type) {
-	case *http.Transport:
-		transport.Proxy = h.proxyURL
-		if h.proxyDial != nil {
-			transport.DialContext = h.proxyDial
-		} else {
-			transport.DialContext = h.baseDial
+	h.eachTransport(func(transport http.RoundTripper, baseDial dialFunc) {
+		if transport, ok := transport.(*http.Transport); ok {
+			transport.Proxy = h.proxyURL
+			if h.proxyDial != nil {
+				transport.DialContext = h.proxyDial
+			} else {
+				transport.DialContext = baseDial
+			}
 		}
-	}
+	})
 }
 
+// ConfigureTimeout 设置单次往返的总超时. 有 HTTPOptions.Hosts 覆盖时只作用于未覆盖 Timeout 的 host.
 func (h *HTTP) ConfigureTimeout(timeout time.Duration) {
+	if h.router != nil {
+		h.router.timeout = timeout
+		return
+	}
 	h.client.Timeout = timeout
 }
 
@@ -309,8 +310,13 @@ func (h *HTTP) Request(ctx context.Context, url string, headers http.Header, bod
 	return h.RequestMethod(ctx, url, method, headers, body)
 }
 
-func (h *HTTP) requestWithRequest(ctx context.Context, request *http.Request) (*Response, error) {
+// requestWithRequest 发送请求; decode 为 true (Accept-Encoding 由 setAcceptEncoding 补上) 时
+// 在交给 OnResponse 之前解码 body.
+func (h *HTTP) requestWithRequest(ctx context.Context, request *http.Request, decode bool) (*Response, error) {
 	response, err := h.client.Do(request)
+	if err == nil && decode {
+		decodeResponseBody(response)
+	}
 	var closeIdleConn bool
 	if h.OnResponse != nil {
 		response, err, closeIdleConn = h.OnResponse(ctx, request, response, err)
@@ -321,7 +327,7 @@ func (h *HTTP) requestWithRequest(ctx context.Context, request *http.Request) (*
 	if err != nil {
 		return nil, err
 	}
-	return &Response{Response: response}, nil
+	return &Response{Response: response, MaxBodySize: h.maxBodySize}, nil
 }
 
 // requestMethodDo 发送请求
@@ -346,7 +352,13 @@ func (h *HTTP) requestMethodDo(ctx context.Context, url string, method string, h
 	if headers != nil {
 		request.Header = http.Header(headers).Clone()
 	}
-	return h.requestWithRequest(ctx, request)
+	decode := setAcceptEncoding(request)
+	start := time.Now()
+	if response, err = h.requestWithRequest(ctx, request, decode); err != nil {
+		return nil, err
+	}
+	response.Attempts = []RetryAttempt{{StatusCode: response.StatusCode, Duration: time.Since(start)}}
+	return response, nil
 }
 
 func (h *HTTP) RequestMethod(ctx context.Context, url string, method string, headers http.Header, body io.Reader) (response *Response, err error) {
@@ -390,12 +402,18 @@ func (h *HTTP) RequestMethod(ctx context.Context, url string, method string, hea
 	if headers != nil {
 		request.Header = http.Header(headers).Clone()
 	}
+	decode := setAcceptEncoding(request)
 	backoff := h.retryBackoff
 	if backoff == nil {
 		backoff = DefaultRetryBackoff
 	}
+	policy := h.retryPolicy
+	if policy == nil {
+		policy = DefaultRetryPolicy
+	}
 	total := h.AutoRetry
-	for i := total; i > 0; i-- {
+	var attempts []RetryAttempt
+	for attempt := 0; ; attempt++ {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
@@ -406,33 +424,54 @@ func (h *HTTP) RequestMethod(ctx context.Context, url string, method string, hea
 				return breader.Temporary(), nil
 			}
 		}
-		if response, err = h.requestWithRequest(ctx, request); err != nil {
-			// RUN-5: 还有可重试次数才 sleep; 最后一次失败不 sleep 直接返回.
-			if i > 1 {
-				attempt := total - i // 0,1,2...
-				delay := backoff(attempt)
-				timer := time.NewTimer(delay)
-				select {
-				case <-timer.C:
-				case <-ctx.Done():
-					timer.Stop()
-					return nil, ctx.Err()
-				}
-			}
-			continue
-		} else {
+		start := time.Now()
+		response, err = h.requestWithRequest(ctx, request, decode)
+		record := RetryAttempt{Err: err, Duration: time.Since(start)}
+		var res *http.Response
+		if response != nil {
+			res = response.Response
+			record.StatusCode = res.StatusCode
+		}
+		// RUN-5: 还有可重试次数才问策略与 sleep; 最后一次直接返回.
+		if attempt+1 >= total {
+			attempts = append(attempts, record)
+			break
+		}
+		retry, delay := policy(attempt, request, res, err)
+		if !retry {
+			attempts = append(attempts, record)
 			break
 		}
+		if delay <= 0 {
+			delay = backoff(attempt)
+		}
+		record.Delay = delay
+		attempts = append(attempts, record)
+		if response != nil {
+			// 丢弃的响应读掉少量 body 再关闭, 便于连接复用.
+			_, _ = io.CopyN(io.Discard, response.Body, 4<<10)
+			_ = response.Close()
+			response = nil
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
 	}
 	if err != nil {
-		// OPS-2: retry 耗尽才 warn 一次, 避免 retry 中途风暴 log.
+		// OPS-2: retry 耗尽 (或策略放弃) 才 warn 一次, 避免 retry 中途风暴 log.
 		slog.Warn("net.HTTP RequestMethod exhausted retries",
 			slog.String("method", method),
 			slog.String("url", safeURLForLog(url)),
-			slog.Int("retries", total),
+			slog.Int("attempts", len(attempts)),
 			slog.String("err", safeErrorForLog(url, err)))
+		return nil, &RetryError{Attempts: attempts, Err: err}
 	}
-	return response, err
+	response.Attempts = attempts
+	return response, nil
 }
 
 func Request(ctx context.Context, url string, headers http.Header, body io.Reader) (*Response, error) {
@@ -2,26 +2,9 @@ package net
 
 import (
 	"crypto/tls"
-	"net/http"
-	"time"
-
-	"github.com/quic-go/quic-go/http3"
 )
 
+// NewHTTP3 用默认超时创建基于 QUIC 的 HTTP, 等价于 NewHTTP3WithOptions(&HTTPOptions{TLSConfig: config}).
 func NewHTTP3(config *tls.Config) *HTTP {
-	if config == nil {
-		config = DefaultTLSConfig()
-	}
-	transport := &http3.Transport{
-		TLSClientConfig: config,
-	}
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   120 * time.Second,
-	}
-	h := &HTTP{
-		transport: transport,
-		client:    client,
-	}
-	return h
+	return NewHTTP3WithOptions(&HTTPOptions{TLSConfig: config})
 }
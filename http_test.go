@@ -40,8 +40,8 @@ func TestSafeErrorForLogRedactsURLErrorURL(t *testing.T) {
 func TestDefaultRetryBackoffLargeAttemptDoesNotPanic(t *testing.T) {
 	for _, attempt := range []int{6, 60, 1000} {
 		got := DefaultRetryBackoff(attempt)
-		if got < 5*time.Second || got >= 6250*time.Millisecond {
-			t.Fatalf("DefaultRetryBackoff(%d) = %v, want [5s, 6.25s)", attempt, got)
+		if got < 5*time.Second || got > 10*time.Second {
+			t.Fatalf("DefaultRetryBackoff(%d) = %v, want [5s, 10s]", attempt, got)
 		}
 	}
 }
@@ -0,0 +1,303 @@
+package net
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// NewHTTP 的历史默认值, HTTPOptions 对应字段为 0 时使用.
+const (
+	defaultDialTimeout           = 20 * time.Second
+	defaultResponseHeaderTimeout = 20 * time.Second
+	defaultExpectContinueTimeout = 5 * time.Second
+	defaultTLSHandshakeTimeout   = 30 * time.Second
+	defaultClientTimeout         = 120 * time.Second
+)
+
+// HTTPOptions 是 NewHTTPWithOptions / NewHTTP3WithOptions 的配置.
+// 时长字段为 0 时使用 NewHTTP 的历史默认值, 为负表示不设限; 连接数字段为 0 时沿用
+// http.Transport 的零值语义 (MaxIdleConnsPerHost 为 2, 其余不限).
+// NewHTTP3WithOptions 只使用 TLSConfig / Timeout 与 Hosts 中的 TLSConfig / Timeout.
+type HTTPOptions struct {
+	TLSConfig *tls.Config // nil 使用 DefaultTLSConfig
+
+	DialTimeout           time.Duration // 默认 20s
+	ResponseHeaderTimeout time.Duration // 默认 20s
+	ExpectContinueTimeout time.Duration // 默认 5s
+	TLSHandshakeTimeout   time.Duration // 默认 30s
+	IdleConnTimeout       time.Duration // 默认不限
+	Timeout               time.Duration // 单次往返 (含读 body) 的总超时, 默认 120s
+
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+
+	// Hosts 按请求 hostname 覆盖上面的设置, 按顺序取第一个匹配项.
+	Hosts []HostOptions
+}
+
+// HostOptions 是按 hostname 的覆盖设置, 零值字段沿用 HTTPOptions.
+// Pattern 按 path.Match 匹配 (不区分大小写, 不含端口): "api.example.com" 精确匹配,
+// "*.example.com" 匹配其子域名但不含 example.com 本身.
+// 只覆盖 Timeout 的 host 与默认配置共用连接池; 覆盖其余字段的 host 使用独立的连接池,
+// 其中 MaxConnsPerHost / MaxIdleConnsPerHost 对每个匹配到的 host 分别生效.
+type HostOptions struct {
+	Pattern   string
+	TLSConfig *tls.Config
+
+	DialTimeout           time.Duration
+	ResponseHeaderTimeout time.Duration
+	TLSHandshakeTimeout   time.Duration
+	Timeout               time.Duration
+
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+}
+
+// ownsTransport 报告该覆盖是否需要独立的 transport (而非只改超时).
+func (options *HostOptions) ownsTransport() bool {
+	return options.TLSConfig != nil || options.DialTimeout != 0 || options.ResponseHeaderTimeout != 0 ||
+		options.TLSHandshakeTimeout != 0 || options.MaxIdleConnsPerHost != 0 || options.MaxConnsPerHost != 0
+}
+
+// optionDuration 把 HTTPOptions 的时长换算为 transport 字段: 0 取 def, 负值为不设限.
+func optionDuration(value, def time.Duration) time.Duration {
+	if value == 0 {
+		return def
+	}
+	if value < 0 {
+		return 0
+	}
+	return value
+}
+
+// NewHTTPWithOptions 按 options 创建 HTTP; options 为 nil 与 NewHTTP(nil) 等价.
+func NewHTTPWithOptions(options *HTTPOptions) *HTTP {
+	if options == nil {
+		options = &HTTPOptions{}
+	}
+	config := options.TLSConfig
+	if config == nil {
+		config = DefaultTLSConfig()
+	}
+	// 基础拨号用 DialContext (而非废弃的 Transport.Dial): 拨号阶段同样响应
+	// ctx 取消与 deadline, 默认保留原 20s 拨号超时语义.
+	baseDial := (&net.Dialer{
+		Timeout: optionDuration(options.DialTimeout, defaultDialTimeout),
+	}).DialContext
+	transport := &http.Transport{
+		DialContext:           baseDial,
+		ResponseHeaderTimeout: optionDuration(options.ResponseHeaderTimeout, defaultResponseHeaderTimeout),
+		ExpectContinueTimeout: optionDuration(options.ExpectContinueTimeout, defaultExpectContinueTimeout),
+		TLSHandshakeTimeout:   optionDuration(options.TLSHandshakeTimeout, defaultTLSHandshakeTimeout),
+		IdleConnTimeout:       optionDuration(options.IdleConnTimeout, 0),
+		MaxIdleConns:          options.MaxIdleConns,
+		MaxIdleConnsPerHost:   options.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       options.MaxConnsPerHost,
+		TLSClientConfig:       config,
+	}
+	h := &HTTP{
+		transport: transport,
+		client:    &http.Client{Transport: transport},
+		baseDial:  baseDial,
+	}
+	for _, host := range options.Hosts {
+		route := &hostRoute{
+			pattern: strings.ToLower(host.Pattern),
+			timeout: optionDuration(host.Timeout, 0),
+		}
+		if host.ownsTransport() {
+			owned := transport.Clone()
+			route.baseDial = baseDial
+			if host.DialTimeout != 0 {
+				route.baseDial = (&net.Dialer{Timeout: optionDuration(host.DialTimeout, 0)}).DialContext
+				owned.DialContext = route.baseDial
+			}
+			owned.ResponseHeaderTimeout = optionDuration(host.ResponseHeaderTimeout, owned.ResponseHeaderTimeout)
+			owned.TLSHandshakeTimeout = optionDuration(host.TLSHandshakeTimeout, owned.TLSHandshakeTimeout)
+			if host.MaxIdleConnsPerHost != 0 {
+				owned.MaxIdleConnsPerHost = host.MaxIdleConnsPerHost
+			}
+			if host.MaxConnsPerHost != 0 {
+				owned.MaxConnsPerHost = host.MaxConnsPerHost
+			}
+			if host.TLSConfig != nil {
+				owned.TLSClientConfig = host.TLSConfig
+			}
+			route.transport = owned
+		}
+		h.routes = append(h.routes, route)
+	}
+	h.configureClientTimeout(optionDuration(options.Timeout, defaultClientTimeout))
+	return h
+}
+
+// NewHTTP3WithOptions 按 options 创建基于 QUIC 的 HTTP; options 为 nil 与 NewHTTP3(nil) 等价.
+// 只使用 TLSConfig / Timeout 与 Hosts 中的 TLSConfig / Timeout, 其余字段对 QUIC 无意义.
+func NewHTTP3WithOptions(options *HTTPOptions) *HTTP {
+	if options == nil {
+		options = &HTTPOptions{}
+	}
+	config := options.TLSConfig
+	if config == nil {
+		config = DefaultTLSConfig()
+	}
+	transport := &http3.Transport{
+		TLSClientConfig: config,
+	}
+	h := &HTTP{
+		transport: transport,
+		client:    &http.Client{Transport: transport},
+	}
+	for _, host := range options.Hosts {
+		route := &hostRoute{
+			pattern: strings.ToLower(host.Pattern),
+			timeout: optionDuration(host.Timeout, 0),
+		}
+		if host.TLSConfig != nil {
+			route.transport = &http3.Transport{TLSClientConfig: host.TLSConfig}
+		}
+		h.routes = append(h.routes, route)
+	}
+	h.configureClientTimeout(optionDuration(options.Timeout, defaultClientTimeout))
+	return h
+}
+
+// configureClientTimeout 在 NewHTTP*WithOptions 末尾设置超时与 client.Transport.
+// 没有按 host 覆盖时沿用 http.Client.Timeout; 有覆盖时由 hostRouter 按 host 施加超时,
+// client.Timeout 置 0 以免更长的 host 超时被全局值截断.
+func (h *HTTP) configureClientTimeout(timeout time.Duration) {
+	if len(h.routes) == 0 {
+		h.client.Timeout = timeout
+		return
+	}
+	h.router = &hostRouter{routes: h.routes, timeout: timeout}
+	h.applyTransport()
+}
+
+// applyTransport 由内向外组装 client.Transport: 基础 transport (或 ConfigureClientProfile 的
+// 指纹 transport) → 按 host 的路由 → ConfigureCache 的缓存 → AddMiddleware 的中间件链.
+func (h *HTTP) applyTransport() {
+	var base http.RoundTripper = h.transport
+	if h.fingerprint != nil {
+		base = h.fingerprint
+	}
+	if h.router != nil {
+		h.router.base = base
+		base = h.router
+	}
+	if h.cache != nil {
+		h.cache.next = base
+		base = h.cache
+	}
+	if len(h.middlewares) == 0 {
+		h.client.Transport = base
+		return
+	}
+	chain := base
+	for i := len(h.middlewares) - 1; i >= 0; i-- {
+		chain = h.middlewares[i](chain)
+	}
+	h.client.Transport = &middlewareTransport{chain: chain, base: base}
+}
+
+// eachTransport 对基础 transport 与各 host 独立的 transport 依次调用 fn, 附带其基础拨号.
+func (h *HTTP) eachTransport(fn func(transport http.RoundTripper, baseDial dialFunc)) {
+	fn(h.transport, h.baseDial)
+	for _, route := range h.routes {
+		if route.transport != nil {
+			fn(route.transport, route.baseDial)
+		}
+	}
+}
+
+type dialFunc = func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// hostRoute 是一条 HostOptions 生效后的路由.
+type hostRoute struct {
+	pattern     string
+	timeout     time.Duration         // 0 沿用 hostRouter.timeout
+	transport   http.RoundTripper     // 独立的 transport, nil 时与默认配置共用 hostRouter.base
+	baseDial    dialFunc              // 独立 transport 的基础拨号, ConfigureProxyClear 时恢复
+	fingerprint *fingerprintTransport // ConfigureClientProfile 基于 transport 建的指纹 transport
+}
+
+// hostRouter 按 hostname 选择 transport 并施加超时.
+type hostRouter struct {
+	base    http.RoundTripper
+	routes  []*hostRoute
+	timeout time.Duration // 未覆盖 Timeout 的请求的超时, 0 不设
+}
+
+func (r *hostRouter) match(hostname string) *hostRoute {
+	hostname = strings.ToLower(hostname)
+	for _, route := range r.routes {
+		if ok, _ := path.Match(route.pattern, hostname); ok {
+			return route
+		}
+	}
+	return nil
+}
+
+func (r *hostRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, timeout := r.base, r.timeout
+	if route := r.match(req.URL.Hostname()); route != nil {
+		if route.fingerprint != nil {
+			transport = route.fingerprint
+		} else if route.transport != nil {
+			transport = route.transport
+		}
+		if route.timeout > 0 {
+			timeout = route.timeout
+		}
+	}
+	if timeout <= 0 {
+		return transport.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	res, err := transport.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// 超时覆盖读 body 阶段 (与 http.Client.Timeout 一致), body 关闭时释放.
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+func (r *hostRouter) CloseIdleConnections() {
+	closeIdle(r.base)
+	for _, route := range r.routes {
+		if route.transport != nil {
+			closeIdle(route.transport)
+		}
+		if route.fingerprint != nil {
+			route.fingerprint.CloseIdleConnections()
+		}
+	}
+}
+
+func closeIdle(transport http.RoundTripper) {
+	if closer, ok := transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
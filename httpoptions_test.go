@@ -0,0 +1,83 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	stdurl "net/url"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPWithOptionsAppliesSettings(t *testing.T) {
+	h := NewHTTPWithOptions(&HTTPOptions{
+		ResponseHeaderTimeout: 3 * time.Second,
+		TLSHandshakeTimeout:   -1,
+		MaxConnsPerHost:       8,
+		Timeout:               time.Minute,
+		Hosts: []HostOptions{
+			{Pattern: "*.slow.example", MaxConnsPerHost: 2, ResponseHeaderTimeout: time.Minute},
+			{Pattern: "fast.example", Timeout: time.Second},
+		},
+	})
+	defer h.Dispose()
+
+	base := h.transport.(*http.Transport)
+	if base.ResponseHeaderTimeout != 3*time.Second || base.TLSHandshakeTimeout != 0 || base.MaxConnsPerHost != 8 {
+		t.Fatalf("base transport = %v / %v / %d", base.ResponseHeaderTimeout, base.TLSHandshakeTimeout, base.MaxConnsPerHost)
+	}
+	if h.client.Timeout != 0 || h.router == nil || h.router.timeout != time.Minute {
+		t.Fatalf("host overrides should move the timeout into the router")
+	}
+	slow := h.router.match("API.slow.example")
+	if slow == nil || slow.transport == nil {
+		t.Fatal("*.slow.example should route to its own transport")
+	}
+	if owned := slow.transport.(*http.Transport); owned.MaxConnsPerHost != 2 || owned.ResponseHeaderTimeout != time.Minute {
+		t.Fatalf("slow transport = %d / %v", owned.MaxConnsPerHost, owned.ResponseHeaderTimeout)
+	}
+	if fast := h.router.match("fast.example"); fast == nil || fast.transport != nil || fast.timeout != time.Second {
+		t.Fatal("fast.example should share the base transport with a 1s timeout")
+	}
+	if h.router.match("slow.example") != nil {
+		t.Fatal("*.slow.example must not match the apex domain")
+	}
+
+	proxy := func(*http.Request) (*stdurl.URL, error) { return nil, nil }
+	if err := h.ConfigureProxy(proxy, true); err != nil {
+		t.Fatalf("ConfigureProxy failed: %v", err)
+	}
+	if slow.transport.(*http.Transport).Proxy == nil {
+		t.Fatal("ConfigureProxy did not reach the per-host transport")
+	}
+	h.ConfigureProxyClear()
+	if owned := slow.transport.(*http.Transport); owned.Proxy != nil || owned.DialContext == nil {
+		t.Fatal("ConfigureProxyClear did not restore the per-host transport")
+	}
+}
+
+func TestHTTPPerHostTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	h := NewHTTPWithOptions(&HTTPOptions{
+		Hosts: []HostOptions{{Pattern: "127.0.0.1", Timeout: 50 * time.Millisecond}},
+	})
+	defer h.Dispose()
+
+	_, err := h.Request(context.Background(), server.URL, nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+
+	h.ConfigureTimeout(time.Millisecond) // 只作用于未覆盖的 host
+	serverURL, _ := stdurl.Parse(server.URL)
+	res, err := h.Request(context.Background(), "http://localhost:"+serverURL.Port(), nil, nil)
+	if err == nil {
+		_ = res.Close()
+		t.Fatal("default timeout not applied to non-matching host")
+	}
+}
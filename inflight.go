@@ -0,0 +1,92 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrTooManyInFlight: InFlightFailFast 模式下等待响应的请求数已达 SetMaxInFlight 上限.
+var ErrTooManyInFlight = fmt.Errorf("too many in-flight requests")
+
+// InFlightMode 决定达到 MaxInFlight 上限时新请求的行为.
+type InFlightMode int
+
+const (
+	// InFlightBlock 阻塞等待空位, 直到 ctx 结束.
+	InFlightBlock InFlightMode = iota
+	// InFlightFailFast 立即返回 ErrTooManyInFlight.
+	InFlightFailFast
+)
+
+// inflightLimiter 是 SetMaxInFlight 的配置快照; sem 容量即上限.
+type inflightLimiter struct {
+	sem  chan struct{}
+	mode InFlightMode
+}
+
+// inflightSlot 是一个等待响应请求占用的名额. release 幂等: Response / caller ctx 取消 /
+// 入队失败 / Stream 结束都可能调用, 只有第一次生效.
+type inflightSlot struct {
+	sem      chan struct{} // nil 表示未限流, 只计数
+	count    *atomic.Int64
+	drained  *drainSignal // 计数归零时唤醒 Shutdown
+	released atomic.Bool
+}
+
+func (slot *inflightSlot) release() {
+	if slot == nil || !slot.released.CompareAndSwap(false, true) {
+		return
+	}
+	if slot.count.Add(-1) == 0 {
+		slot.drained.notify()
+	}
+	if slot.sem != nil {
+		<-slot.sem
+	}
+}
+
+// SetMaxInFlight 限制同时等待响应的请求数 (Request / RequestUnsafe / RequestCallbackUnsafe /
+// Stream), Write 不计入. 名额在收到响应、连接断开、caller ctx 结束或 Stream 结束时释放.
+// 达到上限时按 mode 阻塞或返回 ErrTooManyInFlight. n <= 0 取消限制.
+// *注意* InFlightBlock 下 RequestCallbackUnsafe 会阻塞其 single owner goroutine
+// (例如 wsc.Session.asyncGo), 这类调用方应使用 InFlightFailFast.
+// 建议在 Reset 前调用; 修改前已占用的名额仍归还到旧限额, 切换瞬间总数可能短暂超过新上限.
+func (client *Client[M, T]) SetMaxInFlight(n int, mode InFlightMode) {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	if n <= 0 {
+		client.inflightLimit.Store(nil)
+		return
+	}
+	client.inflightLimit.Store(&inflightLimiter{sem: make(chan struct{}, n), mode: mode})
+}
+
+// acquireInFlight 为一个等待响应的请求占用名额. 不持锁调用: InFlightBlock 可能阻塞到
+// 其它请求完成, 持 RLock 阻塞会拖住并发的 Close.
+func (client *Client[M, T]) acquireInFlight(ctx context.Context) (*inflightSlot, error) {
+	slot := &inflightSlot{count: &client.inflight, drained: &client.drained}
+	if limiter := client.inflightLimit.Load(); limiter != nil {
+		select {
+		case limiter.sem <- struct{}{}:
+		default:
+			if limiter.mode == InFlightFailFast {
+				return nil, ErrTooManyInFlight
+			}
+			select {
+			case limiter.sem <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		slot.sem = limiter.sem
+	}
+	current := client.inflight.Add(1)
+	for {
+		peak := client.inflightPeak.Load()
+		if current <= peak || client.inflightPeak.CompareAndSwap(peak, current) {
+			break
+		}
+	}
+	return slot, nil
+}
@@ -0,0 +1,84 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClientMaxInFlightFailFast(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.SetMaxInFlight(1, InFlightFailFast)
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.Request(ctx, testMessage{id: "req-1", value: "request"})
+		errCh <- err
+	}()
+	req := <-conn.writeCh
+
+	if _, err := client.Request(ctx, testMessage{id: "req-2", value: "request"}); !errors.Is(err, ErrTooManyInFlight) {
+		t.Fatalf("second Request err = %v, want ErrTooManyInFlight", err)
+	}
+	if stats := client.Stats(); stats.InFlight != 1 || stats.InFlightPeak != 1 {
+		t.Fatalf("InFlight = %d, InFlightPeak = %d, want 1/1", stats.InFlight, stats.InFlightPeak)
+	}
+
+	conn.readCh <- testMessage{id: req.id, value: "response"}
+	if err := <-errCh; err != nil {
+		t.Fatalf("first Request failed: %v", err)
+	}
+	if stats := client.Stats(); stats.InFlight != 0 {
+		t.Fatalf("InFlight after response = %d, want 0", stats.InFlight)
+	}
+}
+
+func TestClientMaxInFlightBlockWaitsForSlot(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.SetMaxInFlight(1, InFlightBlock)
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	// 第一个请求 caller 取消后名额立即归还, 即便对端永远不响应.
+	cancelCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := client.Request(cancelCtx, testMessage{id: "req-1", value: "request"})
+		firstErr <- err
+	}()
+	<-conn.writeCh
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	secondErr := make(chan error, 1)
+	go func() {
+		_, err := client.Request(ctx, testMessage{id: "req-2", value: "request"})
+		secondErr <- err
+	}()
+	select {
+	case req := <-conn.writeCh:
+		t.Fatalf("request %q sent while limit reached", req.id)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancelFirst()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("first Request err = %v, want context.Canceled", err)
+	}
+	select {
+	case req := <-conn.writeCh:
+		conn.readCh <- testMessage{id: req.id, value: "response"}
+	case <-ctx.Done():
+		t.Fatal("blocked request was not sent after slot freed")
+	}
+	if err := <-secondErr; err != nil {
+		t.Fatalf("second Request failed: %v", err)
+	}
+}
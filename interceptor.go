@@ -0,0 +1,170 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrInvokerUnavailable: RequestCallbackUnsafe 路径上拦截器在链返回之后 (或第二次)
+// 调用 next. 该路径的入队必须交回 caller goroutine 完成, caller 离开后无法再入队.
+var ErrInvokerUnavailable = fmt.Errorf("interceptor invoker unavailable")
+
+// UnaryInvoker 是拦截器链中的下一步; 最内层是真正的入队 + 等待响应.
+type UnaryInvoker[M any] func(ctx context.Context, req M) (M, error)
+
+// UnaryInterceptor 包裹 Request / RequestUnsafe / RequestCallbackUnsafe.
+// 可以改写 ctx / req 后调用 next, 检查或改写 next 的结果, 也可以不调用 next 直接返回 (短路).
+type UnaryInterceptor[M any] func(ctx context.Context, req M, next UnaryInvoker[M]) (M, error)
+
+// WriteInvoker 是写拦截器链中的下一步; 最内层是真正的入队.
+type WriteInvoker[M any] func(ctx context.Context, msg M) error
+
+// WriteInterceptor 包裹 Write / WriteUnsafe, 语义同 UnaryInterceptor (无响应).
+type WriteInterceptor[M any] func(ctx context.Context, msg M, next WriteInvoker[M]) error
+
+// AddUnaryInterceptor 追加请求拦截器. 先注册的在外层 (最先看到请求, 最后看到结果).
+// 拦截器在调用方 goroutine 中执行 (RequestCallbackUnsafe 例外, 见该方法), 不持有 Client 锁.
+// Stream 不经过拦截器.
+// 线程安全, 可在任意时刻调用; 对已进入链的调用不生效.
+func (client *Client[M, T]) AddUnaryInterceptor(interceptors ...UnaryInterceptor[M]) {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	var chain []UnaryInterceptor[M]
+	if current := client.unaryInterceptors.Load(); current != nil {
+		chain = append(chain, *current...)
+	}
+	chain = append(chain, interceptors...)
+	client.unaryInterceptors.Store(&chain)
+}
+
+// AddWriteInterceptor 追加写拦截器, 顺序与并发语义同 AddUnaryInterceptor.
+func (client *Client[M, T]) AddWriteInterceptor(interceptors ...WriteInterceptor[M]) {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	var chain []WriteInterceptor[M]
+	if current := client.writeInterceptors.Load(); current != nil {
+		chain = append(chain, *current...)
+	}
+	chain = append(chain, interceptors...)
+	client.writeInterceptors.Store(&chain)
+}
+
+// chainUnary 把拦截器按注册顺序套在 final 外层.
+func chainUnary[M any](interceptors []UnaryInterceptor[M], final UnaryInvoker[M]) UnaryInvoker[M] {
+	invoker := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, req M) (M, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return invoker
+}
+
+func chainWrite[M any](interceptors []WriteInterceptor[M], final WriteInvoker[M]) WriteInvoker[M] {
+	invoker := final
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, msg M) error {
+			return interceptor(ctx, msg, next)
+		}
+	}
+	return invoker
+}
+
+// interceptUnary 用当前注册的拦截器执行 final; 无拦截器时直接调用.
+func (client *Client[M, T]) interceptUnary(ctx context.Context, data M, final UnaryInvoker[M]) (M, error) {
+	if done := client.observeRequest(); done != nil {
+		resp, err := client.interceptUnaryChain(ctx, data, final)
+		done(err)
+		return resp, err
+	}
+	return client.interceptUnaryChain(ctx, data, final)
+}
+
+func (client *Client[M, T]) interceptUnaryChain(ctx context.Context, data M, final UnaryInvoker[M]) (M, error) {
+	interceptors := client.unaryInterceptors.Load()
+	if interceptors == nil {
+		return final(ctx, data)
+	}
+	return chainUnary(*interceptors, final)(ctx, data)
+}
+
+func (client *Client[M, T]) interceptWrite(ctx context.Context, data M, final WriteInvoker[M]) error {
+	interceptors := client.writeInterceptors.Load()
+	if interceptors == nil {
+		return final(ctx, data)
+	}
+	return chainWrite(*interceptors, final)(ctx, data)
+}
+
+// interceptedEnqueue 是链最内层交回 caller goroutine 的入队请求.
+type interceptedEnqueue[M any] struct {
+	ctx    context.Context
+	req    M
+	result chan interceptedEnqueueResult[M]
+}
+
+type interceptedEnqueueResult[M any] struct {
+	message *asyncMessage[M]
+	err     error
+}
+
+// requestCallbackIntercepted 是有拦截器时的 RequestCallbackUnsafe.
+// 结果要等响应才有, 所以链在独立 goroutine 中执行; 但 *Unsafe 的入队只能由 single owner
+// (caller) 完成: 最内层 invoker 把 (ctx, req) 交回 caller 入队, 之后在链 goroutine 中
+// 等待响应. caller 阻塞到入队完成、链短路返回或 ctx 结束. callback 收到链的最终结果.
+func (client *Client[M, T]) requestCallbackIntercepted(ctx context.Context, data M, callback func(resp M, err error), interceptors []UnaryInterceptor[M]) error {
+	handoff := make(chan interceptedEnqueue[M])
+	abandoned := make(chan struct{})
+	chainDone := make(chan struct{})
+	var used atomic.Bool
+	var chainErr error
+	final := func(ctx context.Context, req M) (M, error) {
+		var zeroM M
+		if !used.CompareAndSwap(false, true) {
+			return zeroM, ErrInvokerUnavailable
+		}
+		call := interceptedEnqueue[M]{ctx: ctx, req: req, result: make(chan interceptedEnqueueResult[M], 1)}
+		select {
+		case handoff <- call:
+		case <-abandoned:
+			return zeroM, ErrInvokerUnavailable
+		}
+		result := <-call.result
+		if result.err != nil {
+			return zeroM, result.err
+		}
+		// 与无拦截器的回调路径一致: 入队后不再观察 ctx, 只等 Response (teardown 时必定 close).
+		resp, ok := <-result.message.waiter
+		if !ok {
+			return zeroM, ErrConnectionClosed
+		}
+		return resp.Response, resp.Error
+	}
+	go func() {
+		resp, err := chainUnary(interceptors, final)(ctx, data)
+		chainErr = err
+		close(chainDone)
+		if callback != nil {
+			callback(resp, err)
+		}
+	}()
+	select {
+	case call := <-handoff:
+		slot, err := client.acquireInFlight(call.ctx)
+		var message *asyncMessage[M]
+		if err == nil {
+			message, err = client.asyncMessage(call.ctx, call.req, true, slot)
+		}
+		call.result <- interceptedEnqueueResult[M]{message: message, err: err}
+		return err
+	case <-chainDone:
+		// 拦截器短路 (未调用 next).
+		return chainErr
+	case <-ctx.Done():
+		close(abandoned)
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,150 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// echoFakeConn 把写出的请求原样加上 "-resp" 作为响应读回.
+func echoFakeConn(conn *fakeConn) {
+	go func() {
+		for {
+			select {
+			case req := <-conn.writeCh:
+				if req.id == "" {
+					continue
+				}
+				conn.readCh <- testMessage{id: req.id, value: req.value + "-resp"}
+			case <-conn.closed:
+				return
+			}
+		}
+	}()
+}
+
+func TestClientUnaryInterceptorsOrderAndRewrite(t *testing.T) {
+	conn := newFakeConn()
+	echoFakeConn(conn)
+	client := NewClient[testMessage, *fakeConn]()
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	var order []string
+	client.AddUnaryInterceptor(
+		func(ctx context.Context, req testMessage, next UnaryInvoker[testMessage]) (testMessage, error) {
+			order = append(order, "outer-before")
+			req.value += "+auth"
+			resp, err := next(ctx, req)
+			order = append(order, "outer-after")
+			return resp, err
+		},
+		func(ctx context.Context, req testMessage, next UnaryInvoker[testMessage]) (testMessage, error) {
+			order = append(order, "inner-before")
+			resp, err := next(ctx, req)
+			order = append(order, "inner-after")
+			resp.value += "+seen"
+			return resp, err
+		},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := client.Request(ctx, testMessage{id: "req-1", value: "request"})
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp.value != "request+auth-resp+seen" {
+		t.Fatalf("unexpected response: %#v", resp)
+	}
+	want := []string{"outer-before", "inner-before", "inner-after", "outer-after"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestClientInterceptorsShortCircuit(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	errInvalid := errors.New("invalid payload")
+	client.AddUnaryInterceptor(func(ctx context.Context, req testMessage, next UnaryInvoker[testMessage]) (testMessage, error) {
+		if req.value == "" {
+			return testMessage{}, errInvalid
+		}
+		return next(ctx, req)
+	})
+	client.AddWriteInterceptor(func(ctx context.Context, msg testMessage, next WriteInvoker[testMessage]) error {
+		if msg.value == "" {
+			return errInvalid
+		}
+		return next(ctx, msg)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := client.Request(ctx, testMessage{id: "req-empty"}); !errors.Is(err, errInvalid) {
+		t.Fatalf("Request err = %v, want errInvalid", err)
+	}
+	if err := client.Write(ctx, testMessage{}); !errors.Is(err, errInvalid) {
+		t.Fatalf("Write err = %v, want errInvalid", err)
+	}
+	if err := client.Write(ctx, testMessage{value: "notify"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	select {
+	case msg := <-conn.writeCh:
+		if msg.value != "notify" {
+			t.Fatalf("unexpected write: %#v", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("valid write was not sent")
+	}
+	select {
+	case msg := <-conn.writeCh:
+		t.Fatalf("short-circuited message was sent: %#v", msg)
+	default:
+	}
+}
+
+func TestClientRequestCallbackUnsafeRunsInterceptors(t *testing.T) {
+	conn := newFakeConn()
+	echoFakeConn(conn)
+	client := NewClient[testMessage, *fakeConn]()
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	client.AddUnaryInterceptor(func(ctx context.Context, req testMessage, next UnaryInvoker[testMessage]) (testMessage, error) {
+		req.value = "rewritten"
+		resp, err := next(ctx, req)
+		resp.value += "+seen"
+		return resp, err
+	})
+
+	done := make(chan testMessage, 1)
+	err := client.RequestCallbackUnsafe(context.Background(), testMessage{id: "cb-1", value: "request"}, func(resp testMessage, err error) {
+		if err != nil {
+			t.Errorf("callback err: %v", err)
+		}
+		done <- resp
+	})
+	if err != nil {
+		t.Fatalf("RequestCallbackUnsafe failed: %v", err)
+	}
+	select {
+	case resp := <-done:
+		if resp.value != "rewritten-resp+seen" {
+			t.Fatalf("unexpected response: %#v", resp)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("callback not called")
+	}
+}
@@ -0,0 +1,113 @@
+package jsonrpc
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/zdypro888/net"
+)
+
+// Client 是基于 net.Client 的 JSON-RPC 客户端, 内嵌 net.Client 以复用 Reset / Close /
+// Supervise / 拦截器等能力. 对端发来的请求与通知由 T 的 Handle 处理 (见 Server.Serve).
+type Client[T net.Conn[*Message]] struct {
+	*net.Client[*Message, T]
+	ids atomic.Uint64
+}
+
+// NewClient 创建客户端, 需再调用 Reset (或 Supervise) 绑定连接.
+func NewClient[T net.Conn[*Message]]() *Client[T] {
+	return &Client[T]{Client: net.NewClient[*Message, T]()}
+}
+
+// Call 调用 method 并把结果解到 result (可为 nil). 对端返回错误对象时返回 *Error.
+func (c *Client[T]) Call(ctx context.Context, method string, params any, result any) error {
+	req, err := c.newRequest(method, params)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Request(ctx, req)
+	if err != nil {
+		return err
+	}
+	return resp.decodeResult(result)
+}
+
+// Notify 发送通知, 不等待响应.
+func (c *Client[T]) Notify(ctx context.Context, method string, params any) error {
+	msg, err := NewNotification(method, params)
+	if err != nil {
+		return err
+	}
+	return c.Write(ctx, msg)
+}
+
+// BatchElem 是 Batch 中的一项. Notify 为 true 时按通知发送, 不回填 Result / Error.
+type BatchElem struct {
+	Method string
+	Params any
+	Result any   // 调用结果解到这里, 可为 nil
+	Notify bool  // 按通知发送
+	Error  error // 调用完成后回填: *Error / ErrMissingResponse / 解码错误
+}
+
+// Batch 以一条批量消息发送 elems, 等待响应数组后按 id 回填各项的 Result / Error.
+// 响应只要含有其中一个 id 即算完成, 响应中缺少的项 (含对端以 id 为 null 的错误对象回应的项)
+// 回填 ErrMissingResponse. 返回值只表示批量本身是否完成 (发送失败、ctx 结束); 单项错误见 Error.
+// 全部是通知时只发送不等待. 对端只用单个 id 为 null 的错误对象拒绝整个批量时无法匹配,
+// 调用会等到 ctx 结束.
+func (c *Client[T]) Batch(ctx context.Context, elems []*BatchElem) error {
+	msgs := make([]*Message, len(elems))
+	ids := make(map[string]*BatchElem, len(elems))
+	for i, elem := range elems {
+		var msg *Message
+		var err error
+		if elem.Notify {
+			msg, err = NewNotification(elem.Method, elem.Params)
+		} else {
+			msg, err = c.newRequest(elem.Method, elem.Params)
+		}
+		if err != nil {
+			return err
+		}
+		if id, ok := msg.matchID(); ok {
+			ids[id] = elem
+		}
+		msgs[i] = msg
+	}
+	batch := NewBatch(msgs...)
+	if len(ids) == 0 {
+		return c.Write(ctx, batch)
+	}
+	resp, err := c.Request(ctx, batch)
+	if err != nil {
+		return err
+	}
+	items := resp.Batch
+	if items == nil {
+		// 对端对批量只回了单条响应.
+		items = []*Message{resp}
+	}
+	for _, item := range items {
+		id, ok := item.matchID()
+		if !ok {
+			continue
+		}
+		if elem, ok := ids[id]; ok {
+			elem.Error = item.decodeResult(elem.Result)
+			delete(ids, id)
+		}
+	}
+	for _, elem := range ids {
+		elem.Error = ErrMissingResponse
+	}
+	return nil
+}
+
+func (c *Client[T]) newRequest(method string, params any) (*Message, error) {
+	msg, err := NewNotification(method, params)
+	if err != nil {
+		return nil, err
+	}
+	msg.ID = numericID(c.ids.Add(1))
+	return msg, nil
+}
@@ -0,0 +1,152 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// handleIncoming 是 StreamConn / WebSocketConn 共用的 Handle: 请求与通知交给 handler,
+// 未匹配的响应 (调用方已离开 / id 为 null 的错误) 只记日志.
+func handleIncoming(ctx context.Context, msg *Message, handler Handler, reply Reply) {
+	if msg == nil {
+		return
+	}
+	request := msg.IsRequest()
+	if msg.Batch != nil {
+		request = len(msg.Batch) == 0 || msg.Batch[0].IsRequest()
+	}
+	if !request {
+		if msg.Error != nil {
+			slog.Debug("jsonrpc dropped unmatched error response", slog.Any("err", msg.Error))
+		}
+		return
+	}
+	if handler == nil {
+		if !msg.IsNotification() && msg.Batch == nil {
+			resp := NewError(msg.ID, &Error{Code: CodeMethodNotFound, Message: "method not found: " + msg.Method})
+			if err := reply(ctx, resp); err != nil {
+				slog.Debug("jsonrpc reply failed", slog.Any("err", err))
+			}
+		}
+		return
+	}
+	handler(ctx, msg, reply)
+}
+
+// StreamConn 在字节流 (TCP / TLS / unix socket / stdio) 上实现 net.Conn[*Message]:
+// 连续的 JSON 值, 写出时每条以换行结尾.
+type StreamConn struct {
+	rwc     io.ReadWriteCloser
+	decoder *json.Decoder
+	handler Handler
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewStreamConn 包装 rwc. handler 处理对端请求与通知, 可传 server.Serve; nil 时对端请求
+// 一律回 CodeMethodNotFound.
+func NewStreamConn(rwc io.ReadWriteCloser, handler Handler) *StreamConn {
+	return &StreamConn{rwc: rwc, decoder: json.NewDecoder(rwc), handler: handler}
+}
+
+// Close 实现 net.Conn, 幂等.
+func (c *StreamConn) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		c.closeErr = c.rwc.Close()
+	})
+	return c.closeErr
+}
+
+// Read 实现 net.Conn. 由 Close 解除阻塞.
+func (c *StreamConn) Read(ctx context.Context) (*Message, error) {
+	var msg Message
+	if err := c.decoder.Decode(&msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// Write 实现 net.Conn, 并发安全.
+func (c *StreamConn) Write(ctx context.Context, msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.rwc.Write(data)
+	return err
+}
+
+// Handle 实现 net.Conn.
+func (c *StreamConn) Handle(ctx context.Context, msg *Message) {
+	handleIncoming(ctx, msg, c.handler, c.Write)
+}
+
+// WebSocketConn 在 gorilla websocket 连接上实现 net.Conn[*Message]: 每条消息一个文本帧.
+type WebSocketConn struct {
+	conn    *websocket.Conn
+	handler Handler
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// WebSocketWriteTimeout 是 WebSocketConn 单次写帧的超时.
+const WebSocketWriteTimeout = 10 * time.Second
+
+// NewWebSocketConn 包装已完成握手的 conn, handler 语义同 NewStreamConn.
+// 入站消息大小上限由调用方在 conn 上 SetReadLimit.
+func NewWebSocketConn(conn *websocket.Conn, handler Handler) *WebSocketConn {
+	return &WebSocketConn{conn: conn, handler: handler}
+}
+
+// Close 实现 net.Conn, 幂等.
+func (c *WebSocketConn) Close(ctx context.Context) error {
+	c.closeOnce.Do(func() {
+		c.closeErr = c.conn.Close()
+	})
+	return c.closeErr
+}
+
+// Read 实现 net.Conn.
+func (c *WebSocketConn) Read(ctx context.Context) (*Message, error) {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// Write 实现 net.Conn, 并发安全 (gorilla 要求单写者).
+func (c *WebSocketConn) Write(ctx context.Context, msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(WebSocketWriteTimeout)); err != nil {
+		return err
+	}
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// Handle 实现 net.Conn.
+func (c *WebSocketConn) Handle(ctx context.Context, msg *Message) {
+	handleIncoming(ctx, msg, c.handler, c.Write)
+}
@@ -0,0 +1,270 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	gonet "net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zdypro888/net/wsc"
+)
+
+type addParams struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+func newTestServer(notified chan<- string) *Server {
+	server := NewServer()
+	server.Register("add", Func(func(ctx context.Context, params addParams) (int, error) {
+		return params.A + params.B, nil
+	}))
+	server.Register("fail", func(ctx context.Context, params json.RawMessage) (any, error) {
+		return nil, &Error{Code: 42, Message: "boom"}
+	})
+	server.Register("log", Func(func(ctx context.Context, params string) (any, error) {
+		notified <- params
+		return nil, nil
+	}))
+	return server
+}
+
+func TestClientCallNotifyBatchOverStream(t *testing.T) {
+	clientSide, serverSide := gonet.Pipe()
+	notified := make(chan string, 4)
+	server := newTestServer(notified)
+
+	// 服务端也是一个 net.Client: 只负责读写与分发, 不主动调用.
+	serverClient := NewClient[*StreamConn]()
+	serverClient.Reset(context.Background(), NewStreamConn(serverSide, server.Serve))
+	defer serverClient.Close()
+	client := NewClient[*StreamConn]()
+	client.Reset(context.Background(), NewStreamConn(clientSide, nil))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var sum int
+	if err := client.Call(ctx, "add", addParams{A: 1, B: 2}, &sum); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if sum != 3 {
+		t.Fatalf("sum = %d, want 3", sum)
+	}
+
+	var rpcErr *Error
+	if err := client.Call(ctx, "fail", nil, nil); !errors.As(err, &rpcErr) || rpcErr.Code != 42 {
+		t.Fatalf("Call fail err = %v, want *Error code 42", err)
+	}
+	if err := client.Call(ctx, "missing", nil, nil); !errors.As(err, &rpcErr) || rpcErr.Code != CodeMethodNotFound {
+		t.Fatalf("Call missing err = %v, want CodeMethodNotFound", err)
+	}
+
+	if err := client.Notify(ctx, "log", "hello"); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	select {
+	case got := <-notified:
+		if got != "hello" {
+			t.Fatalf("notified = %q", got)
+		}
+	case <-ctx.Done():
+		t.Fatal("notification was not dispatched")
+	}
+
+	var first, second int
+	elems := []*BatchElem{
+		{Method: "add", Params: addParams{A: 2, B: 3}, Result: &first},
+		{Method: "log", Params: "batched", Notify: true},
+		{Method: "fail"},
+		{Method: "add", Params: addParams{A: 10, B: 20}, Result: &second},
+	}
+	if err := client.Batch(ctx, elems); err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+	if elems[0].Error != nil || first != 5 || elems[3].Error != nil || second != 30 {
+		t.Fatalf("batch results = %d (%v), %d (%v)", first, elems[0].Error, second, elems[3].Error)
+	}
+	if !errors.As(elems[2].Error, &rpcErr) || rpcErr.Code != 42 {
+		t.Fatalf("batch fail err = %v", elems[2].Error)
+	}
+	select {
+	case got := <-notified:
+		if got != "batched" {
+			t.Fatalf("notified = %q", got)
+		}
+	case <-ctx.Done():
+		t.Fatal("batched notification was not dispatched")
+	}
+}
+
+// batchWithPeer 对一个手写的对端发送 elems 的批量调用, respond 按收到的请求数组构造原始响应.
+func batchWithPeer(t *testing.T, elems []*BatchElem, respond func(reqs []*Message) string) {
+	t.Helper()
+	clientSide, serverSide := gonet.Pipe()
+	defer serverSide.Close()
+	go func() {
+		var reqs []*Message
+		if err := json.NewDecoder(serverSide).Decode(&reqs); err != nil {
+			return
+		}
+		serverSide.Write([]byte(respond(reqs)))
+	}()
+	client := NewClient[*StreamConn]()
+	client.Reset(context.Background(), NewStreamConn(clientSide, nil))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := client.Batch(ctx, elems); err != nil {
+		t.Fatalf("Batch failed: %v", err)
+	}
+}
+
+func TestClientBatchPartialResponse(t *testing.T) {
+	var first int
+	elems := []*BatchElem{
+		{Method: "add", Result: &first},
+		{Method: "add"},
+		{Method: "add"},
+	}
+	batchWithPeer(t, elems, func(reqs []*Message) string {
+		return `[{"jsonrpc":"2.0","id":` + string(reqs[0].ID) + `,"result":5}]`
+	})
+	if elems[0].Error != nil || first != 5 {
+		t.Fatalf("answered elem = %d (%v), want 5", first, elems[0].Error)
+	}
+	for _, elem := range elems[1:] {
+		if !errors.Is(elem.Error, ErrMissingResponse) {
+			t.Fatalf("unanswered elem err = %v, want ErrMissingResponse", elem.Error)
+		}
+	}
+}
+
+func TestClientBatchNullIDErrorResponse(t *testing.T) {
+	var first int
+	elems := []*BatchElem{
+		{Method: "add", Result: &first},
+		{Method: "add"},
+	}
+	batchWithPeer(t, elems, func(reqs []*Message) string {
+		return `[{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"invalid"}},` +
+			`{"jsonrpc":"2.0","id":` + string(reqs[0].ID) + `,"result":5}]`
+	})
+	if elems[0].Error != nil || first != 5 {
+		t.Fatalf("answered elem = %d (%v), want 5", first, elems[0].Error)
+	}
+	if !errors.Is(elems[1].Error, ErrMissingResponse) {
+		t.Fatalf("null-id elem err = %v, want ErrMissingResponse", elems[1].Error)
+	}
+}
+
+func TestMessageIdSeparatesPeerRequests(t *testing.T) {
+	var incoming Message
+	if err := json.Unmarshal([]byte(`{"jsonrpc":"2.0","id":7,"method":"add"}`), &incoming); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if _, ok := incoming.Id(); ok {
+		t.Fatal("peer request must not match pending calls")
+	}
+	var response Message
+	if err := json.Unmarshal([]byte(`{"jsonrpc":"2.0","id": 7,"result":1}`), &response); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	outgoing, err := NewRequest(7, "add", nil)
+	if err != nil {
+		t.Fatalf("NewRequest failed: %v", err)
+	}
+	reqID, _ := outgoing.Id()
+	respID, ok := response.Id()
+	if !ok || reqID != respID {
+		t.Fatalf("response id %v does not match request id %v", respID, reqID)
+	}
+	literal := &Message{JSONRPC: Version, ID: json.RawMessage("7"), Method: "add"}
+	if literalID, ok := literal.Id(); !ok || literalID != respID {
+		t.Fatalf("struct literal request id = %v, %v; want %v", literalID, ok, respID)
+	}
+}
+
+// recordingPeer 是只记录请求 id 的 WSCPeer, 直接回一个空结果.
+type recordingPeer struct {
+	WSCPeer
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+func (p *recordingPeer) Request(ctx context.Context, data *Message) (*Message, error) {
+	p.mu.Lock()
+	p.ids[string(data.ID)] = true
+	p.mu.Unlock()
+	return NewResult(data.ID, nil)
+}
+
+func TestWSCCallUsesDistinctIDs(t *testing.T) {
+	peer := &recordingPeer{ids: make(map[string]bool)}
+	var calls sync.WaitGroup
+	for range 16 {
+		calls.Go(func() {
+			if err := WSCCall(context.Background(), peer, "ping", nil, nil); err != nil {
+				t.Errorf("WSCCall failed: %v", err)
+			}
+		})
+	}
+	calls.Wait()
+	if len(peer.ids) != 16 {
+		t.Fatalf("distinct ids = %d, want 16", len(peer.ids))
+	}
+}
+
+func TestWSCCallAndServe(t *testing.T) {
+	notified := make(chan string, 1)
+	server := newTestServer(notified)
+	wscServer := wsc.NewServer[*Message]()
+	defer wscServer.Close()
+	upgrader := websocket.Upgrader{}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		session, err := wscServer.OnConnection(conn, nil)
+		if err != nil {
+			return
+		}
+		go func() { _ = ServeWSC(ctx, session, server) }()
+	}))
+	defer httpServer.Close()
+
+	client := wsc.NewClient[*Message]("ws" + httpServer.URL[len("http"):])
+	defer client.Close()
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	var sum int
+	if err := WSCCall(ctx, client, "add", addParams{A: 4, B: 5}, &sum); err != nil {
+		t.Fatalf("WSCCall failed: %v", err)
+	}
+	if sum != 9 {
+		t.Fatalf("sum = %d, want 9", sum)
+	}
+	if err := WSCNotify(ctx, client, "log", "over wsc"); err != nil {
+		t.Fatalf("WSCNotify failed: %v", err)
+	}
+	select {
+	case got := <-notified:
+		if got != "over wsc" {
+			t.Fatalf("notified = %q", got)
+		}
+	case <-ctx.Done():
+		t.Fatal("wsc notification was not dispatched")
+	}
+}
@@ -0,0 +1,202 @@
+// Package jsonrpc 在 net.Client 之上实现 JSON-RPC 2.0: Message 实现 net.NotifyMessage
+// (用 id 匹配响应, 对端的请求 / 通知交给 Handle), Client 提供 Call / Notify / Batch,
+// Server 按 method 分发. 传输层可用 StreamConn (TCP / TLS / unix socket 上的 JSON 流)、
+// WebSocketConn, 或经由 wsc 会话 (见 wsc.go).
+package jsonrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Version 是 jsonrpc 字段的固定值.
+const Version = "2.0"
+
+// 规范定义的错误码.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// ErrMissingResponse: Batch 的响应数组中缺少某个调用的结果.
+var ErrMissingResponse = fmt.Errorf("jsonrpc: missing response in batch")
+
+// Error 是 JSON-RPC 错误对象, 同时实现 error. Server 的方法返回 *Error 时原样回给对端.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// Message 是一条 JSON-RPC 消息 (请求 / 通知 / 响应), Batch 非 nil 时表示批量消息,
+// 编码为 JSON 数组, 其余字段忽略. 可以用 NewRequest 等构造, 也可以直接写结构体字面量.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	Batch   []*Message      `json:"-"`
+
+	// received 标记从线上解码得到的消息 (UnmarshalJSON 置位). 本端请求与对端请求都带
+	// method + id, 只有本端构造的参与响应匹配, 解码得到的对端请求交给 Handle.
+	received bool
+}
+
+// NewRequest 创建请求. id 须为字符串或数字.
+func NewRequest(id any, method string, params any) (*Message, error) {
+	rawID, err := json.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := NewNotification(method, params)
+	if err != nil {
+		return nil, err
+	}
+	msg.ID = rawID
+	return msg, nil
+}
+
+// NewNotification 创建通知 (无 id, 对端不响应).
+func NewNotification(method string, params any) (*Message, error) {
+	msg := &Message{JSONRPC: Version, Method: method}
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		msg.Params = raw
+	}
+	return msg, nil
+}
+
+// NewBatch 把多条消息合成一条批量消息.
+func NewBatch(msgs ...*Message) *Message {
+	return &Message{Batch: msgs}
+}
+
+// NewResult 创建对请求 id 的成功响应.
+func NewResult(id json.RawMessage, result any) (*Message, error) {
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{JSONRPC: Version, ID: id, Result: raw}, nil
+}
+
+// NewError 创建对请求 id 的错误响应; id 为空时按规范写 null.
+func NewError(id json.RawMessage, err *Error) *Message {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &Message{JSONRPC: Version, ID: id, Error: err}
+}
+
+// IsRequest 报告是否是请求或通知 (带 method).
+func (msg *Message) IsRequest() bool {
+	return msg.Method != ""
+}
+
+// IsNotification 报告是否是通知 (带 method, 无 id).
+func (msg *Message) IsNotification() bool {
+	return msg.Method != "" && !hasID(msg.ID)
+}
+
+// Id 实现 net.NotifyMessage. 单条: 本端构造的请求与解码得到的响应返回 id 文本; 解码得到的
+// 对端请求、通知、id 为 null 的错误响应返回 false (交给 Handle). 批量: 返回第一个参与匹配的 id,
+// 匹配按 Ids 进行.
+func (msg *Message) Id() (any, bool) {
+	if ids := msg.Ids(); len(ids) > 0 {
+		return ids[0], true
+	}
+	if id, ok := msg.matchID(); ok {
+		return id, true
+	}
+	return nil, false
+}
+
+// Ids 实现 net.NotifyBatchMessage: 批量消息返回其中所有参与匹配的 id, 单条返回 nil.
+// Batch 调用以每个成员 id 登记, 对端返回的响应数组只要含有其中一个 id 就能匹配到,
+// 缺项或夹带 id 为 null 的错误对象时也一样 (见 Client.Batch).
+func (msg *Message) Ids() []any {
+	if msg == nil || msg.Batch == nil {
+		return nil
+	}
+	ids := make([]any, 0, len(msg.Batch))
+	for _, item := range msg.Batch {
+		if id, ok := item.matchID(); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (msg *Message) matchID() (string, bool) {
+	if msg == nil || !hasID(msg.ID) || (msg.Method != "" && msg.received) {
+		return "", false
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, msg.ID); err != nil {
+		return string(msg.ID), true
+	}
+	return compact.String(), true
+}
+
+// decodeResult 把响应的 result 解到 v; 错误响应返回 *Error.
+func (msg *Message) decodeResult(v any) error {
+	if msg.Error != nil {
+		return msg.Error
+	}
+	if v == nil || len(msg.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(msg.Result, v)
+}
+
+// message 是 Message 去掉方法后的别名, 供 Marshal/Unmarshal 使用默认编码.
+type message Message
+
+// MarshalJSON 批量消息编码为数组.
+func (msg *Message) MarshalJSON() ([]byte, error) {
+	if msg.Batch != nil {
+		return json.Marshal(msg.Batch)
+	}
+	return json.Marshal((*message)(msg))
+}
+
+// UnmarshalJSON 识别数组形式的批量消息, 并把结果标记为对端消息 (见 Id).
+func (msg *Message) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		batch := []*Message{}
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return err
+		}
+		*msg = Message{Batch: batch, received: true}
+		return nil
+	}
+	if err := json.Unmarshal(trimmed, (*message)(msg)); err != nil {
+		return err
+	}
+	msg.received = true
+	return nil
+}
+
+func hasID(id json.RawMessage) bool {
+	return len(id) > 0 && string(id) != "null"
+}
+
+// numericID 把递增序号编码为 JSON 数字 id.
+func numericID(n uint64) json.RawMessage {
+	return json.RawMessage(strconv.FormatUint(n, 10))
+}
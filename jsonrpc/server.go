@@ -0,0 +1,118 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// HandlerFunc 处理一个方法调用. 返回 *Error 时原样回给对端, 其它 error 包装为
+// CodeInternalError. 通知调用的返回值被丢弃.
+type HandlerFunc func(ctx context.Context, params json.RawMessage) (any, error)
+
+// Func 把强类型函数适配为 HandlerFunc: params 解码失败时返回 CodeInvalidParams.
+func Func[P, R any](fn func(ctx context.Context, params P) (R, error)) HandlerFunc {
+	return func(ctx context.Context, raw json.RawMessage) (any, error) {
+		var params P
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &params); err != nil {
+				return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+			}
+		}
+		return fn(ctx, params)
+	}
+}
+
+// Reply 把响应写回对端.
+type Reply func(ctx context.Context, resp *Message) error
+
+// Handler 处理对端发来的请求 / 通知 (未匹配到本端调用的消息).
+type Handler func(ctx context.Context, msg *Message, reply Reply)
+
+// Server 按 method 分发请求. 方法在连接的处理协程中同步执行, 慢方法会阻塞该连接的
+// 响应匹配, 需要时配合 net.Client.SetHandleDispatch 使用.
+type Server struct {
+	locker  sync.RWMutex
+	methods map[string]HandlerFunc
+}
+
+// NewServer 创建空的方法表.
+func NewServer() *Server {
+	return &Server{methods: make(map[string]HandlerFunc)}
+}
+
+// Register 注册 (或替换) method 的处理函数. 线程安全.
+func (server *Server) Register(method string, handler HandlerFunc) {
+	server.locker.Lock()
+	defer server.locker.Unlock()
+	server.methods[method] = handler
+}
+
+// Serve 实现 Handler: 分发 msg 并通过 reply 写回响应 (通知不回).
+func (server *Server) Serve(ctx context.Context, msg *Message, reply Reply) {
+	resp := server.Dispatch(ctx, msg)
+	if resp == nil {
+		return
+	}
+	if err := reply(ctx, resp); err != nil {
+		slog.Warn("jsonrpc server reply failed", slog.String("method", msg.Method), slog.Any("err", err))
+	}
+}
+
+// Dispatch 执行 msg (单条或批量) 并返回应写回的响应; 通知及全部是通知的批量返回 nil.
+func (server *Server) Dispatch(ctx context.Context, msg *Message) *Message {
+	if msg.Batch == nil {
+		return server.dispatchOne(ctx, msg)
+	}
+	if len(msg.Batch) == 0 {
+		return NewError(nil, &Error{Code: CodeInvalidRequest, Message: "empty batch"})
+	}
+	var responses []*Message
+	for _, item := range msg.Batch {
+		if resp := server.dispatchOne(ctx, item); resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return NewBatch(responses...)
+}
+
+func (server *Server) dispatchOne(ctx context.Context, msg *Message) *Message {
+	if msg == nil || msg.JSONRPC != Version || msg.Method == "" || msg.Batch != nil {
+		var id json.RawMessage
+		if msg != nil {
+			id = msg.ID
+		}
+		return NewError(id, &Error{Code: CodeInvalidRequest, Message: "invalid request"})
+	}
+	server.locker.RLock()
+	handler, ok := server.methods[msg.Method]
+	server.locker.RUnlock()
+	notification := msg.IsNotification()
+	if !ok {
+		if notification {
+			return nil
+		}
+		return NewError(msg.ID, &Error{Code: CodeMethodNotFound, Message: "method not found: " + msg.Method})
+	}
+	result, err := handler(ctx, msg.Params)
+	if notification {
+		return nil
+	}
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		return NewError(msg.ID, rpcErr)
+	}
+	resp, err := NewResult(msg.ID, result)
+	if err != nil {
+		return NewError(msg.ID, &Error{Code: CodeInternalError, Message: err.Error()})
+	}
+	return resp
+}
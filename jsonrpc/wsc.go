@@ -0,0 +1,76 @@
+package jsonrpc
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/zdypro888/net/wsc"
+)
+
+// WSCPeer 是 wsc.Session[*Message] 与 wsc.Client[*Message] 的公共能力. 经由 wsc 传输时
+// 请求-响应匹配由 wsc 信封的 ID 完成, JSON-RPC 的 id 只原样携带.
+type WSCPeer interface {
+	Write(ctx context.Context, data *Message) error
+	Request(ctx context.Context, data *Message) (*Message, error)
+	Reply(ctx context.Context, id string, data *Message) error
+	Handle() <-chan *wsc.Packet[*Message]
+}
+
+// wscIDs 为 WSCCall 分配 JSON-RPC id, 进程内递增, 同一会话上的并发调用在日志与
+// 错误中可区分.
+var wscIDs atomic.Uint64
+
+// WSCCall 经由 wsc 会话调用 method, 语义同 Client.Call.
+func WSCCall(ctx context.Context, peer WSCPeer, method string, params any, result any) error {
+	// 匹配由 wsc 完成, 但对端 Server 需要 id 才会返回响应, 且 id 会出现在对端日志与错误里.
+	req, err := NewNotification(method, params)
+	if err != nil {
+		return err
+	}
+	req.ID = numericID(wscIDs.Add(1))
+	resp, err := peer.Request(ctx, req)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return &Error{Code: CodeInternalError, Message: "empty response"}
+	}
+	return resp.decodeResult(result)
+}
+
+// WSCNotify 经由 wsc 会话发送通知.
+func WSCNotify(ctx context.Context, peer WSCPeer, method string, params any) error {
+	msg, err := NewNotification(method, params)
+	if err != nil {
+		return err
+	}
+	return peer.Write(ctx, msg)
+}
+
+// ServeWSC 消费 peer.Handle() 中的请求 / 通知并交给 server 分发, 带 wsc 请求 ID 的包
+// 通过 Reply 回写. 阻塞到 ctx 结束或 Handle 通道关闭 (会话关闭). wsc.Client 的
+// Closed 包 (断线提示) 被跳过, 重连后继续服务.
+func ServeWSC(ctx context.Context, peer WSCPeer, server *Server) error {
+	packets := peer.Handle()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case packet, ok := <-packets:
+			if !ok {
+				return wsc.ErrSessionClosed
+			}
+			if packet.Closed || packet.Data == nil {
+				continue
+			}
+			resp := server.Dispatch(ctx, packet.Data)
+			if resp == nil || packet.ID == "" {
+				continue
+			}
+			if err := peer.Reply(ctx, packet.ID, resp); err != nil {
+				slog.Warn("jsonrpc wsc reply failed", slog.String("method", packet.Data.Method), slog.Any("err", err))
+			}
+		}
+	}
+}
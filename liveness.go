@@ -0,0 +1,35 @@
+package net
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrReadIdleTimeout: SetReadIdleTimeout 开启后, 超过设定时间没有从连接读到任何消息
+// (含心跳应答), Client 关闭连接并以此作为 lastError; 等待中的请求同样拿到该错误.
+var ErrReadIdleTimeout = fmt.Errorf("read idle timeout")
+
+// rttWeight 是 RTT 滑动平均中新样本的权重倒数 (与 TCP SRTT 一致取 1/8).
+const rttWeight = 8
+
+// SetReadIdleTimeout 设置读空闲上限: 连接上超过 timeout 没有读到任何消息时主动断开,
+// Supervise 模式下随后重连. <=0 关闭检测 (默认). 对端静默的常见原因是半开连接,
+// 通常配合心跳使用, timeout 取心跳间隔的 2~3 倍.
+// 与 SetBufferSize 一样在 Reset 时生效; 已连接后修改在下次 Reset 生效.
+func (client *Client[M, T]) SetReadIdleTimeout(timeout time.Duration) {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	client.readIdleTimeout = max(timeout, 0)
+}
+
+// observeRTT 记录一次心跳往返时延并更新滑动平均. 只在 asyncGo 中调用.
+func (client *Client[M, T]) observeRTT(sample time.Duration) {
+	client.lastRTT.Store(int64(sample))
+	rtt := client.rtt.Load()
+	if rtt == 0 {
+		rtt = int64(sample)
+	} else {
+		rtt += (int64(sample) - rtt) / rttWeight
+	}
+	client.rtt.Store(rtt)
+}
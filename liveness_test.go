@@ -0,0 +1,94 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// heartAckConn 每 10ms 发一次 "ping" 心跳, 把 "pong" 识别为心跳应答.
+type heartAckConn struct {
+	*fakeConn
+}
+
+func (c *heartAckConn) Heart(connect bool, count uint64) (testMessage, time.Time, bool) {
+	return testMessage{value: "ping"}, time.Now().Add(10 * time.Millisecond), !connect
+}
+
+func (c *heartAckConn) HeartAck(msg testMessage) bool {
+	return msg.value == "pong"
+}
+
+func TestClientHeartAckMeasuresRTT(t *testing.T) {
+	conn := &heartAckConn{fakeConn: newFakeConn()}
+	client := NewClient[testMessage, *heartAckConn]()
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	// 对端: 延迟 5ms 应答心跳.
+	deadline := time.After(time.Second)
+	for acked := 0; acked < 3; {
+		select {
+		case msg := <-conn.writeCh:
+			if msg.value != "ping" {
+				continue
+			}
+			time.Sleep(5 * time.Millisecond)
+			conn.readCh <- testMessage{value: "pong"}
+			acked++
+		case <-deadline:
+			t.Fatal("heartbeats were not sent")
+		}
+	}
+	for {
+		stats := client.Stats()
+		if stats.LastRTT >= 5*time.Millisecond && stats.RTT >= 5*time.Millisecond {
+			if stats.LastRead.IsZero() {
+				t.Fatal("LastRead was not recorded")
+			}
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("RTT not measured: %+v", stats)
+		case <-time.After(time.Millisecond):
+		}
+	}
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if len(conn.handled) != 0 {
+		t.Fatalf("heartbeat acks leaked to Handle: %v", conn.handled)
+	}
+}
+
+func TestClientReadIdleTimeoutClosesConnection(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.SetReadIdleTimeout(50 * time.Millisecond)
+	client.Reset(context.Background(), conn)
+
+	// 持续有读到的消息时不断开.
+	for range 5 {
+		conn.readCh <- testMessage{value: "push"}
+		time.Sleep(20 * time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := client.Request(ctx, testMessage{id: "1", value: "req"})
+	if !errors.Is(err, ErrReadIdleTimeout) {
+		t.Fatalf("Request err = %v, want ErrReadIdleTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("idle timeout fired late: %v", elapsed)
+	}
+	select {
+	case <-conn.closed:
+	default:
+		t.Fatal("connection was not closed")
+	}
+	if err := client.Close(); !errors.Is(err, ErrReadIdleTimeout) {
+		t.Fatalf("Close err = %v, want ErrReadIdleTimeout", err)
+	}
+}
@@ -0,0 +1,66 @@
+// Package metrics 定义 net.Client、wsc 与 wsproxy 共用的观测接口 Observer, 并提供
+// 不依赖第三方库的 Prometheus 文本格式适配器. 与各组件的 Stats 快照不同, Observer
+// 是推送式的: 组件在事件发生时同步调用, 由实现自行聚合.
+package metrics
+
+import "time"
+
+// Observer 接收组件事件. component 标识事件来源 (默认 "net" / "wsc" / "wsproxy.server" /
+// "wsproxy.slaver", 可用 Named 改写), 用于区分同一进程内的多个实例.
+// 方法在组件的读写 / 请求协程中同步调用, 实现必须并发安全且不阻塞.
+type Observer interface {
+	// RequestStart 在请求开始 (进入发送前) 时调用.
+	RequestStart(component string)
+	// RequestEnd 在请求结束时调用, duration 为从 RequestStart 起的耗时, err 为请求结果.
+	RequestEnd(component string, duration time.Duration, err error)
+	// MessageSent 在一条消息写出时调用. bytes 为线上字节数, 组件无法得知时为 0.
+	MessageSent(component string, bytes int)
+	// MessageReceived 在读到一条消息时调用, bytes 含义同 MessageSent.
+	MessageReceived(component string, bytes int)
+	// Reconnect 在断线后重新建立连接时调用 (首次连接不调用).
+	Reconnect(component string)
+	// SessionOpen / SessionClose 在会话建立 / 结束时成对调用.
+	SessionOpen(component string)
+	SessionClose(component string)
+	// TunnelOpen / TunnelClose 在代理隧道开始 / 结束转发时成对调用, err 为隧道的结束原因.
+	TunnelOpen(component string)
+	TunnelClose(component string, duration time.Duration, err error)
+}
+
+// Nop 是不做任何事的 Observer. 嵌入到自定义实现中可以只覆盖关心的方法.
+type Nop struct{}
+
+func (Nop) RequestStart(component string)                                   {}
+func (Nop) RequestEnd(component string, duration time.Duration, err error)  {}
+func (Nop) MessageSent(component string, bytes int)                         {}
+func (Nop) MessageReceived(component string, bytes int)                     {}
+func (Nop) Reconnect(component string)                                      {}
+func (Nop) SessionOpen(component string)                                    {}
+func (Nop) SessionClose(component string)                                   {}
+func (Nop) TunnelOpen(component string)                                     {}
+func (Nop) TunnelClose(component string, duration time.Duration, err error) {}
+
+// Named 返回把所有事件的 component 替换为 name 后转给 observer 的 Observer,
+// 用于区分同类组件的多个实例 (例如两个连不同后端的 net.Client).
+func Named(observer Observer, name string) Observer {
+	return named{observer: observer, name: name}
+}
+
+type named struct {
+	observer Observer
+	name     string
+}
+
+func (n named) RequestStart(string) { n.observer.RequestStart(n.name) }
+func (n named) RequestEnd(_ string, duration time.Duration, err error) {
+	n.observer.RequestEnd(n.name, duration, err)
+}
+func (n named) MessageSent(_ string, bytes int)     { n.observer.MessageSent(n.name, bytes) }
+func (n named) MessageReceived(_ string, bytes int) { n.observer.MessageReceived(n.name, bytes) }
+func (n named) Reconnect(string)                    { n.observer.Reconnect(n.name) }
+func (n named) SessionOpen(string)                  { n.observer.SessionOpen(n.name) }
+func (n named) SessionClose(string)                 { n.observer.SessionClose(n.name) }
+func (n named) TunnelOpen(string)                   { n.observer.TunnelOpen(n.name) }
+func (n named) TunnelClose(_ string, duration time.Duration, err error) {
+	n.observer.TunnelClose(n.name, duration, err)
+}
@@ -0,0 +1,252 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets 是请求耗时直方图的默认上界 (秒).
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Prometheus 是聚合 Observer 事件并以 Prometheus 文本格式 (text/plain; version=0.0.4)
+// 输出的 Observer. 所有指标带 component 标签; 作为 http.Handler 可直接挂到 /metrics.
+type Prometheus struct {
+	namespace string
+	buckets   []float64
+
+	locker     sync.Mutex
+	components map[string]*componentMetrics
+}
+
+type componentMetrics struct {
+	requestsInFlight int64
+	requestsOK       uint64
+	requestsError    uint64
+	durationBuckets  []uint64 // 与 buckets 一一对应的非累积计数, 输出时累加
+	durationSum      float64
+	messagesSent     uint64
+	messagesReceived uint64
+	bytesSent        uint64
+	bytesReceived    uint64
+	reconnects       uint64
+	sessionsOpen     int64
+	sessionsOpened   uint64
+	tunnelsOpen      int64
+	tunnelsOK        uint64
+	tunnelsError     uint64
+	tunnelSeconds    float64
+}
+
+// NewPrometheus 创建 Prometheus. namespace 为指标名前缀, 空时为 "net"; buckets 为
+// 请求耗时直方图的上界 (秒), 空时用 DefaultBuckets.
+func NewPrometheus(namespace string, buckets ...float64) *Prometheus {
+	if namespace == "" {
+		namespace = "net"
+	}
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	return &Prometheus{namespace: namespace, buckets: slices.Compact(buckets), components: make(map[string]*componentMetrics)}
+}
+
+// component 返回 component 的指标, 不存在时创建. 必须持有 locker.
+func (p *Prometheus) component(component string) *componentMetrics {
+	metrics, ok := p.components[component]
+	if !ok {
+		metrics = &componentMetrics{durationBuckets: make([]uint64, len(p.buckets))}
+		p.components[component] = metrics
+	}
+	return metrics
+}
+
+func (p *Prometheus) update(component string, fn func(metrics *componentMetrics)) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	fn(p.component(component))
+}
+
+// RequestStart 实现 Observer.
+func (p *Prometheus) RequestStart(component string) {
+	p.update(component, func(metrics *componentMetrics) { metrics.requestsInFlight++ })
+}
+
+// RequestEnd 实现 Observer.
+func (p *Prometheus) RequestEnd(component string, duration time.Duration, err error) {
+	seconds := duration.Seconds()
+	bucket, _ := slices.BinarySearch(p.buckets, seconds)
+	p.update(component, func(metrics *componentMetrics) {
+		metrics.requestsInFlight--
+		if err != nil {
+			metrics.requestsError++
+		} else {
+			metrics.requestsOK++
+		}
+		if bucket < len(metrics.durationBuckets) {
+			metrics.durationBuckets[bucket]++
+		}
+		metrics.durationSum += seconds
+	})
+}
+
+// MessageSent 实现 Observer.
+func (p *Prometheus) MessageSent(component string, bytes int) {
+	p.update(component, func(metrics *componentMetrics) {
+		metrics.messagesSent++
+		metrics.bytesSent += uint64(max(bytes, 0))
+	})
+}
+
+// MessageReceived 实现 Observer.
+func (p *Prometheus) MessageReceived(component string, bytes int) {
+	p.update(component, func(metrics *componentMetrics) {
+		metrics.messagesReceived++
+		metrics.bytesReceived += uint64(max(bytes, 0))
+	})
+}
+
+// Reconnect 实现 Observer.
+func (p *Prometheus) Reconnect(component string) {
+	p.update(component, func(metrics *componentMetrics) { metrics.reconnects++ })
+}
+
+// SessionOpen 实现 Observer.
+func (p *Prometheus) SessionOpen(component string) {
+	p.update(component, func(metrics *componentMetrics) {
+		metrics.sessionsOpen++
+		metrics.sessionsOpened++
+	})
+}
+
+// SessionClose 实现 Observer.
+func (p *Prometheus) SessionClose(component string) {
+	p.update(component, func(metrics *componentMetrics) { metrics.sessionsOpen-- })
+}
+
+// TunnelOpen 实现 Observer.
+func (p *Prometheus) TunnelOpen(component string) {
+	p.update(component, func(metrics *componentMetrics) { metrics.tunnelsOpen++ })
+}
+
+// TunnelClose 实现 Observer.
+func (p *Prometheus) TunnelClose(component string, duration time.Duration, err error) {
+	p.update(component, func(metrics *componentMetrics) {
+		metrics.tunnelsOpen--
+		if err != nil {
+			metrics.tunnelsError++
+		} else {
+			metrics.tunnelsOK++
+		}
+		metrics.tunnelSeconds += duration.Seconds()
+	})
+}
+
+// metricFamily 是一个指标的输出描述, value 按 component 取一个或多个样本.
+type metricFamily struct {
+	name    string
+	kind    string
+	help    string
+	samples func(labels string, metrics *componentMetrics, write func(suffix, labels string, value float64))
+}
+
+func single(get func(metrics *componentMetrics) float64) func(string, *componentMetrics, func(string, string, float64)) {
+	return func(labels string, metrics *componentMetrics, write func(string, string, float64)) {
+		write("", labels, get(metrics))
+	}
+}
+
+func (p *Prometheus) families() []metricFamily {
+	return []metricFamily{
+		{"requests_in_flight", "gauge", "Requests started and not yet finished.", single(func(m *componentMetrics) float64 { return float64(m.requestsInFlight) })},
+		{"requests_total", "counter", "Finished requests by result.", func(labels string, m *componentMetrics, write func(string, string, float64)) {
+			write("", labels+`,result="ok"`, float64(m.requestsOK))
+			write("", labels+`,result="error"`, float64(m.requestsError))
+		}},
+		{"request_duration_seconds", "histogram", "Request latency.", func(labels string, m *componentMetrics, write func(string, string, float64)) {
+			var cumulative uint64
+			for i, bound := range p.buckets {
+				cumulative += m.durationBuckets[i]
+				write("_bucket", labels+`,le="`+strconv.FormatFloat(bound, 'g', -1, 64)+`"`, float64(cumulative))
+			}
+			count := m.requestsOK + m.requestsError
+			write("_bucket", labels+`,le="+Inf"`, float64(count))
+			write("_sum", labels, m.durationSum)
+			write("_count", labels, float64(count))
+		}},
+		{"messages_sent_total", "counter", "Messages written.", single(func(m *componentMetrics) float64 { return float64(m.messagesSent) })},
+		{"messages_received_total", "counter", "Messages read.", single(func(m *componentMetrics) float64 { return float64(m.messagesReceived) })},
+		{"sent_bytes_total", "counter", "Bytes written, where known.", single(func(m *componentMetrics) float64 { return float64(m.bytesSent) })},
+		{"received_bytes_total", "counter", "Bytes read, where known.", single(func(m *componentMetrics) float64 { return float64(m.bytesReceived) })},
+		{"reconnects_total", "counter", "Reconnections after a lost connection.", single(func(m *componentMetrics) float64 { return float64(m.reconnects) })},
+		{"sessions_open", "gauge", "Currently open sessions.", single(func(m *componentMetrics) float64 { return float64(m.sessionsOpen) })},
+		{"sessions_opened_total", "counter", "Sessions opened.", single(func(m *componentMetrics) float64 { return float64(m.sessionsOpened) })},
+		{"tunnels_open", "gauge", "Currently open proxy tunnels.", single(func(m *componentMetrics) float64 { return float64(m.tunnelsOpen) })},
+		{"tunnels_closed_total", "counter", "Closed proxy tunnels by result.", func(labels string, m *componentMetrics, write func(string, string, float64)) {
+			write("", labels+`,result="ok"`, float64(m.tunnelsOK))
+			write("", labels+`,result="error"`, float64(m.tunnelsError))
+		}},
+		{"tunnel_duration_seconds_total", "counter", "Total lifetime of closed proxy tunnels.", single(func(m *componentMetrics) float64 { return m.tunnelSeconds })},
+	}
+}
+
+// WriteTo 以 Prometheus 文本格式写出当前所有指标, component 按字典序输出.
+func (p *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	p.locker.Lock()
+	names := make([]string, 0, len(p.components))
+	for name := range p.components {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	snapshot := make([]componentMetrics, len(names))
+	for i, name := range names {
+		snapshot[i] = *p.components[name]
+		snapshot[i].durationBuckets = slices.Clone(snapshot[i].durationBuckets)
+	}
+	p.locker.Unlock()
+
+	counter := &countingWriter{w: w}
+	buffered := bufio.NewWriter(counter)
+	for _, family := range p.families() {
+		name := p.namespace + "_" + family.name
+		fmt.Fprintf(buffered, "# HELP %s %s\n# TYPE %s %s\n", name, family.help, name, family.kind)
+		for i := range snapshot {
+			labels := `component="` + escapeLabel(names[i]) + `"`
+			family.samples(labels, &snapshot[i], func(suffix, labels string, value float64) {
+				fmt.Fprintf(buffered, "%s%s{%s} %s\n", name, suffix, labels, strconv.FormatFloat(value, 'g', -1, 64))
+			})
+		}
+	}
+	err := buffered.Flush()
+	return counter.n, err
+}
+
+// ServeHTTP 实现 http.Handler, 输出 WriteTo 的内容.
+func (p *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = p.WriteTo(w)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusExposition(t *testing.T) {
+	p := NewPrometheus("app", 0.1, 1)
+	p.RequestStart("net")
+	p.RequestEnd("net", 50*time.Millisecond, nil)
+	p.RequestStart("net")
+	p.RequestEnd("net", 2*time.Second, errors.New("boom"))
+	p.RequestStart("net")
+	p.MessageSent("net", 10)
+	p.MessageReceived("net", 4)
+	p.Reconnect("net")
+	p.SessionOpen("net")
+	Named(p, `edge "a"`).TunnelOpen("wsproxy.server")
+
+	var out strings.Builder
+	if _, err := p.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	text := out.String()
+	for _, want := range []string{
+		"# TYPE app_request_duration_seconds histogram\n",
+		`app_requests_in_flight{component="net"} 1` + "\n",
+		`app_requests_total{component="net",result="ok"} 1` + "\n",
+		`app_requests_total{component="net",result="error"} 1` + "\n",
+		`app_request_duration_seconds_bucket{component="net",le="0.1"} 1` + "\n",
+		`app_request_duration_seconds_bucket{component="net",le="1"} 1` + "\n",
+		`app_request_duration_seconds_bucket{component="net",le="+Inf"} 2` + "\n",
+		`app_request_duration_seconds_sum{component="net"} 2.05` + "\n",
+		`app_request_duration_seconds_count{component="net"} 2` + "\n",
+		`app_sent_bytes_total{component="net"} 10` + "\n",
+		`app_received_bytes_total{component="net"} 4` + "\n",
+		`app_reconnects_total{component="net"} 1` + "\n",
+		`app_sessions_open{component="net"} 1` + "\n",
+		`app_tunnels_open{component="edge \"a\""} 1` + "\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("exposition missing %q:\n%s", want, text)
+		}
+	}
+	// component 按字典序输出.
+	if strings.Index(text, `app_sessions_open{component="edge`) > strings.Index(text, `app_sessions_open{component="net"}`) {
+		t.Fatalf("components not sorted:\n%s", text)
+	}
+
+	recorder := httptest.NewRecorder()
+	p.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if got := recorder.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", got)
+	}
+	if recorder.Body.String() != text {
+		t.Fatalf("ServeHTTP output differs from WriteTo")
+	}
+}
@@ -0,0 +1,156 @@
+package net
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/zdypro888/net/metrics"
+)
+
+// RoundTripFunc 把函数适配为 http.RoundTripper.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (fn RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+// Middleware 包裹下一层 RoundTripper, 与 http.RoundTripper 装饰器同义.
+// 遵守 RoundTripper 约定: 不修改传入的 req (需要改时先 req.Clone), 出错时不返回响应.
+// 重定向的每一跳与 AutoRetry 的每次尝试都各自经过整条链.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// middlewareTransport 是 HTTP.client 实际使用的 transport: chain 为套好的链,
+// CloseIdleConnections 透传给底层 transport, 让 client.CloseIdleConnections 继续生效.
+type middlewareTransport struct {
+	chain http.RoundTripper
+	base  http.RoundTripper
+}
+
+func (t *middlewareTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.chain.RoundTrip(req)
+}
+
+func (t *middlewareTransport) CloseIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// AddMiddleware 追加中间件. 先注册的在外层 (最先看到请求, 最后看到响应), 最内层是
+// NewHTTP / NewHTTP3 创建的 transport (有 HTTPOptions.Hosts 时是按 host 的路由), 代理等
+// Configure* 设置仍作用于它, 见 applyTransport.
+// 与其他 Configure* 一样需在调用 Request 前设置好, 无并发保护.
+func (h *HTTP) AddMiddleware(middlewares ...Middleware) {
+	h.middlewares = append(h.middlewares, middlewares...)
+	h.applyTransport()
+}
+
+// LogMiddleware 以 Debug 级别记录每次往返的方法、URL、状态码与耗时. URL 与错误经过
+// safeURLForLog 脱敏 (去掉 userinfo / query / fragment). logger 为 nil 时用 slog.Default().
+func LogMiddleware(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			res, err := next.RoundTrip(req)
+			rawURL := req.URL.String()
+			attrs := []slog.Attr{
+				slog.String("method", req.Method),
+				slog.String("url", safeURLForLog(rawURL)),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if err != nil {
+				attrs = append(attrs, slog.String("err", safeErrorForLog(rawURL, err)))
+			} else {
+				attrs = append(attrs, slog.Int("status", res.StatusCode))
+			}
+			logger.LogAttrs(req.Context(), slog.LevelDebug, "net.HTTP round trip", attrs...)
+			return res, err
+		})
+	}
+}
+
+// cloneRequest 复制 req 供中间件改写, 不动调用方的请求. RoundTripper 收到的 req.Header
+// 可以为 nil (http.Client 会补, 直接调用 RoundTrip 时不会), 副本上补成空 map 以便写入.
+func cloneRequest(req *http.Request) *http.Request {
+	cloned := req.Clone(req.Context())
+	if cloned.Header == nil {
+		cloned.Header = make(http.Header)
+	}
+	return cloned
+}
+
+// HeaderMiddleware 为请求补上 headers 中请求尚未设置的头, 已有的头不覆盖.
+func HeaderMiddleware(headers http.Header) Middleware {
+	headers = headers.Clone()
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			var cloned *http.Request
+			for key, values := range headers {
+				if _, ok := req.Header[key]; ok {
+					continue
+				}
+				if cloned == nil {
+					cloned = cloneRequest(req)
+				}
+				cloned.Header[key] = append([]string(nil), values...)
+			}
+			if cloned != nil {
+				req = cloned
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// AuthMiddleware 按请求的 hostname (不含端口) 注入 Authorization 头, credentials 的 key 为
+// hostname, value 为完整头值 (如 "Bearer xxx"). 请求已带 Authorization 时不覆盖.
+// 只按精确 hostname 匹配, 重定向到其他 host 的请求不会带上凭据.
+func AuthMiddleware(credentials map[string]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if value, ok := credentials[req.URL.Hostname()]; ok && req.Header.Get("Authorization") == "" {
+				req = cloneRequest(req)
+				req.Header.Set("Authorization", value)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// SignMiddleware 在发送前对请求的副本调用 sign, 用于签名类鉴权 (改写头或 URL query).
+// 需要读 body 时用 req.GetBody 取副本, 不要消费 req.Body. sign 返回错误时不发送请求.
+func SignMiddleware(sign func(req *http.Request) error) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			req = cloneRequest(req)
+			if err := sign(req); err != nil {
+				if req.Body != nil {
+					_ = req.Body.Close()
+				}
+				return nil, err
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// MetricsMiddleware 把每次往返作为一次请求上报给 observer: RequestStart / RequestEnd,
+// 耗时到响应头为止, err 只含传输错误 (非 2xx 不算错误). component 为空时用 "http".
+func MetricsMiddleware(observer metrics.Observer, component string) Middleware {
+	if component == "" {
+		component = "http"
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			observer.RequestStart(component)
+			res, err := next.RoundTrip(req)
+			observer.RequestEnd(component, time.Since(start), err)
+			return res, err
+		})
+	}
+}
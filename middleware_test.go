@@ -0,0 +1,152 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zdypro888/net/metrics"
+)
+
+type countingObserver struct {
+	metrics.Nop
+	mu     sync.Mutex
+	starts int
+	ends   int
+}
+
+func (o *countingObserver) RequestStart(string) {
+	o.mu.Lock()
+	o.starts++
+	o.mu.Unlock()
+}
+
+func (o *countingObserver) RequestEnd(string, time.Duration, error) {
+	o.mu.Lock()
+	o.ends++
+	o.mu.Unlock()
+}
+
+func TestHTTPMiddlewareChainOrderAndBuiltins(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	observer := &countingObserver{}
+	h.AddMiddleware(trace("outer"), HeaderMiddleware(http.Header{"X-Default": {"d"}, "User-Agent": {"default-ua"}}))
+	h.AddMiddleware(
+		AuthMiddleware(map[string]string{"127.0.0.1": "Bearer token"}),
+		SignMiddleware(func(req *http.Request) error {
+			req.Header.Set("X-Signature", req.Method+" "+req.URL.Path)
+			return nil
+		}),
+		MetricsMiddleware(observer, ""),
+		LogMiddleware(nil),
+		trace("inner"),
+	)
+
+	headers := http.Header{"User-Agent": {"caller-ua"}}
+	res, err := h.Request(context.Background(), server.URL+"/path", headers, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if _, err := res.Data(); err != nil {
+		t.Fatalf("read body failed: %v", err)
+	}
+	if strings.Join(order, ",") != "outer,inner" {
+		t.Fatalf("order = %v, want outer,inner", order)
+	}
+	checks := map[string]string{
+		"X-Default":     "d",
+		"User-Agent":    "caller-ua",
+		"Authorization": "Bearer token",
+		"X-Signature":   "GET /path",
+	}
+	for key, want := range checks {
+		if got.Get(key) != want {
+			t.Fatalf("header %s = %q, want %q", key, got.Get(key), want)
+		}
+	}
+	if headers.Get("X-Default") != "" {
+		t.Fatal("middleware mutated caller headers")
+	}
+	if observer.starts != 1 || observer.ends != 1 {
+		t.Fatalf("observer starts=%d ends=%d, want 1/1", observer.starts, observer.ends)
+	}
+	if _, ok := h.client.Transport.(interface{ CloseIdleConnections() }); !ok {
+		t.Fatal("wrapped transport lost CloseIdleConnections")
+	}
+}
+
+func TestHTTPSignMiddlewareErrorAbortsRequest(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	errSign := errors.New("no key")
+	h.AddMiddleware(SignMiddleware(func(*http.Request) error { return errSign }))
+
+	if _, err := h.Request(context.Background(), server.URL, nil, nil); !errors.Is(err, errSign) {
+		t.Fatalf("err = %v, want sign error", err)
+	}
+	if calls != 0 {
+		t.Fatalf("server calls = %d, want 0", calls)
+	}
+}
+
+func TestHTTP3AddMiddlewareWrapsTransport(t *testing.T) {
+	h := NewHTTP3(nil)
+	defer h.Dispose()
+	h.AddMiddleware(LogMiddleware(nil))
+	if _, ok := h.client.Transport.(*middlewareTransport); !ok {
+		t.Fatalf("client transport = %T, want *middlewareTransport", h.client.Transport)
+	}
+}
+
+func TestHeaderAndAuthMiddlewareAcceptNilHeader(t *testing.T) {
+	var got http.Header
+	final := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		got = req.Header
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	})
+	chain := HeaderMiddleware(http.Header{"X-Trace": {"t1"}})(
+		AuthMiddleware(map[string]string{"api.example": "Bearer k"})(final))
+
+	req, err := http.NewRequest(http.MethodGet, "https://api.example/v1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = nil
+	if _, err := chain.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if got.Get("X-Trace") != "t1" || got.Get("Authorization") != "Bearer k" {
+		t.Fatalf("headers = %v", got)
+	}
+	if req.Header != nil {
+		t.Fatalf("caller request modified: %v", req.Header)
+	}
+}
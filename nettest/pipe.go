@@ -0,0 +1,328 @@
+// Package nettest 提供测试 net.Client 使用方的内存连接: Pipe 返回一对相连的 Conn,
+// 可注入延迟、丢包、乱序、重复、读停顿与强制断开, 用于确定性地测试心跳、超时与重连.
+package nettest
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// ErrPipeClosed: 任一端 Close 后两端的 Read / Write 返回此错误.
+var ErrPipeClosed = fmt.Errorf("nettest pipe closed")
+
+// Config 配置 Pipe. 概率类故障由 Seed 决定的伪随机数驱动, 同一 Seed 与相同的调用序列
+// 得到相同结果. 各字段对两个方向同时生效, 运行期可用 Conn 上的方法按方向调整.
+type Config struct {
+	Latency       time.Duration // 每条消息的投递延迟
+	Jitter        time.Duration // 在 Latency 上叠加 [0, Jitter) 的随机延迟, 不改变投递顺序
+	DropRate      float64       // 每条消息被丢弃的概率
+	DuplicateRate float64       // 每条消息被投递两次的概率
+	ReorderRate   float64       // 每条消息被扣留到下一条之后投递的概率
+	Seed          uint64
+}
+
+// pipe 是两端共享的关闭状态与随机源.
+type pipe struct {
+	locker sync.Mutex
+	rand   *rand.Rand
+	err    error
+	done   chan struct{}
+}
+
+func (p *pipe) float64() float64 {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	return p.rand.Float64()
+}
+
+func (p *pipe) int64N(n int64) int64 {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	return p.rand.Int64N(n)
+}
+
+func (p *pipe) close(err error) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if p.err == nil {
+		p.err = err
+		close(p.done)
+	}
+}
+
+func (p *pipe) closedErr() error {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	return p.err
+}
+
+type entry[M any] struct {
+	data M
+	at   time.Time
+}
+
+// Conn 是 Pipe 的一端, 实现 net.Conn[M]、net.ConnHeart[M] 与 net.ConnHeartAck[M].
+// 写入的消息进入对端的接收队列, 写侧故障 (DropNext / DuplicateNext / ReorderNext /
+// SetLatency) 作用于本端发出的消息, 读侧故障 (Stall) 作用于本端的读取.
+type Conn[M any] struct {
+	pipe *pipe
+	peer *Conn[M]
+
+	locker    sync.Mutex
+	changed   chan struct{} // 接收队列或 stalled 变化时关闭并替换, 唤醒 Read
+	queue     []entry[M]
+	stalled   bool
+	held      *entry[M] // ReorderNext 扣留的消息, 随下一条消息之后投递
+	lastAt    time.Time // 最近一条入队消息的投递时间, 保证 Jitter 不打乱顺序
+	latency   time.Duration
+	jitter    time.Duration
+	drop      int
+	duplicate int
+	reorder   int
+	config    Config
+
+	handler func(ctx context.Context, data M)
+	heart   func(connect bool, count uint64) (M, time.Time, bool)
+	ack     func(data M) bool
+}
+
+// Pipe 创建一对相连的内存连接. config 为 nil 时不注入任何故障.
+func Pipe[M any](config *Config) (*Conn[M], *Conn[M]) {
+	if config == nil {
+		config = &Config{}
+	}
+	shared := &pipe{
+		rand: rand.New(rand.NewPCG(config.Seed, config.Seed^0x9e3779b97f4a7c15)),
+		done: make(chan struct{}),
+	}
+	a := &Conn[M]{pipe: shared, changed: make(chan struct{}), latency: config.Latency, jitter: config.Jitter, config: *config}
+	b := &Conn[M]{pipe: shared, changed: make(chan struct{}), latency: config.Latency, jitter: config.Jitter, config: *config}
+	a.peer, b.peer = b, a
+	return a, b
+}
+
+// SetHandler 设置 Handle 的回调 (未匹配到请求的消息). nil 时丢弃.
+func (c *Conn[M]) SetHandler(handler func(ctx context.Context, data M)) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.handler = handler
+}
+
+// SetHeart 设置 Heart 的实现. 未设置时与 net.Client 对无心跳连接的默认行为一致.
+func (c *Conn[M]) SetHeart(heart func(connect bool, count uint64) (M, time.Time, bool)) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.heart = heart
+}
+
+// SetHeartAck 设置 HeartAck 的实现, 用于测试 RTT 统计. 未设置时不识别任何应答.
+func (c *Conn[M]) SetHeartAck(ack func(data M) bool) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.ack = ack
+}
+
+// SetLatency 调整本端发出消息的延迟与抖动.
+func (c *Conn[M]) SetLatency(latency, jitter time.Duration) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.latency, c.jitter = latency, jitter
+}
+
+// DropNext 丢弃本端接下来发出的 n 条消息 (Write 仍返回 nil).
+func (c *Conn[M]) DropNext(n int) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.drop += n
+}
+
+// DuplicateNext 让本端接下来发出的 n 条消息各投递两次.
+func (c *Conn[M]) DuplicateNext(n int) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.duplicate += n
+}
+
+// ReorderNext 扣留本端接下来发出的 n 条消息, 每条在其后一条消息之后投递.
+// 扣留期间没有后续消息时, 该消息一直不会投递.
+func (c *Conn[M]) ReorderNext(n int) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.reorder += n
+}
+
+// Stall 让本端的 Read 停止返回 (消息继续在队列中累积), 直到 Resume.
+func (c *Conn[M]) Stall() {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.stalled = true
+	c.notifyLocked()
+}
+
+// Resume 恢复本端的 Read.
+func (c *Conn[M]) Resume() {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.stalled = false
+	c.notifyLocked()
+}
+
+// Pending 返回本端接收队列中尚未被读取的消息数 (含未到投递时间的).
+func (c *Conn[M]) Pending() int {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return len(c.queue)
+}
+
+// Break 模拟连接异常断开: 两端的 Read / Write 返回 err (nil 时为 ErrPipeClosed),
+// 队列中的消息被丢弃.
+func (c *Conn[M]) Break(err error) {
+	if err == nil {
+		err = ErrPipeClosed
+	}
+	c.pipe.close(err)
+}
+
+// Close 实现 net.Conn: 关闭两端, 幂等.
+func (c *Conn[M]) Close(ctx context.Context) error {
+	c.pipe.close(ErrPipeClosed)
+	return nil
+}
+
+// Read 实现 net.Conn: 阻塞到有已到投递时间的消息、连接关闭或 ctx 结束.
+func (c *Conn[M]) Read(ctx context.Context) (M, error) {
+	var zeroM M
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		if err := c.pipe.closedErr(); err != nil {
+			return zeroM, err
+		}
+		c.locker.Lock()
+		var wait <-chan time.Time
+		if !c.stalled && len(c.queue) > 0 {
+			delay := time.Until(c.queue[0].at)
+			if delay <= 0 {
+				data := c.queue[0].data
+				c.queue[0] = entry[M]{}
+				c.queue = c.queue[1:]
+				c.locker.Unlock()
+				return data, nil
+			}
+			if timer == nil {
+				timer = time.NewTimer(delay)
+			} else {
+				timer.Reset(delay)
+			}
+			wait = timer.C
+		}
+		changed := c.changed
+		c.locker.Unlock()
+		select {
+		case <-changed:
+		case <-wait:
+		case <-c.pipe.done:
+		case <-ctx.Done():
+			return zeroM, ctx.Err()
+		}
+	}
+}
+
+// Write 实现 net.Conn: 按本端的故障设置把消息放入对端的接收队列.
+func (c *Conn[M]) Write(ctx context.Context, data M) error {
+	if err := c.pipe.closedErr(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.locker.Lock()
+	drop := c.take(&c.drop, c.config.DropRate)
+	duplicate := !drop && c.take(&c.duplicate, c.config.DuplicateRate)
+	reorder := !drop && c.held == nil && c.take(&c.reorder, c.config.ReorderRate)
+	latency, jitter := c.latency, c.jitter
+	held := c.held
+	if reorder {
+		c.held = &entry[M]{data: data}
+	} else if !drop {
+		c.held = nil
+	}
+	c.locker.Unlock()
+	if drop || reorder {
+		return nil
+	}
+	c.peer.enqueue(data, latency, jitter)
+	if duplicate {
+		c.peer.enqueue(data, latency, jitter)
+	}
+	if held != nil {
+		c.peer.enqueue(held.data, latency, jitter)
+	}
+	return nil
+}
+
+// take 消耗一次确定性故障计数, 否则按概率决定. 必须持有 locker.
+func (c *Conn[M]) take(count *int, rate float64) bool {
+	if *count > 0 {
+		*count--
+		return true
+	}
+	return rate > 0 && c.pipe.float64() < rate
+}
+
+func (c *Conn[M]) enqueue(data M, latency, jitter time.Duration) {
+	if jitter > 0 {
+		latency += time.Duration(c.pipe.int64N(int64(jitter)))
+	}
+	at := time.Now().Add(latency)
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	if at.Before(c.lastAt) {
+		at = c.lastAt
+	}
+	c.lastAt = at
+	c.queue = append(c.queue, entry[M]{data: data, at: at})
+	c.notifyLocked()
+}
+
+func (c *Conn[M]) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Handle 实现 net.Conn.
+func (c *Conn[M]) Handle(ctx context.Context, data M) {
+	c.locker.Lock()
+	handler := c.handler
+	c.locker.Unlock()
+	if handler != nil {
+		handler(ctx, data)
+	}
+}
+
+// Heart 实现 net.ConnHeart.
+func (c *Conn[M]) Heart(connect bool, count uint64) (M, time.Time, bool) {
+	c.locker.Lock()
+	heart := c.heart
+	c.locker.Unlock()
+	if heart != nil {
+		return heart(connect, count)
+	}
+	var zeroM M
+	return zeroM, time.Now().Add(60 * time.Second), false
+}
+
+// HeartAck 实现 net.ConnHeartAck.
+func (c *Conn[M]) HeartAck(data M) bool {
+	c.locker.Lock()
+	ack := c.ack
+	c.locker.Unlock()
+	return ack != nil && ack(data)
+}
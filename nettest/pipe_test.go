@@ -0,0 +1,185 @@
+package nettest
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zdypro888/net"
+)
+
+type message struct {
+	ID    string
+	Value string
+}
+
+func (m message) Id() (any, bool) {
+	return m.ID, m.ID != ""
+}
+
+func readN(t *testing.T, conn *Conn[int], n int) []int {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	values := make([]int, 0, n)
+	for range n {
+		value, err := conn.Read(ctx)
+		if err != nil {
+			t.Fatalf("Read after %v failed: %v", values, err)
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func TestPipeDeterministicFaults(t *testing.T) {
+	a, b := Pipe[int](nil)
+	ctx := context.Background()
+	a.DropNext(1)
+	a.DuplicateNext(1)
+	for i := 1; i <= 2; i++ {
+		if err := a.Write(ctx, i); err != nil {
+			t.Fatalf("Write(%d) failed: %v", i, err)
+		}
+	}
+	a.ReorderNext(1)
+	for i := 3; i <= 4; i++ {
+		if err := a.Write(ctx, i); err != nil {
+			t.Fatalf("Write(%d) failed: %v", i, err)
+		}
+	}
+	got := readN(t, b, 4)
+	want := []int{2, 2, 4, 3}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("received %v, want %v", got, want)
+		}
+	}
+
+	a.SetLatency(50*time.Millisecond, 0)
+	start := time.Now()
+	if err := a.Write(ctx, 5); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	readN(t, b, 1)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("latency not applied: %v", elapsed)
+	}
+}
+
+func TestPipeSeededFaultsAreReproducible(t *testing.T) {
+	run := func() []int {
+		a, b := Pipe[int](&Config{DropRate: 0.3, DuplicateRate: 0.2, Seed: 42})
+		for i := range 50 {
+			if err := a.Write(context.Background(), i); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+		}
+		return readN(t, b, b.Pending())
+	}
+	first, second := run(), run()
+	if len(first) == 50 || len(first) != len(second) {
+		t.Fatalf("unexpected runs: %d vs %d messages", len(first), len(second))
+	}
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("runs differ at %d: %v vs %v", i, first, second)
+		}
+	}
+}
+
+func TestPipeStallAndBreak(t *testing.T) {
+	a, b := Pipe[int](nil)
+	b.Stall()
+	if err := a.Write(context.Background(), 1); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	_, err := b.Read(ctx)
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("stalled Read = %v, want deadline", err)
+	}
+	b.Resume()
+	readN(t, b, 1)
+
+	broken := errors.New("link down")
+	a.Break(broken)
+	if _, err := b.Read(context.Background()); !errors.Is(err, broken) {
+		t.Fatalf("Read after Break = %v, want %v", err, broken)
+	}
+	if err := b.Write(context.Background(), 2); !errors.Is(err, broken) {
+		t.Fatalf("Write after Break = %v, want %v", err, broken)
+	}
+}
+
+func TestPipeWithClient(t *testing.T) {
+	clientSide, serverSide := Pipe[message](nil)
+	heartbeats := make(chan struct{}, 16)
+	var heartStopped atomic.Bool
+	clientSide.SetHeart(func(connect bool, count uint64) (message, time.Time, bool) {
+		if heartStopped.Load() {
+			return message{}, time.Now().Add(time.Hour), false
+		}
+		return message{Value: "ping"}, time.Now().Add(10 * time.Millisecond), !connect
+	})
+	// 对端: 回显请求, 记录心跳.
+	serverSide.SetHandler(func(ctx context.Context, msg message) {
+		if msg.Value == "ping" {
+			select {
+			case heartbeats <- struct{}{}:
+			default:
+			}
+			return
+		}
+		if err := serverSide.Write(ctx, message{ID: msg.ID, Value: msg.Value + "-resp"}); err != nil {
+			t.Errorf("echo failed: %v", err)
+		}
+	})
+	server := net.NewClient[message, *Conn[message]]()
+	server.Reset(context.Background(), serverSide)
+	defer func() { _ = server.Close() }()
+	client := net.NewClient[message, *Conn[message]]()
+	client.Reset(context.Background(), clientSide)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := client.Request(ctx, message{ID: "1", Value: "hello"})
+	if err != nil || resp.Value != "hello-resp" {
+		t.Fatalf("Request: resp=%#v err=%v", resp, err)
+	}
+	select {
+	case <-heartbeats:
+	case <-ctx.Done():
+		t.Fatal("heartbeat was not delivered")
+	}
+	// 停止心跳: 否则 Break 后心跳可能先于在途请求写到断开的管道, 以 ErrPipeClosed 关闭连接.
+	heartStopped.Store(true)
+
+	// 丢弃下一条响应: 请求按 caller 的 ctx 超时.
+	serverSide.DropNext(1)
+	shortCtx, shortCancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	_, err = client.Request(shortCtx, message{ID: "2", Value: "lost"})
+	shortCancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("dropped response: err=%v, want deadline", err)
+	}
+
+	// 强制断开: 在途请求拿到 ErrConnectionClosed.
+	serverSide.Stall()
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.Request(ctx, message{ID: "3", Value: "pending"})
+		result <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	clientSide.Break(nil)
+	if err := <-result; !errors.Is(err, net.ErrConnectionClosed) {
+		t.Fatalf("request across Break: err=%v, want ErrConnectionClosed", err)
+	}
+	if err := client.Close(); err != nil && !errors.Is(err, net.ErrConnectionClosed) && !errors.Is(err, ErrPipeClosed) {
+		t.Fatalf("Close returned %v", err)
+	}
+}
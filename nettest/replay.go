@@ -0,0 +1,157 @@
+package nettest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/zdypro888/net"
+)
+
+// ErrReplayMismatch: Replay 设置了 SetMatch 时, 写入的消息与录制中对应位置的写入不一致.
+var ErrReplayMismatch = fmt.Errorf("nettest replay write mismatch")
+
+// Replay 按 net.Recorder 录制的事件回放对端: 实现 net.Conn[M], Read 依次返回录制中
+// 方向为 read 的消息, 每条都要等到录制里它之前的写入次数已经写满才返回, 使响应不会早于
+// 对应的请求. 录制的消息读完后 Read 阻塞到 ctx 结束或 Close.
+//
+// 请求与响应按 NotifyMessage.Id 匹配, 回放时请求 Id 必须与录制一致. Replay 不实现心跳,
+// 录制中的心跳等与时序相关的写入应在回放前从事件中过滤掉, 否则会一直占着写入计数.
+type Replay[M any] struct {
+	locker   sync.Mutex
+	changed  chan struct{} // 写入计数或关闭状态变化时关闭并替换, 唤醒 Read
+	reads    []replayRead[M]
+	expected []M // 录制中的写入, 按顺序
+	written  []M
+	next     int // 下一条要返回的 reads 下标
+	match    func(want, got M) bool
+	handler  func(ctx context.Context, data M)
+	closed   bool
+	done     chan struct{}
+	finished bool
+}
+
+type replayRead[M any] struct {
+	data   M
+	writes int // 返回本条前需要的写入次数
+}
+
+// NewReplay 用录制的事件创建 Replay, 通常来自 net.ReadRecords.
+func NewReplay[M any](events []net.TapEvent[M]) *Replay[M] {
+	replay := &Replay[M]{changed: make(chan struct{}), done: make(chan struct{})}
+	for _, event := range events {
+		switch event.Direction {
+		case net.TapRead:
+			replay.reads = append(replay.reads, replayRead[M]{data: event.Data, writes: len(replay.expected)})
+		case net.TapWrite:
+			replay.expected = append(replay.expected, event.Data)
+		}
+	}
+	replay.checkFinishedLocked()
+	return replay
+}
+
+// SetMatch 设置写入校验: 第 n 次写入与录制中的第 n 条写入比较, 不一致时 Write 返回
+// ErrReplayMismatch. 超出录制的写入不做校验. 未设置时不校验.
+func (r *Replay[M]) SetMatch(match func(want, got M) bool) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	r.match = match
+}
+
+// SetHandler 设置 Handle 的回调 (未匹配到请求的消息). nil 时丢弃.
+func (r *Replay[M]) SetHandler(handler func(ctx context.Context, data M)) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	r.handler = handler
+}
+
+// Written 返回已写入的消息.
+func (r *Replay[M]) Written() []M {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	return append([]M(nil), r.written...)
+}
+
+// Done 在录制的读取全部返回、录制的写入全部写满后关闭.
+func (r *Replay[M]) Done() <-chan struct{} {
+	return r.done
+}
+
+// Close 实现 net.Conn, 幂等.
+func (r *Replay[M]) Close(ctx context.Context) error {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if !r.closed {
+		r.closed = true
+		r.notifyLocked()
+	}
+	return nil
+}
+
+// Read 实现 net.Conn.
+func (r *Replay[M]) Read(ctx context.Context) (M, error) {
+	var zeroM M
+	for {
+		r.locker.Lock()
+		if r.closed {
+			r.locker.Unlock()
+			return zeroM, ErrPipeClosed
+		}
+		if r.next < len(r.reads) && len(r.written) >= r.reads[r.next].writes {
+			data := r.reads[r.next].data
+			r.next++
+			r.checkFinishedLocked()
+			r.locker.Unlock()
+			return data, nil
+		}
+		changed := r.changed
+		r.locker.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return zeroM, ctx.Err()
+		}
+	}
+}
+
+// Write 实现 net.Conn.
+func (r *Replay[M]) Write(ctx context.Context, data M) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if r.closed {
+		return ErrPipeClosed
+	}
+	if n := len(r.written); r.match != nil && n < len(r.expected) && !r.match(r.expected[n], data) {
+		return fmt.Errorf("%w: write %d: want %+v, got %+v", ErrReplayMismatch, n, r.expected[n], data)
+	}
+	r.written = append(r.written, data)
+	r.checkFinishedLocked()
+	r.notifyLocked()
+	return nil
+}
+
+// Handle 实现 net.Conn.
+func (r *Replay[M]) Handle(ctx context.Context, data M) {
+	r.locker.Lock()
+	handler := r.handler
+	r.locker.Unlock()
+	if handler != nil {
+		handler(ctx, data)
+	}
+}
+
+func (r *Replay[M]) notifyLocked() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+func (r *Replay[M]) checkFinishedLocked() {
+	if !r.finished && r.next == len(r.reads) && len(r.written) >= len(r.expected) {
+		r.finished = true
+		close(r.done)
+	}
+}
@@ -0,0 +1,106 @@
+package nettest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zdypro888/net"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	// 录制: 对端回显请求, 并在第一条响应后推送一条无 Id 的通知.
+	clientSide, serverSide := Pipe[message](nil)
+	serverSide.SetHandler(func(ctx context.Context, msg message) {
+		_ = serverSide.Write(ctx, message{ID: msg.ID, Value: msg.Value + "-resp"})
+		if msg.ID == "1" {
+			_ = serverSide.Write(ctx, message{Value: "push"})
+		}
+	})
+	server := net.NewClient[message, *Conn[message]]()
+	server.Reset(context.Background(), serverSide)
+	defer func() { _ = server.Close() }()
+
+	var buf bytes.Buffer
+	recorder := net.NewRecorder[message](&buf)
+	client := net.NewClient[message, *Conn[message]]()
+	client.SetTap(recorder.Tap)
+	pushed := make(chan message, 1)
+	clientSide.SetHandler(func(ctx context.Context, msg message) { pushed <- msg })
+	client.Reset(context.Background(), clientSide)
+	for _, id := range []string{"1", "2"} {
+		if _, err := client.Request(ctx, message{ID: id, Value: "v" + id}); err != nil {
+			t.Fatalf("recorded Request %s failed: %v", id, err)
+		}
+	}
+	<-pushed
+	_ = client.Close()
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("recorder error: %v", err)
+	}
+
+	events, err := net.ReadRecords[message](&buf)
+	if err != nil {
+		t.Fatalf("ReadRecords failed: %v", err)
+	}
+	if len(events) != 5 {
+		t.Fatalf("recorded %d events, want 5: %+v", len(events), events)
+	}
+
+	// 回放: 不需要对端, 响应与推送都来自录制.
+	replay := NewReplay(events)
+	replay.SetMatch(func(want, got message) bool { return want == got })
+	replayed := make(chan message, 1)
+	replay.SetHandler(func(ctx context.Context, msg message) { replayed <- msg })
+	replayClient := net.NewClient[message, *Replay[message]]()
+	replayClient.Reset(context.Background(), replay)
+	defer func() { _ = replayClient.Close() }()
+	for _, id := range []string{"1", "2"} {
+		resp, err := replayClient.Request(ctx, message{ID: id, Value: "v" + id})
+		if err != nil || resp.Value != "v"+id+"-resp" {
+			t.Fatalf("replayed Request %s: resp=%+v err=%v", id, resp, err)
+		}
+	}
+	select {
+	case msg := <-replayed:
+		if msg.Value != "push" {
+			t.Fatalf("replayed push = %+v", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("push was not replayed")
+	}
+	select {
+	case <-replay.Done():
+	case <-ctx.Done():
+		t.Fatal("replay did not finish")
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	replay := NewReplay([]net.TapEvent[message]{
+		{Direction: net.TapWrite, Data: message{ID: "1", Value: "hello"}},
+		{Direction: net.TapRead, Data: message{ID: "1", Value: "world"}},
+	})
+	replay.SetMatch(func(want, got message) bool { return want == got })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	_, err := replay.Read(ctx)
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Read before the recorded write = %v, want deadline", err)
+	}
+	if err := replay.Write(context.Background(), message{ID: "1", Value: "other"}); !errors.Is(err, ErrReplayMismatch) {
+		t.Fatalf("mismatched Write = %v, want ErrReplayMismatch", err)
+	}
+	if err := replay.Write(context.Background(), message{ID: "1", Value: "hello"}); err != nil {
+		t.Fatalf("matching Write failed: %v", err)
+	}
+	if msg, err := replay.Read(context.Background()); err != nil || msg.Value != "world" {
+		t.Fatalf("Read after write: msg=%+v err=%v", msg, err)
+	}
+}
@@ -0,0 +1,69 @@
+package net
+
+import (
+	"time"
+
+	"github.com/zdypro888/net/metrics"
+)
+
+// observerComponent 是 Client 上报给 metrics.Observer 的 component.
+const observerComponent = "net"
+
+// SetObserver 设置观测回调, nil 关闭. 上报的 component 为 "net", 多个 Client 共用一个
+// Observer 时用 metrics.Named 区分. 上报的事件:
+//   - Request / RequestUnsafe / RequestCallbackUnsafe 的开始与结束 (含拦截器耗时);
+//   - 每条读到与写出的消息, 字节数取自 MessageSizer, 未实现时为 0;
+//   - Supervise 断线后的重连;
+//   - 每次 Reset 建立的连接作为一个会话, 连接结束时 SessionClose.
+//
+// 可随时调用, 立即生效 (已建立的连接仍按建立时的 Observer 上报 SessionClose).
+func (client *Client[M, T]) SetObserver(observer metrics.Observer) {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	if observer == nil {
+		client.observer.Store(nil)
+		return
+	}
+	client.observer.Store(&observer)
+}
+
+func (client *Client[M, T]) loadObserver() metrics.Observer {
+	if observer := client.observer.Load(); observer != nil {
+		return *observer
+	}
+	return nil
+}
+
+// traffic 把一条读写消息交给 SetTap 与 SetObserver 设置的回调.
+func (client *Client[M, T]) traffic(direction TapDirection, data M) {
+	client.tapMessage(direction, data)
+	observer := client.loadObserver()
+	if observer == nil {
+		return
+	}
+	if direction == TapRead {
+		observer.MessageReceived(observerComponent, messageSize(data))
+	} else {
+		observer.MessageSent(observerComponent, messageSize(data))
+	}
+}
+
+// observeRequest 上报请求开始, 返回上报结束的函数; 未设置 Observer 时返回 nil.
+func (client *Client[M, T]) observeRequest() func(err error) {
+	observer := client.loadObserver()
+	if observer == nil {
+		return nil
+	}
+	start := time.Now()
+	observer.RequestStart(observerComponent)
+	return func(err error) {
+		observer.RequestEnd(observerComponent, time.Since(start), err)
+	}
+}
+
+func messageSize(data any) int {
+	if sizer, ok := data.(MessageSizer); ok {
+		return sizer.MessageSize()
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zdypro888/net/metrics"
+)
+
+type recordingObserver struct {
+	metrics.Nop
+	mu     sync.Mutex
+	events []string
+	errs   []error
+}
+
+func (o *recordingObserver) record(event string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, event)
+}
+
+func (o *recordingObserver) RequestStart(component string) { o.record("start:" + component) }
+func (o *recordingObserver) RequestEnd(component string, duration time.Duration, err error) {
+	o.mu.Lock()
+	o.errs = append(o.errs, err)
+	o.mu.Unlock()
+	o.record("end:" + component)
+}
+func (o *recordingObserver) MessageSent(component string, bytes int)     { o.record("sent") }
+func (o *recordingObserver) MessageReceived(component string, bytes int) { o.record("received") }
+func (o *recordingObserver) SessionOpen(component string)                { o.record("open") }
+func (o *recordingObserver) SessionClose(component string)               { o.record("close") }
+
+func (o *recordingObserver) count(event string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for _, e := range o.events {
+		if e == event {
+			n++
+		}
+	}
+	return n
+}
+
+func TestClientObserverReportsRequestsAndMessages(t *testing.T) {
+	conn := newFakeConn()
+	observer := &recordingObserver{}
+	client := NewClient[testMessage, *fakeConn]()
+	client.SetObserver(observer)
+	client.Reset(context.Background(), conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go func() {
+		req := <-conn.writeCh
+		conn.readCh <- testMessage{id: req.id, value: "response"}
+	}()
+	if _, err := client.Request(ctx, testMessage{id: "req-1"}); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	shortCtx, shortCancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	_, err := client.Request(shortCtx, testMessage{id: "req-2"})
+	shortCancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unanswered Request = %v, want deadline", err)
+	}
+	checkClose(t, "client", client.Close)
+
+	if got := observer.count("start:net"); got != 2 {
+		t.Fatalf("RequestStart called %d times, want 2", got)
+	}
+	observer.mu.Lock()
+	errs := observer.errs
+	observer.mu.Unlock()
+	if len(errs) != 2 || errs[0] != nil || !errors.Is(errs[1], context.DeadlineExceeded) {
+		t.Fatalf("RequestEnd errors = %v", errs)
+	}
+	if sent, received := observer.count("sent"), observer.count("received"); sent != 2 || received != 1 {
+		t.Fatalf("sent=%d received=%d, want 2 and 1", sent, received)
+	}
+	if open, closed := observer.count("open"), observer.count("close"); open != 1 || closed != 1 {
+		t.Fatalf("session open=%d close=%d, want 1 and 1", open, closed)
+	}
+}
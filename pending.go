@@ -0,0 +1,142 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// ErrRequestCanceled: 请求被 CancelPending / CancelPendingOlder 取消, 等待中的 caller
+// (Request / RequestCallback / Stream) 以此错误返回.
+var ErrRequestCanceled = fmt.Errorf("request canceled")
+
+// ConnCancel 是可选接口: Conn 实现时, CancelPending / CancelPendingOlder 取消请求后
+// 用 CancelMessage 构造取消消息发给对端, 让对端停止处理. 返回 false 表示该请求不发送.
+type ConnCancel[T any] interface {
+	// CancelMessage 构造请求 id 的取消消息. 在唯一的处理协程中调用.
+	CancelMessage(id any) (T, bool)
+}
+
+// PendingRequest 是一个已发出、等待响应的请求快照.
+type PendingRequest struct {
+	ID       any           // NotifyMessage.Id(), 批量请求为 NotifyBatchMessage.Ids() 的第一个
+	Enqueued time.Time     // 进入发送队列的时间
+	Age      time.Duration // 快照时距 Enqueued 的时长
+	Stream   bool          // 是否为 Stream 请求
+}
+
+// PendingRequests 返回当前连接上等待响应的请求, 按 Enqueued 从早到晚排序. 已被 caller
+// 放弃 (ctx 结束) 但尚未清理的请求不在其中; 仍在发送队列中的请求尚未登记, 也不在其中.
+// 在 asyncGo 中执行, 未连接或连接已关闭时返回错误. 线程安全.
+func (client *Client[M, T]) PendingRequests(ctx context.Context) ([]PendingRequest, error) {
+	var pending []PendingRequest
+	err := client.inspectPending(ctx, func(ctx context.Context, conn T, notifys map[any]*asyncMessage[M]) {
+		now := time.Now()
+		// 批量请求以多个 id 登记同一 message, 只报告一次.
+		seen := make(map[*asyncMessage[M]]bool, len(notifys))
+		for _, message := range notifys {
+			if message.canceled.Load() || seen[message] {
+				continue
+			}
+			seen[message] = true
+			pending = append(pending, PendingRequest{
+				ID:       notifyIDs(message.Data)[0],
+				Enqueued: message.enqueued,
+				Age:      now.Sub(message.enqueued),
+				Stream:   message.stream != nil,
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(pending, func(a, b PendingRequest) int {
+		return a.Enqueued.Compare(b.Enqueued)
+	})
+	return pending, nil
+}
+
+// CancelPending 取消 id 对应的等待中请求: caller 拿到 ErrRequestCanceled, 之后到达的
+// 响应按未匹配消息交给 Handle. Conn 实现 ConnCancel 时同时向对端发送取消消息.
+// 批量请求按其中任一 id 取消整个请求, 并为每个 id 发送取消消息.
+// 返回是否找到该请求. 线程安全.
+func (client *Client[M, T]) CancelPending(ctx context.Context, id any) (bool, error) {
+	canceled, err := client.cancelPending(ctx, func(notifyId any, message *asyncMessage[M], now time.Time) bool {
+		return notifyId == id
+	})
+	return canceled > 0, err
+}
+
+// CancelPendingOlder 取消所有进入发送队列超过 age 的等待中请求, 语义同 CancelPending.
+// 返回取消的数量. 线程安全.
+func (client *Client[M, T]) CancelPendingOlder(ctx context.Context, age time.Duration) (int, error) {
+	return client.cancelPending(ctx, func(notifyId any, message *asyncMessage[M], now time.Time) bool {
+		return now.Sub(message.enqueued) >= age
+	})
+}
+
+func (client *Client[M, T]) cancelPending(ctx context.Context, match func(id any, message *asyncMessage[M], now time.Time) bool) (int, error) {
+	canceled := 0
+	err := client.inspectPending(ctx, func(ctx context.Context, conn T, notifys map[any]*asyncMessage[M]) {
+		var zeroM M
+		cancelConn, hasCancel := any(conn).(ConnCancel[M])
+		now := time.Now()
+		for id, message := range notifys {
+			if message.canceled.Load() || !match(id, message, now) {
+				continue
+			}
+			// 先移除全部 id: 遍历中删除的项不会再被访问, 批量请求只取消一次.
+			unregisterNotify(notifys, message)
+			message.Response(zeroM, ErrRequestCanceled)
+			canceled++
+			if !hasCancel {
+				continue
+			}
+			for _, notifyId := range notifyIDs(message.Data) {
+				if cancelMessage, ok := cancelConn.CancelMessage(notifyId); ok {
+					client.traffic(TapWrite, cancelMessage)
+					// 写失败说明连接已坏, receiveGo 会随之退出, 这里只记日志.
+					if err := conn.Write(ctx, cancelMessage); err != nil {
+						slog.Warn("net client write cancel message failed", slog.Any("id", notifyId), slog.Any("err", err))
+					}
+				}
+			}
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return canceled, nil
+}
+
+// inspectPending 在 asyncGo 中执行 fn 并等待其完成. asyncGo 先退出时返回 ErrConnectionClosed.
+func (client *Client[M, T]) inspectPending(ctx context.Context, fn func(ctx context.Context, conn T, notifys map[any]*asyncMessage[M])) error {
+	done := make(chan struct{})
+	request := &asynRequest[M, T]{Command: asyncCommandPending, pending: func(ctx context.Context, conn T, notifys map[any]*asyncMessage[M]) {
+		defer close(done)
+		fn(ctx, conn, notifys)
+	}}
+	client.locker.RLock()
+	stopChan := client.stopChan
+	err := client.async(ctx, request)
+	client.locker.RUnlock()
+	if err != nil {
+		return err
+	}
+	select {
+	case <-done:
+		return nil
+	case <-stopChan:
+		// Close 先于 asyncGo 退出关闭 stopChan, fn 可能已执行完: 以 done 为准.
+		select {
+		case <-done:
+			return nil
+		default:
+			return ErrConnectionClosed
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
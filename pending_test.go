@@ -0,0 +1,88 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// cancelConn 为每个被取消的请求发送 "cancel:<id>".
+type cancelConn struct {
+	*fakeConn
+}
+
+func (c *cancelConn) CancelMessage(id any) (testMessage, bool) {
+	return testMessage{value: fmt.Sprintf("cancel:%v", id)}, true
+}
+
+func TestClientCancelPending(t *testing.T) {
+	conn := &cancelConn{fakeConn: newFakeConn()}
+	client := NewClient[testMessage, *cancelConn]()
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	results := make(map[string]chan error)
+	for _, id := range []string{"a", "b", "c"} {
+		result := make(chan error, 1)
+		results[id] = result
+		go func() {
+			_, err := client.Request(ctx, testMessage{id: id})
+			result <- err
+		}()
+		// 等请求写出, 保证 Enqueued 有先后.
+		<-conn.writeCh
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	pending, err := client.PendingRequests(ctx)
+	if err != nil {
+		t.Fatalf("PendingRequests failed: %v", err)
+	}
+	if len(pending) != 3 || pending[0].ID != "a" || pending[2].ID != "c" || pending[0].Age < pending[2].Age {
+		t.Fatalf("unexpected pending: %+v", pending)
+	}
+
+	found, err := client.CancelPending(ctx, "b")
+	if err != nil || !found {
+		t.Fatalf("CancelPending(b) = %v, %v", found, err)
+	}
+	if err := <-results["b"]; !errors.Is(err, ErrRequestCanceled) {
+		t.Fatalf("canceled request err = %v, want ErrRequestCanceled", err)
+	}
+	if msg := <-conn.writeCh; msg.value != "cancel:b" {
+		t.Fatalf("unexpected cancel message: %+v", msg)
+	}
+	if found, _ := client.CancelPending(ctx, "b"); found {
+		t.Fatal("CancelPending(b) found the request twice")
+	}
+
+	// 只剩 a / c; 取消阈值之内的不受影响.
+	if n, err := client.CancelPendingOlder(ctx, time.Hour); err != nil || n != 0 {
+		t.Fatalf("CancelPendingOlder(1h) = %d, %v", n, err)
+	}
+	if n, err := client.CancelPendingOlder(ctx, 0); err != nil || n != 2 {
+		t.Fatalf("CancelPendingOlder(0) = %d, %v", n, err)
+	}
+	for _, id := range []string{"a", "c"} {
+		if err := <-results[id]; !errors.Is(err, ErrRequestCanceled) {
+			t.Fatalf("request %s err = %v, want ErrRequestCanceled", id, err)
+		}
+	}
+	if pending, err := client.PendingRequests(ctx); err != nil || len(pending) != 0 {
+		t.Fatalf("PendingRequests after cancel = %+v, %v", pending, err)
+	}
+	if stats := client.Stats(); stats.InFlight != 0 {
+		t.Fatalf("InFlight = %d after cancel", stats.InFlight)
+	}
+}
+
+func TestClientPendingRequestsNotConnected(t *testing.T) {
+	client := NewClient[testMessage, *fakeConn]()
+	if _, err := client.PendingRequests(context.Background()); !errors.Is(err, ErrNotConnected) {
+		t.Fatalf("PendingRequests err = %v, want ErrNotConnected", err)
+	}
+}
@@ -0,0 +1,167 @@
+package net
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Priority 是发送请求的优先级. 每个优先级对应 Client 内独立的发送队列, asyncGo 按
+// SetPriorityMode 的策略在队列间调度; 同一优先级内保持 FIFO.
+type Priority int
+
+const (
+	PriorityNormal Priority = iota // 默认: Write / Request 等未指定优先级的请求
+	PriorityHigh                   // 控制消息、应答等需要插队的小消息
+	PriorityLow                    // 批量数据
+)
+
+// PriorityMode 决定多个优先级队列同时有请求时的调度方式.
+type PriorityMode int
+
+const (
+	// PriorityStrict 总是先取高优先级队列 (默认). 高优先级持续有请求时低优先级会饥饿.
+	PriorityStrict PriorityMode = iota
+	// PriorityWeighted 按权重平滑轮询: 各队列都积压时, 取出次数之比等于权重之比.
+	PriorityWeighted
+)
+
+// 发送队列下标, 按优先级从高到低.
+const (
+	laneHigh = iota
+	laneNormal
+	laneLow
+	laneCount
+)
+
+func (priority Priority) lane() int {
+	switch priority {
+	case PriorityHigh:
+		return laneHigh
+	case PriorityLow:
+		return laneLow
+	default:
+		return laneNormal
+	}
+}
+
+type priorityContextKey struct{}
+
+// WithPriority 返回携带发送优先级的 ctx. Write / Request / Stream / RequestCallback /
+// AsyncCall 使用该 ctx 时请求进入对应的优先级队列; 拦截器透传 ctx, 同样生效.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityContextKey{}, priority)
+}
+
+func priorityFromContext(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityContextKey{}).(Priority); ok {
+		return priority
+	}
+	return PriorityNormal
+}
+
+// WritePriority 以指定优先级发送数据, 等同 Write(WithPriority(ctx, priority), data).
+func (client *Client[M, T]) WritePriority(ctx context.Context, priority Priority, data M) error {
+	return client.Write(WithPriority(ctx, priority), data)
+}
+
+// RequestPriority 以指定优先级发送请求并等待响应, 等同 Request(WithPriority(ctx, priority), data).
+// 优先级只影响发送顺序, 不影响响应匹配.
+func (client *Client[M, T]) RequestPriority(ctx context.Context, priority Priority, data M) (M, error) {
+	return client.Request(WithPriority(ctx, priority), data)
+}
+
+// prioritySchedule 是 PriorityWeighted 的权重配置, 整体换新; nil 表示 PriorityStrict.
+type prioritySchedule struct {
+	weights [laneCount]int
+}
+
+// SetPriorityMode 设置优先级队列的调度方式. PriorityWeighted 时 high / normal / low
+// 为各队列权重, <=0 按 1 计; PriorityStrict 忽略权重. 可随时调用, 立即生效.
+func (client *Client[M, T]) SetPriorityMode(mode PriorityMode, high, normal, low int) {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	if mode != PriorityWeighted {
+		client.prioritySchedule.Store(nil)
+		return
+	}
+	client.prioritySchedule.Store(&prioritySchedule{
+		weights: [laneCount]int{max(high, 1), max(normal, 1), max(low, 1)},
+	})
+}
+
+// readyChan 是已关闭的 chan, 用作 select 中"立即可执行"的分支.
+var readyChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// sendLanes 是 asyncGo 侧的优先级发送队列与调度状态, 只在 asyncGo 中使用.
+// asyncGo 的 select 从任一队列取出请求后先暂存 (stashed, 视为该队列的队首), 再由 next
+// 按调度策略挑选, 保证同时就绪的多个队列按优先级而不是 select 的随机顺序执行.
+type sendLanes[M any, T Conn[M]] struct {
+	chans     [laneCount]<-chan *asynRequest[M, T]
+	stashed   *asynRequest[M, T]
+	stashLane int
+	current   [laneCount]int // 平滑加权轮询的当前值
+	schedule  *atomic.Pointer[prioritySchedule]
+}
+
+// stash 暂存 select 取出的请求并返回按调度策略应执行的请求 (可能是更高优先级队列的队首).
+func (lanes *sendLanes[M, T]) stash(lane int, request *asynRequest[M, T]) *asynRequest[M, T] {
+	lanes.stashed, lanes.stashLane = request, lane
+	return lanes.next()
+}
+
+// next 按调度策略非阻塞地取出下一条请求, 所有队列为空时返回 nil.
+func (lanes *sendLanes[M, T]) next() *asynRequest[M, T] {
+	var ready [laneCount]bool
+	for lane := range laneCount {
+		ready[lane] = (lanes.stashed != nil && lanes.stashLane == lane) || len(lanes.chans[lane]) > 0
+	}
+	lane := lanes.pick(ready)
+	if lane < 0 {
+		return nil
+	}
+	if lanes.stashed != nil && lanes.stashLane == lane {
+		request := lanes.stashed
+		lanes.stashed = nil
+		return request
+	}
+	select {
+	case request, ok := <-lanes.chans[lane]:
+		if ok {
+			return request
+		}
+	default:
+	}
+	return nil
+}
+
+func (lanes *sendLanes[M, T]) pick(ready [laneCount]bool) int {
+	schedule := lanes.schedule.Load()
+	if schedule == nil {
+		for lane := range laneCount {
+			if ready[lane] {
+				return lane
+			}
+		}
+		return -1
+	}
+	// 平滑加权轮询 (nginx SWRR): 只在就绪的队列间分配.
+	best, total := -1, 0
+	for lane := range laneCount {
+		if !ready[lane] {
+			continue
+		}
+		lanes.current[lane] += schedule.weights[lane]
+		total += schedule.weights[lane]
+		if best < 0 || lanes.current[lane] > lanes.current[best] {
+			best = lane
+		}
+	}
+	if best >= 0 {
+		lanes.current[best] -= total
+	}
+	return best
+}
@@ -0,0 +1,80 @@
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// queueWhileBlocked 卡住 asyncGo, 依次以给定优先级写入 values, 返回释放函数.
+func queueWhileBlocked(t *testing.T, ctx context.Context, client *Client[testMessage, *fakeConn], priorities []Priority, values []string) func() {
+	t.Helper()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	if err := client.AsyncCall(ctx, func(ctx context.Context, conn *fakeConn) {
+		close(started)
+		<-release
+	}); err != nil {
+		t.Fatalf("AsyncCall failed: %v", err)
+	}
+	<-started
+	for i, value := range values {
+		if err := client.WritePriority(ctx, priorities[i], testMessage{value: value}); err != nil {
+			t.Fatalf("WritePriority(%s) failed: %v", value, err)
+		}
+	}
+	return func() { close(release) }
+}
+
+func readWrites(t *testing.T, ctx context.Context, conn *fakeConn, n int) string {
+	t.Helper()
+	order := ""
+	for range n {
+		select {
+		case msg := <-conn.writeCh:
+			order += msg.value
+		case <-ctx.Done():
+			t.Fatalf("only %q written", order)
+		}
+	}
+	return order
+}
+
+func TestClientPriorityStrict(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	release := queueWhileBlocked(t, ctx, client,
+		[]Priority{PriorityLow, PriorityNormal, PriorityLow, PriorityHigh, PriorityNormal, PriorityHigh},
+		[]string{"a", "b", "c", "d", "e", "f"})
+	if stats := client.Stats(); stats.HighLen != 2 || stats.AsynchanLen != 2 || stats.LowLen != 2 {
+		t.Fatalf("lane depths = %d/%d/%d, want 2/2/2", stats.HighLen, stats.AsynchanLen, stats.LowLen)
+	}
+	release()
+	if order := readWrites(t, ctx, conn, 6); order != "dfbeac" {
+		t.Fatalf("write order = %q, want %q", order, "dfbeac")
+	}
+}
+
+func TestClientPriorityWeighted(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.SetPriorityMode(PriorityWeighted, 2, 1, 1)
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	release := queueWhileBlocked(t, ctx, client,
+		[]Priority{PriorityHigh, PriorityHigh, PriorityHigh, PriorityHigh, PriorityLow, PriorityLow},
+		[]string{"h", "h", "h", "h", "l", "l"})
+	release()
+	// 权重 2:1 的平滑轮询: 低优先级不会等到高优先级全部写完.
+	if order := readWrites(t, ctx, conn, 6); order != "hlhhlh" {
+		t.Fatalf("write order = %q, want %q", order, "hlhhlh")
+	}
+}
@@ -72,6 +72,12 @@ func (proxy *Proxy) DialContext(ctx context.Context, network, address string) (n
 	if err != nil {
 		return nil, err
 	}
+	return proxy.dialURL(ctx, proxyURL, network, address)
+}
+
+// dialURL 经 proxyURL 指定的代理建立到 address 的隧道: socks5 / ws 直接拨号, http / https
+// 走 CONNECT.
+func (proxy *Proxy) dialURL(ctx context.Context, proxyURL *url.URL, network, address string) (net.Conn, error) {
 	switch proxyURL.Scheme {
 	case "socks5":
 		d := socks5.NewDialer("tcp", proxyURL.Host)
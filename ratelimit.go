@@ -0,0 +1,164 @@
+package net
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultWriteBatchSize 是 BatchWriter 一次合并写出的默认最大消息数.
+const DefaultWriteBatchSize = 64
+
+// MessageSizer 是可选接口, 为 RateLimit.BytesPerSecond 提供消息的字节数.
+// 未实现的消息按 0 字节计, 只受 MessagesPerSecond 限制.
+type MessageSizer interface {
+	MessageSize() int
+}
+
+// BatchWriter 是可选接口: Conn 实现时, 发送队列中积压了多条消息的情况下 asyncGo 把它们
+// 合并为一次 WriteBatch (例如一次 writev 或一个 websocket 帧), 减少系统调用.
+// 队列不积压时仍逐条调用 Write. 在唯一的处理协程中调用.
+type BatchWriter[T any] interface {
+	// WriteBatch 按顺序写出 data. 返回错误时整批视为失败.
+	WriteBatch(ctx context.Context, data []T) error
+}
+
+// RateLimit 配置 Client 的发送限速 (令牌桶). 两个维度同时生效, <=0 表示该维度不限.
+// 心跳不受限速, 也不消耗令牌.
+type RateLimit struct {
+	MessagesPerSecond float64 // 每秒消息数
+	MessageBurst      int     // 消息突发上限, <=0 时取 max(1, MessagesPerSecond)
+	BytesPerSecond    float64 // 每秒字节数, 需消息实现 MessageSizer
+	ByteBurst         int     // 字节突发上限, <=0 时取 max(1, BytesPerSecond); 单条超过上限的消息在桶满时放行
+}
+
+// SetRateLimit 设置发送限速, 零值关闭. 等待令牌期间 asyncGo 暂停从发送队列取消息
+// (保持顺序, 队列满时 Write / Request 按 ctx 超时), 但继续处理响应与心跳. 可随时调用,
+// 新设置立即生效, 令牌桶从满桶开始.
+func (client *Client[M, T]) SetRateLimit(limit RateLimit) {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	if limit.MessagesPerSecond <= 0 && limit.BytesPerSecond <= 0 {
+		client.rateLimit.Store(nil)
+		return
+	}
+	now := time.Now()
+	client.rateLimit.Store(&rateLimiter{
+		messages: newTokenBucket(limit.MessagesPerSecond, limit.MessageBurst, now),
+		bytes:    newTokenBucket(limit.BytesPerSecond, limit.ByteBurst, now),
+	})
+}
+
+// SetWriteBatchSize 设置 BatchWriter 一次合并的最大消息数, <=0 恢复 DefaultWriteBatchSize.
+// 1 等同于关闭合并.
+func (client *Client[M, T]) SetWriteBatchSize(size int) {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	if size <= 0 {
+		size = DefaultWriteBatchSize
+	}
+	client.writeBatchSize.Store(int64(size))
+}
+
+// rateLimiter 只由 asyncGo 修改; SetRateLimit 整体换新.
+type rateLimiter struct {
+	messages *tokenBucket
+	bytes    *tokenBucket
+}
+
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	size := float64(burst)
+	if burst <= 0 {
+		size = max(1, rate)
+	}
+	return &tokenBucket{rate: rate, burst: size, tokens: size, last: now}
+}
+
+// wait 返回取得 n 个令牌前需要等待的时间, 不消耗令牌. n 超过 burst 时按 burst 计,
+// 取得后令牌数变为负值, 由后续消息偿还.
+func (bucket *tokenBucket) wait(now time.Time, n float64) time.Duration {
+	if bucket == nil {
+		return 0
+	}
+	bucket.tokens = min(bucket.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*bucket.rate)
+	bucket.last = now
+	need := min(n, bucket.burst)
+	if bucket.tokens >= need {
+		return 0
+	}
+	return time.Duration((need - bucket.tokens) / bucket.rate * float64(time.Second))
+}
+
+func (bucket *tokenBucket) take(n float64) {
+	if bucket != nil {
+		bucket.tokens -= n
+	}
+}
+
+// reserve 在两个令牌桶都足够时消耗令牌并返回 0, 否则返回需要等待的时间.
+func (limiter *rateLimiter) reserve(size int) time.Duration {
+	now := time.Now()
+	delay := max(limiter.messages.wait(now, 1), limiter.bytes.wait(now, float64(size)))
+	if delay > 0 {
+		return delay
+	}
+	limiter.messages.take(1)
+	limiter.bytes.take(float64(size))
+	return 0
+}
+
+// reserveWrite 为 data 申请发送令牌, 未限速时返回 0. 只在 asyncGo 中调用.
+func (client *Client[M, T]) reserveWrite(data M) time.Duration {
+	limiter := client.rateLimit.Load()
+	if limiter == nil {
+		return 0
+	}
+	return limiter.reserve(messageSize(data))
+}
+
+// collectBatch 按优先级调度非阻塞地取出已积压的请求追加到 messages, 直到达到批量上限、
+// 队列为空、遇到非发送命令或令牌不足. 未能并入的请求作为 next 返回, 由调用方接着处理.
+// 只在 asyncGo 中调用.
+func (client *Client[M, T]) collectBatch(source func() *asynRequest[M, T], messages []*asyncMessage[M]) ([]*asyncMessage[M], *asynRequest[M, T]) {
+	limit := int(client.writeBatchSize.Load())
+	if limit <= 0 {
+		limit = DefaultWriteBatchSize
+	}
+	for len(messages) < limit {
+		next := source()
+		if next == nil {
+			// 队列为空 (或已关闭, asyncGo 回到循环顶后退出): 先写出已取到的.
+			return messages, nil
+		}
+		if next.Command != AsyncCommandSend || client.reserveWrite(next.Message.Data) > 0 {
+			return messages, next
+		}
+		messages = append(messages, next.Message)
+	}
+	return messages, nil
+}
+
+// writeMessages 写出一条消息, 多条时经 BatchWriter 合并写出.
+func (client *Client[M, T]) writeMessages(ctx context.Context, conn T, batchConn BatchWriter[M], messages []*asyncMessage[M]) error {
+	for _, message := range messages {
+		client.traffic(TapWrite, message.Data)
+	}
+	if len(messages) == 1 {
+		return conn.Write(ctx, messages[0].Data)
+	}
+	data := make([]M, len(messages))
+	for i, message := range messages {
+		data[i] = message.Data
+	}
+	client.writeBatches.Add(1)
+	return batchConn.WriteBatch(ctx, data)
+}
@@ -0,0 +1,120 @@
+package net
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientRateLimitSpacesWrites(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.SetRateLimit(RateLimit{MessagesPerSecond: 50, MessageBurst: 1})
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	for i := range 5 {
+		if err := client.Write(ctx, testMessage{value: string(rune('a' + i))}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	for i := range 5 {
+		select {
+		case msg := <-conn.writeCh:
+			if want := string(rune('a' + i)); msg.value != want {
+				t.Fatalf("write %d = %q, want %q", i, msg.value, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("write %d not sent", i)
+		}
+	}
+	// 首条消耗突发令牌, 其余 4 条各等 20ms.
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Fatalf("writes were not rate limited: %v", elapsed)
+	}
+	if stats := client.Stats(); stats.RateLimited == 0 {
+		t.Fatalf("RateLimited = 0, stats %+v", stats)
+	}
+}
+
+func TestTokenBucketBytes(t *testing.T) {
+	now := time.Now()
+	limiter := &rateLimiter{bytes: newTokenBucket(1000, 100, now)}
+	if delay := limiter.reserve(100); delay != 0 {
+		t.Fatalf("full bucket delayed: %v", delay)
+	}
+	if delay := limiter.reserve(50); delay < 40*time.Millisecond || delay > 50*time.Millisecond {
+		t.Fatalf("empty bucket delay = %v, want ~50ms", delay)
+	}
+	// 超过突发上限的消息在桶满时放行, 之后由后续消息偿还.
+	limiter = &rateLimiter{bytes: newTokenBucket(1000, 100, now)}
+	if delay := limiter.reserve(300); delay != 0 {
+		t.Fatalf("oversized message on full bucket delayed: %v", delay)
+	}
+	if delay := limiter.reserve(1); delay < 190*time.Millisecond {
+		t.Fatalf("debt not repaid: delay %v", delay)
+	}
+}
+
+// batchConn 记录每次 WriteBatch 的消息数.
+type batchConn struct {
+	*fakeConn
+	mu      sync.Mutex
+	batches []int
+}
+
+func (c *batchConn) WriteBatch(ctx context.Context, data []testMessage) error {
+	c.mu.Lock()
+	c.batches = append(c.batches, len(data))
+	c.mu.Unlock()
+	for _, msg := range data {
+		if err := c.Write(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestClientBatchWriterCoalescesQueuedWrites(t *testing.T) {
+	conn := &batchConn{fakeConn: newFakeConn()}
+	client := NewClient[testMessage, *batchConn]()
+	client.SetWriteBatchSize(4)
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	// 用阻塞的回调卡住 asyncGo, 让写请求在队列中积压.
+	release := make(chan struct{})
+	if err := client.AsyncCall(ctx, func(ctx context.Context, conn *batchConn) { <-release }); err != nil {
+		t.Fatalf("AsyncCall failed: %v", err)
+	}
+	for i := range 6 {
+		if err := client.Write(ctx, testMessage{value: string(rune('a' + i))}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	close(release)
+	for i := range 6 {
+		select {
+		case msg := <-conn.writeCh:
+			if want := string(rune('a' + i)); msg.value != want {
+				t.Fatalf("write %d = %q, want %q", i, msg.value, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("write %d not sent", i)
+		}
+	}
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if len(conn.batches) != 2 || conn.batches[0] != 4 || conn.batches[1] != 2 {
+		t.Fatalf("batches = %v, want [4 2]", conn.batches)
+	}
+	if stats := client.Stats(); stats.WriteBatches != 2 {
+		t.Fatalf("WriteBatches = %d, want 2", stats.WriteBatches)
+	}
+}
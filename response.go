@@ -0,0 +1,131 @@
+package net
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// DefaultMaxBodySize 是 Response.JSON / XML / Text / CheckStatus 等解码辅助在
+// Response.MaxBodySize <= 0 时使用的 body 上限 (解压后字节数).
+const DefaultMaxBodySize int64 = 32 << 20
+
+// statusSnippetSize 是 StatusError.Body 保留的最大字节数.
+const statusSnippetSize = 512
+
+// ErrBodyTooLarge 表示 body 超过 Response.MaxBodySize (或 DefaultMaxBodySize).
+var ErrBodyTooLarge = errors.New("http response body too large")
+
+// StatusError 是 CheckStatus / ExpectJSON 在非 2xx 响应时返回的错误.
+// Body 是 body 开头至多 512 字节的片段, 只用于排查, 可能截断在多字节字符中间.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	if len(e.Body) == 0 {
+		return fmt.Sprintf("http status %s", e.Status)
+	}
+	return fmt.Sprintf("http status %s: %s", e.Status, e.Body)
+}
+
+// ConfigureMaxBodySize 设置之后返回的 Response.MaxBodySize. n <= 0 恢复 DefaultMaxBodySize.
+// 只影响解码辅助与 Data; 直接 Read 不受限.
+func (h *HTTP) ConfigureMaxBodySize(n int64) {
+	h.maxBodySize = n
+}
+
+// bodyLimit 返回解码辅助使用的 body 上限.
+func (res *Response) bodyLimit() int64 {
+	if res.MaxBodySize > 0 {
+		return res.MaxBodySize
+	}
+	return DefaultMaxBodySize
+}
+
+// readLimited 读出至多 limit 字节的 (解压后) body 并关闭, 超出时返回 ErrBodyTooLarge.
+func (res *Response) readLimited(limit int64) (data []byte, err error) {
+	defer func() {
+		err = errors.Join(err, res.Close())
+	}()
+	if data, err = io.ReadAll(io.LimitReader(res, limit+1)); err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrBodyTooLarge
+	}
+	return data, nil
+}
+
+// JSON 读出 body 并按 JSON 解码到 v, 不检查状态码. body 总会被关闭.
+func (res *Response) JSON(v any) error {
+	data, err := res.readLimited(res.bodyLimit())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// XML 读出 body 并按 XML 解码到 v, 不检查状态码. body 总会被关闭.
+// XML 声明里的非 UTF-8 encoding 按 charset 转换.
+func (res *Response) XML(v any) error {
+	data, err := res.readLimited(res.bodyLimit())
+	if err != nil {
+		return err
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder.Decode(v)
+}
+
+// Text 读出 body 并按 Content-Type 的 charset (缺省时按 BOM / HTML meta 嗅探) 转成 UTF-8 字符串.
+// 不检查状态码. body 总会被关闭.
+func (res *Response) Text() (string, error) {
+	data, err := res.readLimited(res.bodyLimit())
+	if err != nil {
+		return "", err
+	}
+	encoding, name, certain := charset.DetermineEncoding(data, res.Header.Get("Content-Type"))
+	// 嗅探不出时 DetermineEncoding 兜底为 windows-1252, 此时合法 UTF-8 按 UTF-8 处理;
+	// Content-Type 明确声明 windows-1252 时照常转换.
+	if name == "utf-8" || (!certain && name == "windows-1252" && utf8.Valid(data)) {
+		return string(data), nil
+	}
+	text, err := encoding.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
+// CheckStatus 在状态码为 2xx 时返回 nil 且不动 body; 否则读出 body 开头片段,
+// 关闭 body 并返回 *StatusError.
+func (res *Response) CheckStatus() error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	limit := min(res.bodyLimit(), statusSnippetSize)
+	snippet, err := io.ReadAll(io.LimitReader(res, limit))
+	var statusErr error = &StatusError{StatusCode: res.StatusCode, Status: res.Status, Body: snippet}
+	// 片段读取/关闭失败不掩盖状态错误, 一并返回; 都成功时保持 *StatusError 本身便于类型断言.
+	if err = errors.Join(err, res.Close()); err != nil {
+		return errors.Join(statusErr, err)
+	}
+	return statusErr
+}
+
+// ExpectJSON 是 CheckStatus 后接 JSON: 非 2xx 时返回 *StatusError, 否则解码到 v.
+func (res *Response) ExpectJSON(v any) error {
+	if err := res.CheckStatus(); err != nil {
+		return err
+	}
+	return res.JSON(v)
+}
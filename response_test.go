@@ -0,0 +1,122 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseJSONAndText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"name":"alice","age":3}`))
+		case "/latin1":
+			w.Header().Set("Content-Type", "text/plain; charset=ISO-8859-1")
+			_, _ = w.Write([]byte{'c', 'a', 'f', 0xe9})
+		case "/cp1252":
+			// 声明 windows-1252 的字节恰好也是合法 UTF-8, 仍应按声明解码.
+			w.Header().Set("Content-Type", "text/plain; charset=windows-1252")
+			_, _ = w.Write([]byte{0xc3, 0xa9})
+		}
+	}))
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+
+	res, err := h.Request(context.Background(), server.URL+"/json", nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var v struct {
+		Name string `json:"name"`
+		Age  int    `json:"age"`
+	}
+	if err := res.ExpectJSON(&v); err != nil {
+		t.Fatalf("ExpectJSON failed: %v", err)
+	}
+	if v.Name != "alice" || v.Age != 3 {
+		t.Fatalf("decoded = %+v", v)
+	}
+
+	res, err = h.Request(context.Background(), server.URL+"/latin1", nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	text, err := res.Text()
+	if err != nil {
+		t.Fatalf("Text failed: %v", err)
+	}
+	if text != "café" {
+		t.Fatalf("Text() = %q, want %q", text, "café")
+	}
+
+	res, err = h.Request(context.Background(), server.URL+"/cp1252", nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if text, err = res.Text(); err != nil || text != "Ã©" {
+		t.Fatalf("Text() = %q, %v; want %q", text, err, "Ã©")
+	}
+}
+
+func TestResponseCheckStatusReturnsSnippet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(strings.Repeat("x", 4096)))
+	}))
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+
+	res, err := h.Request(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	var v map[string]any
+	err = res.ExpectJSON(&v)
+	statusErr, ok := errors.AsType[*StatusError](err)
+	if !ok {
+		t.Fatalf("ExpectJSON err = %v, want *StatusError", err)
+	}
+	if statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("StatusCode = %d, want 502", statusErr.StatusCode)
+	}
+	if len(statusErr.Body) != statusSnippetSize {
+		t.Fatalf("snippet length = %d, want %d", len(statusErr.Body), statusSnippetSize)
+	}
+}
+
+func TestResponseMaxBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("y", 100)))
+	}))
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	h.ConfigureMaxBodySize(64)
+
+	res, err := h.Request(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if _, err := res.Text(); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("Text err = %v, want ErrBodyTooLarge", err)
+	}
+
+	h.ConfigureMaxBodySize(100)
+	res, err = h.Request(context.Background(), server.URL, nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if data, err := res.Data(); err != nil || len(data) != 100 {
+		t.Fatalf("Data() = %d bytes, %v; want 100 bytes", len(data), err)
+	}
+}
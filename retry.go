@@ -0,0 +1,161 @@
+package net
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy 决定 HTTP.RequestMethod 第 attempt 次尝试 (0 起) 之后是否重试.
+// res / err 是该次尝试的结果 (二者恰有一个非 nil). delay > 0 时覆盖 backoff 给出的等待,
+// 否则按 HTTP.ConfigureRetryBackoff (缺省 DefaultRetryBackoff) 等待.
+// 是否还有剩余次数由 AutoRetry 决定, 策略不必关心.
+type RetryPolicy func(attempt int, req *http.Request, res *http.Response, err error) (retry bool, delay time.Duration)
+
+// RetryOnError 只在 client.Do 返回错误时重试, 不看方法与状态码, 即旧 AutoRetry 的行为.
+// 需要对 POST 等非幂等请求也按错误重试时显式设置它.
+func RetryOnError(attempt int, req *http.Request, res *http.Response, err error) (bool, time.Duration) {
+	return err != nil, 0
+}
+
+// RetryStatus 返回在传输错误或状态码属于 codes 时重试的策略. codes 为空时用
+// 429 / 502 / 503 / 504.
+func RetryStatus(codes ...int) RetryPolicy {
+	if len(codes) == 0 {
+		codes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	return func(attempt int, req *http.Request, res *http.Response, err error) (bool, time.Duration) {
+		if err != nil {
+			return true, 0
+		}
+		return slices.Contains(codes, res.StatusCode), 0
+	}
+}
+
+// RetryIdempotent 包装 policy, 只允许幂等请求重试: GET / HEAD / OPTIONS / TRACE / PUT / DELETE,
+// 或带 Idempotency-Key 头的请求. 其余 (POST / PATCH 等) 一律不重试, 避免重复提交.
+func RetryIdempotent(policy RetryPolicy) RetryPolicy {
+	return func(attempt int, req *http.Request, res *http.Response, err error) (bool, time.Duration) {
+		if !isIdempotent(req) {
+			return false, 0
+		}
+		return policy(attempt, req, res, err)
+	}
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// RetryAfter 包装 policy, policy 决定重试且响应带 Retry-After (秒数或 HTTP 日期) 时按其等待.
+// Retry-After 超过 limit 时放弃重试, 直接返回该响应; limit <= 0 表示不设上限.
+func RetryAfter(policy RetryPolicy, limit time.Duration) RetryPolicy {
+	return func(attempt int, req *http.Request, res *http.Response, err error) (bool, time.Duration) {
+		retry, delay := policy(attempt, req, res, err)
+		if !retry || res == nil {
+			return retry, delay
+		}
+		after, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			return retry, delay
+		}
+		if limit > 0 && after > limit {
+			return false, 0
+		}
+		return true, after
+	}
+}
+
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(at.Sub(now), 0), true
+}
+
+// DefaultRetryPolicy 是未配置 RetryPolicy 时的默认策略: 只重试幂等请求, 在传输错误或
+// 429/502/503/504 时重试, 并遵守不超过 30s 的 Retry-After.
+var DefaultRetryPolicy = RetryAfter(RetryIdempotent(RetryStatus()), 30*time.Second)
+
+// ExponentialBackoff 返回 base * 2^attempt、上限 limit 的 backoff, 再加 0~25% jitter
+// 防止多 client 同步 retry 雷暴. attempt 从 0 起计数.
+func ExponentialBackoff(base, limit time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := exponentialDelay(base, limit, attempt)
+		if quarter := int64(delay / 4); quarter > 0 {
+			delay += time.Duration(rand.Int64N(quarter))
+		}
+		return delay
+	}
+}
+
+// EqualJitterBackoff 返回 "equal jitter" backoff: 取 d = min(base * 2^attempt, limit),
+// 在 [d/2, d] 内均匀随机. 与 ExponentialBackoff 只上浮 25% 相比, 同一轮失败的多个 client
+// 分散得更开, 且不超过 limit. attempt 从 0 起计数.
+func EqualJitterBackoff(base, limit time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := exponentialDelay(base, limit, attempt)
+		half := delay / 2
+		if half <= 0 {
+			return delay
+		}
+		return half + time.Duration(rand.Int64N(int64(delay-half)+1))
+	}
+}
+
+// exponentialDelay 返回 min(base * 2^attempt, limit), 移位前判上限避免大 attempt 溢出.
+func exponentialDelay(base, limit time.Duration, attempt int) time.Duration {
+	if attempt < 0 {
+		attempt = 0
+	}
+	if attempt < 62 && base <= limit>>uint(attempt) {
+		return base << uint(attempt)
+	}
+	return limit
+}
+
+// RetryAttempt 记录 RequestMethod 的一次尝试.
+type RetryAttempt struct {
+	StatusCode int           // 0 表示没有拿到响应
+	Err        error         // 传输错误
+	Duration   time.Duration // 本次往返耗时 (到响应头)
+	Delay      time.Duration // 本次之后、下一次之前的等待; 最后一次为 0
+}
+
+// RetryError 是 RequestMethod 用尽重试 (或策略放弃) 后最后一次尝试的错误, 带上全部尝试记录.
+// errors.Is / errors.As 可透过它匹配原错误.
+type RetryError struct {
+	Attempts []RetryAttempt
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, len(e.Attempts))
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigureRetryPolicy 设置重试策略. policy=nil 时恢复 DefaultRetryPolicy.
+// 只在 AutoRetry > 1 时生效; 需在调用 Request 前设置好.
+func (h *HTTP) ConfigureRetryPolicy(policy RetryPolicy) {
+	h.retryPolicy = policy
+}
@@ -0,0 +1,157 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestMethodRetriesStatusWithPolicy(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	h.ConfigureAutoRetry(5)
+	h.ConfigureRetryPolicy(DefaultRetryPolicy)
+	h.ConfigureRetryBackoff(func(int) time.Duration { return time.Millisecond })
+
+	res, err := h.RequestMethod(context.Background(), server.URL, http.MethodGet, nil, nil)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	if text, err := res.Text(); err != nil || text != "ok" {
+		t.Fatalf("Text() = %q, %v", text, err)
+	}
+	if len(res.Attempts) != 3 {
+		t.Fatalf("attempts = %d, want 3", len(res.Attempts))
+	}
+	for i, want := range []int{503, 503, 200} {
+		if res.Attempts[i].StatusCode != want {
+			t.Fatalf("attempt %d status = %d, want %d", i, res.Attempts[i].StatusCode, want)
+		}
+	}
+	if res.Attempts[2].Delay != 0 {
+		t.Fatalf("last attempt delay = %v, want 0", res.Attempts[2].Delay)
+	}
+}
+
+func TestRequestMethodPolicySkipsNonIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	h.ConfigureAutoRetry(3)
+	h.ConfigureRetryBackoff(func(int) time.Duration { return time.Millisecond })
+
+	res, err := h.RequestMethod(context.Background(), server.URL, http.MethodPost, nil, strings.NewReader("body"))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer res.Close()
+	if res.StatusCode != http.StatusBadGateway || calls.Load() != 1 {
+		t.Fatalf("status = %d, calls = %d; want 502 after a single call", res.StatusCode, calls.Load())
+	}
+
+	calls.Store(0)
+	headers := http.Header{"Idempotency-Key": {"k1"}}
+	res, err = h.RequestMethod(context.Background(), server.URL, http.MethodPost, headers, strings.NewReader("body"))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer res.Close()
+	if calls.Load() != 3 || len(res.Attempts) != 3 {
+		t.Fatalf("calls = %d, attempts = %d; want 3 with Idempotency-Key", calls.Load(), len(res.Attempts))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"7", 7 * time.Second, true},
+		{"-1", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"soon", 0, false},
+	}
+	for _, tc := range cases {
+		got, ok := parseRetryAfter(tc.value, now)
+		if got != tc.want || ok != tc.ok {
+			t.Fatalf("parseRetryAfter(%q) = %v, %v; want %v, %v", tc.value, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestExponentialBackoffBounds(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, time.Second)
+	for attempt, base := range []time.Duration{10, 20, 40, 80} {
+		base *= time.Millisecond
+		if got := backoff(attempt); got < base || got >= base+base/4 {
+			t.Fatalf("backoff(%d) = %v, want [%v, %v)", attempt, got, base, base+base/4)
+		}
+	}
+	if got := backoff(100); got < time.Second || got >= 1250*time.Millisecond {
+		t.Fatalf("backoff(100) = %v, want capped at 1s", got)
+	}
+}
+
+func TestEqualJitterBackoffBounds(t *testing.T) {
+	backoff := EqualJitterBackoff(10*time.Millisecond, time.Second)
+	for attempt, full := range []time.Duration{10, 20, 40, 80} {
+		full *= time.Millisecond
+		if got := backoff(attempt); got < full/2 || got > full {
+			t.Fatalf("backoff(%d) = %v, want [%v, %v]", attempt, got, full/2, full)
+		}
+	}
+	if got := backoff(100); got < 500*time.Millisecond || got > time.Second {
+		t.Fatalf("backoff(100) = %v, want within [500ms, 1s]", got)
+	}
+}
+
+func TestRequestMethodWrapsFinalErrorWithAttempts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	h := NewHTTP(nil)
+	defer h.Dispose()
+	h.ConfigureAutoRetry(3)
+	h.ConfigureRetryBackoff(func(int) time.Duration { return time.Millisecond })
+
+	_, err := h.RequestMethod(context.Background(), url, http.MethodGet, nil, nil)
+	retryErr, ok := errors.AsType[*RetryError](err)
+	if !ok || len(retryErr.Attempts) != 3 || retryErr.Err == nil {
+		t.Fatalf("err = %v, want *RetryError with 3 attempts", err)
+	}
+	if !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatalf("err = %q, want attempt count", err)
+	}
+
+	_, err = h.RequestMethod(context.Background(), url, http.MethodPost, nil, strings.NewReader("body"))
+	if retryErr, ok := errors.AsType[*RetryError](err); !ok || len(retryErr.Attempts) != 1 {
+		t.Fatalf("POST err = %v, want a single attempt under the default policy", err)
+	}
+}
@@ -0,0 +1,93 @@
+package net
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrUnrouted: 推送消息的路由 key 没有注册处理函数, 且没有兜底处理函数.
+var ErrUnrouted = fmt.Errorf("unrouted message")
+
+// PushHandler 处理一条未匹配到请求的消息 (对端推送).
+type PushHandler[M any] func(ctx context.Context, data M)
+
+// Router 按 key 把推送消息分发给注册的处理函数, 代替 Conn.Handle 里手写的 switch:
+//
+//	func (c *myConn) Handle(ctx context.Context, msg *Message) { c.router.Dispatch(ctx, msg) }
+//
+// 注册与分发均线程安全, 可与 SetHandleDispatch 的并发 Handle 配合使用.
+type Router[M any] struct {
+	key func(M) string
+
+	locker     sync.RWMutex
+	handlers   map[string]PushHandler[M]
+	onUnrouted func(ctx context.Context, data M, err error)
+	unrouted   atomic.Uint64
+}
+
+// NewRouter 创建 Router. key 从消息中取出路由 key, 例如方法名或类型标签.
+func NewRouter[M any](key func(M) string) *Router[M] {
+	return &Router[M]{key: key, handlers: make(map[string]PushHandler[M])}
+}
+
+// Handle 注册 (或替换) key 的处理函数; key 为 "" 时作为未注册 key 的兜底.
+// handler 为 nil 时删除该 key.
+func (router *Router[M]) Handle(key string, handler PushHandler[M]) {
+	router.locker.Lock()
+	defer router.locker.Unlock()
+	if handler == nil {
+		delete(router.handlers, key)
+		return
+	}
+	router.handlers[key] = handler
+}
+
+// OnUnrouted 设置 Dispatch 遇到无法路由的消息时的回调 (err 包装 ErrUnrouted), 用于记录
+// 错误或上报指标. 未设置时记 Debug 日志. 回调在 Handle 的协程中同步调用.
+func (router *Router[M]) OnUnrouted(fn func(ctx context.Context, data M, err error)) {
+	router.locker.Lock()
+	defer router.locker.Unlock()
+	router.onUnrouted = fn
+}
+
+// Route 把 data 交给 key 对应的处理函数, 未注册时交给兜底处理函数. 两者都没有时
+// 返回包装了 ErrUnrouted 的错误, 不计入 Unrouted.
+func (router *Router[M]) Route(ctx context.Context, data M) error {
+	key := router.key(data)
+	router.locker.RLock()
+	handler, ok := router.handlers[key]
+	if !ok {
+		handler, ok = router.handlers[""]
+	}
+	router.locker.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: key %q", ErrUnrouted, key)
+	}
+	handler(ctx, data)
+	return nil
+}
+
+// Dispatch 与 Conn.Handle 签名一致: 调用 Route, 无法路由时计入 Unrouted 并交给 OnUnrouted.
+func (router *Router[M]) Dispatch(ctx context.Context, data M) {
+	err := router.Route(ctx, data)
+	if err == nil {
+		return
+	}
+	router.unrouted.Add(1)
+	router.locker.RLock()
+	onUnrouted := router.onUnrouted
+	router.locker.RUnlock()
+	if onUnrouted != nil {
+		onUnrouted(ctx, data, err)
+		return
+	}
+	slog.Debug("net router dropped message", slog.Any("err", err))
+}
+
+// Unrouted 返回 Dispatch 丢弃的无法路由消息的累计数.
+func (router *Router[M]) Unrouted() uint64 {
+	return router.unrouted.Load()
+}
@@ -0,0 +1,75 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// routedConn 用 Router 代替 fakeConn 的 Handle.
+type routedConn struct {
+	*fakeConn
+	router *Router[testMessage]
+}
+
+func (c *routedConn) Handle(ctx context.Context, msg testMessage) {
+	c.router.Dispatch(ctx, msg)
+}
+
+func TestRouterDispatchesPushes(t *testing.T) {
+	router := NewRouter(func(m testMessage) string { return m.value })
+	var mu sync.Mutex
+	got := map[string]int{}
+	record := func(name string) PushHandler[testMessage] {
+		return func(ctx context.Context, msg testMessage) {
+			mu.Lock()
+			defer mu.Unlock()
+			got[name]++
+		}
+	}
+	router.Handle("price", record("price"))
+	router.Handle("news", record("news"))
+	unrouted := make(chan error, 1)
+	router.OnUnrouted(func(ctx context.Context, msg testMessage, err error) { unrouted <- err })
+
+	conn := &routedConn{fakeConn: newFakeConn(), router: router}
+	client := NewClient[testMessage, *routedConn]()
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+	for _, value := range []string{"price", "news", "price", "unknown"} {
+		conn.readCh <- testMessage{value: value}
+	}
+	select {
+	case err := <-unrouted:
+		if !errors.Is(err, ErrUnrouted) {
+			t.Fatalf("unrouted err = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("unrouted message was not reported")
+	}
+	mu.Lock()
+	if got["price"] != 2 || got["news"] != 1 {
+		t.Fatalf("routed counts = %v", got)
+	}
+	mu.Unlock()
+	if n := router.Unrouted(); n != 1 {
+		t.Fatalf("Unrouted = %d, want 1", n)
+	}
+
+	// 兜底处理函数接收未注册的 key; 删除后恢复为无法路由.
+	router.Handle("", record("fallback"))
+	if err := router.Route(context.Background(), testMessage{value: "other"}); err != nil {
+		t.Fatalf("Route with fallback failed: %v", err)
+	}
+	router.Handle("", nil)
+	if err := router.Route(context.Background(), testMessage{value: "other"}); !errors.Is(err, ErrUnrouted) {
+		t.Fatalf("Route without fallback = %v, want ErrUnrouted", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got["fallback"] != 1 {
+		t.Fatalf("fallback count = %d, want 1", got["fallback"])
+	}
+}
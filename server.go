@@ -0,0 +1,433 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrServerClosed: Server 已 Close / Shutdown. Serve 以此返回; Shutdown 期间新到的请求
+// 也以此错误交给 Reply.
+var ErrServerClosed = fmt.Errorf("server closed")
+
+// ErrNoHandler: 请求的路由 key 没有注册处理函数, 以此错误交给 Reply.
+var ErrNoHandler = fmt.Errorf("no handler for route")
+
+// ServerHandler 处理一个请求, 返回值经 ServerConfig.Reply 编上请求的 ID 后写回对端.
+// peer 是 Server 为该连接创建的 Client: 可以向对端发起 Request / Write (双向 RPC).
+// ctx 是连接级 context (ConnContext 派生), 连接断开时取消.
+type ServerHandler[M any, T Conn[M]] func(ctx context.Context, peer *Client[M, *ServerConn[M, T]], req M) (M, error)
+
+// ServerConfig 配置 Server. Wrap / Route / Reply 必填.
+type ServerConfig[M any, T Conn[M]] struct {
+	// Wrap 把 accept 到的连接包装为 T (例如 NewFramedConn). 返回错误时关闭连接.
+	Wrap func(conn net.Conn) (T, error)
+	// Route 返回请求的路由 key (如方法名); 第二个返回值 false 表示不是请求
+	// (推送 / 通知), 交给 T 的 Handle. 与本端 Request 匹配的响应不会到这里.
+	Route func(msg M) (string, bool)
+	// Reply 用处理结果构造带 req ID 的响应; 返回 false 表示不回复 (如通知).
+	// err 可能是处理函数的错误、ErrNoHandler 或 ErrServerClosed.
+	Reply func(req M, resp M, err error) (M, bool)
+	// MaxConns 限制同时服务的连接数, 达到上限时暂停 Accept. <=0 不限.
+	MaxConns int
+	// ConnContext 派生连接级 context (可附带认证信息等), nil 使用 Server 的基础 context.
+	ConnContext func(ctx context.Context, conn net.Conn) context.Context
+	// Configure 在连接的 Client Reset 之前调用 (可 SetHandleDispatch / AddUnaryInterceptor 等), 用于 SetBufferSize 等.
+	Configure func(peer *Client[M, *ServerConn[M, T]])
+	// OnConnect / OnDisconnect 在连接建立 / 结束时调用 (连接协程内同步执行).
+	OnConnect    func(peer *Client[M, *ServerConn[M, T]])
+	OnDisconnect func(peer *Client[M, *ServerConn[M, T]], err error)
+}
+
+// Server 是 Client 的服务端对应物: 从 net.Listener 接受连接, 每个连接包装为 T 并由
+// 一个 Client 多路复用; 对端请求按 Route 分发到注册的处理函数, 结果按同一 ID 写回.
+// 每个请求在独立协程中处理, 不阻塞该连接的响应匹配.
+type Server[M any, T Conn[M]] struct {
+	config  ServerConfig[M, T]
+	slots   chan struct{}
+	baseCtx context.Context
+	cancel  context.CancelFunc
+
+	locker    sync.RWMutex
+	handlers  map[string]ServerHandler[M, T]
+	listeners map[net.Listener]struct{}
+	conns     map[*ServerConn[M, T]]struct{}
+	closed    bool
+	waiter    sync.WaitGroup
+}
+
+// NewServer 创建 Server, 之后 Handle 注册处理函数并 Serve.
+func NewServer[M any, T Conn[M]](config ServerConfig[M, T]) *Server[M, T] {
+	ctx, cancel := context.WithCancel(context.Background())
+	server := &Server[M, T]{
+		config:    config,
+		baseCtx:   ctx,
+		cancel:    cancel,
+		handlers:  make(map[string]ServerHandler[M, T]),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[*ServerConn[M, T]]struct{}),
+	}
+	if config.MaxConns > 0 {
+		server.slots = make(chan struct{}, config.MaxConns)
+	}
+	return server
+}
+
+// Handle 注册 (或替换) 路由 key 的处理函数; key 为 "" 时作为未注册 key 的兜底. 线程安全.
+func (server *Server[M, T]) Handle(key string, handler ServerHandler[M, T]) {
+	server.locker.Lock()
+	defer server.locker.Unlock()
+	server.handlers[key] = handler
+}
+
+// Serve 在 listener 上接受连接直到 Close / Shutdown (返回 ErrServerClosed) 或 Accept
+// 出现非临时错误. 可对多个 listener 并发调用. listener 由 Server 关闭.
+func (server *Server[M, T]) Serve(listener net.Listener) error {
+	server.locker.Lock()
+	if server.closed {
+		server.locker.Unlock()
+		return ErrServerClosed
+	}
+	server.listeners[listener] = struct{}{}
+	server.locker.Unlock()
+	defer func() {
+		server.locker.Lock()
+		delete(server.listeners, listener)
+		server.locker.Unlock()
+	}()
+
+	var tempDelay time.Duration
+	for {
+		if server.slots != nil {
+			select {
+			case server.slots <- struct{}{}:
+			case <-server.baseCtx.Done():
+				return ErrServerClosed
+			}
+		}
+		conn, err := listener.Accept()
+		if err != nil {
+			server.releaseSlot()
+			if server.isClosed() {
+				return ErrServerClosed
+			}
+			// 与 net/http.Server 一致: 临时错误 (如 EMFILE) 退避重试.
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				tempDelay = min(max(2*tempDelay, 5*time.Millisecond), time.Second)
+				slog.Warn("net server accept failed; retrying", slog.Any("err", err), slog.Duration("delay", tempDelay))
+				time.Sleep(tempDelay)
+				continue
+			}
+			return err
+		}
+		tempDelay = 0
+		if !server.track() {
+			server.releaseSlot()
+			closeNetConn(conn)
+			return ErrServerClosed
+		}
+		go func() {
+			defer server.waiter.Done()
+			defer server.releaseSlot()
+			server.serveConn(conn)
+		}()
+	}
+}
+
+// ServeConn 服务一个已建立的连接 (例如自行 Accept 或 net.Pipe), 阻塞到连接结束.
+// 不占用 MaxConns 名额. Server 已关闭时直接关闭 conn 并返回 ErrServerClosed.
+func (server *Server[M, T]) ServeConn(conn net.Conn) error {
+	if !server.track() {
+		closeNetConn(conn)
+		return ErrServerClosed
+	}
+	defer server.waiter.Done()
+	server.serveConn(conn)
+	return nil
+}
+
+// track 在未关闭时为一个连接协程计数. 与 closed 同锁, 保证 Close 的 Wait 之后不会再 Add.
+func (server *Server[M, T]) track() bool {
+	server.locker.Lock()
+	defer server.locker.Unlock()
+	if server.closed {
+		return false
+	}
+	server.waiter.Add(1)
+	return true
+}
+
+func closeNetConn(conn net.Conn) {
+	if err := conn.Close(); err != nil {
+		slog.Debug("net server close connection failed", slog.Any("err", err))
+	}
+}
+
+func (server *Server[M, T]) releaseSlot() {
+	if server.slots != nil {
+		<-server.slots
+	}
+}
+
+func (server *Server[M, T]) isClosed() bool {
+	server.locker.RLock()
+	defer server.locker.RUnlock()
+	return server.closed
+}
+
+func (server *Server[M, T]) serveConn(netConn net.Conn) {
+	ctx := server.baseCtx
+	if server.config.ConnContext != nil {
+		ctx = server.config.ConnContext(ctx, netConn)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wrapped, err := server.config.Wrap(netConn)
+	if err != nil {
+		slog.Warn("net server wrap connection failed", slog.Any("remote", netConn.RemoteAddr()), slog.Any("err", err))
+		closeNetConn(netConn)
+		return
+	}
+	conn := &ServerConn[M, T]{conn: wrapped, netConn: netConn, server: server, ctx: ctx}
+	peer := NewClient[M, *ServerConn[M, T]]()
+	conn.peer = peer
+	if server.config.Configure != nil {
+		server.config.Configure(peer)
+	}
+
+	server.locker.Lock()
+	if server.closed {
+		server.locker.Unlock()
+		if closeErr := wrapped.Close(ctx); closeErr != nil {
+			slog.Debug("net server close late connection failed", slog.Any("err", closeErr))
+		}
+		return
+	}
+	server.conns[conn] = struct{}{}
+	// Reset 在 server 锁内完成: Close / Shutdown 拿到的 peer 一定已经连接.
+	peer.Reset(ctx, conn)
+	peer.locker.RLock()
+	stopChan := peer.stopChan
+	peer.locker.RUnlock()
+	server.locker.Unlock()
+
+	if server.config.OnConnect != nil {
+		server.config.OnConnect(peer)
+	}
+	<-stopChan
+	// 连接已断开 (或被 Close): 取消连接级 ctx 通知在途处理函数, Close 等 asyncGo 退出
+	// 后不会再有新的处理函数, 再等在途的返回 (其回写失败只记日志).
+	cancel()
+	closeErr := peer.Close()
+	conn.handlers.Wait()
+
+	server.locker.Lock()
+	delete(server.conns, conn)
+	server.locker.Unlock()
+	if server.config.OnDisconnect != nil {
+		server.config.OnDisconnect(peer, closeErr)
+	}
+}
+
+// Peers 返回当前所有连接的 Client 快照, 用于服务端主动推送.
+func (server *Server[M, T]) Peers() []*Client[M, *ServerConn[M, T]] {
+	server.locker.RLock()
+	defer server.locker.RUnlock()
+	peers := make([]*Client[M, *ServerConn[M, T]], 0, len(server.conns))
+	for conn := range server.conns {
+		peers = append(peers, conn.peer)
+	}
+	return peers
+}
+
+// stopLocked 标记关闭、关闭所有 listener 并返回当前连接快照. 必须持有 locker.
+func (server *Server[M, T]) stopLocked() []*ServerConn[M, T] {
+	server.closed = true
+	for listener := range server.listeners {
+		if err := listener.Close(); err != nil {
+			slog.Debug("net server close listener failed", slog.Any("err", err))
+		}
+	}
+	conns := make([]*ServerConn[M, T], 0, len(server.conns))
+	for conn := range server.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// Close 立即关闭: 停止 Accept, 关闭所有连接 (在途请求拿到 ErrConnectionClosed),
+// 等待连接协程退出.
+func (server *Server[M, T]) Close() error {
+	server.locker.Lock()
+	conns := server.stopLocked()
+	server.locker.Unlock()
+	server.cancel()
+	for _, conn := range conns {
+		if err := conn.peer.Close(); err != nil && !errors.Is(err, ErrConnectionClosed) {
+			slog.Debug("net server close peer failed", slog.Any("err", err))
+		}
+	}
+	server.waiter.Wait()
+	return nil
+}
+
+// Shutdown 优雅关闭: 停止 Accept, 新到的请求以 ErrServerClosed 回复, 等待在途处理函数
+// 返回并回写, 再对每个连接执行 Client.Shutdown (等本端发出的请求收到响应).
+// ctx 结束时不再等待, 剩余连接直接关闭并返回 ctx.Err().
+func (server *Server[M, T]) Shutdown(ctx context.Context) error {
+	server.locker.Lock()
+	conns := server.stopLocked()
+	server.locker.Unlock()
+
+	errs := make([]error, len(conns))
+	var waiter sync.WaitGroup
+	for i, conn := range conns {
+		waiter.Go(func() {
+			handled := make(chan struct{})
+			go func() {
+				conn.drain()
+				close(handled)
+			}()
+			select {
+			case <-handled:
+				errs[i] = conn.peer.Shutdown(ctx)
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				if err := conn.peer.Close(); err != nil && !errors.Is(err, ErrConnectionClosed) {
+					slog.Debug("net server close peer failed", slog.Any("err", err))
+				}
+			}
+		})
+	}
+	waiter.Wait()
+	server.cancel()
+	server.waiter.Wait()
+	return errors.Join(errs...)
+}
+
+// ServerConn 包装 T 供连接的 Client 使用: Read / Write / Close / Heart 透传, Handle 先按
+// Route 把请求分发给 Server 的处理函数, 其余消息交给 T 的 Handle.
+type ServerConn[M any, T Conn[M]] struct {
+	conn    T
+	netConn net.Conn
+	server  *Server[M, T]
+	peer    *Client[M, *ServerConn[M, T]]
+	ctx     context.Context
+
+	locker   sync.Mutex
+	draining bool
+	handlers sync.WaitGroup
+}
+
+// drain 让之后的请求以 ErrServerClosed 回复, 并等待在途处理函数返回.
+// draining 与 handlers.Add 在同一把锁内, 保证 Wait 之后不会再 Add.
+func (c *ServerConn[M, T]) drain() {
+	c.locker.Lock()
+	c.draining = true
+	c.locker.Unlock()
+	c.handlers.Wait()
+}
+
+// Conn 返回包装的 T.
+func (c *ServerConn[M, T]) Conn() T {
+	return c.conn
+}
+
+// NetConn 返回底层连接.
+func (c *ServerConn[M, T]) NetConn() net.Conn {
+	return c.netConn
+}
+
+// Context 返回连接级 context.
+func (c *ServerConn[M, T]) Context() context.Context {
+	return c.ctx
+}
+
+// Close 实现 Conn.
+func (c *ServerConn[M, T]) Close(ctx context.Context) error {
+	return c.conn.Close(ctx)
+}
+
+// Read 实现 Conn.
+func (c *ServerConn[M, T]) Read(ctx context.Context) (M, error) {
+	return c.conn.Read(ctx)
+}
+
+// Write 实现 Conn.
+func (c *ServerConn[M, T]) Write(ctx context.Context, data M) error {
+	return c.conn.Write(ctx, data)
+}
+
+// Heart 实现 ConnHeart: T 实现时透传, 否则与 Client 对无心跳连接的处理一致.
+func (c *ServerConn[M, T]) Heart(connect bool, count uint64) (M, time.Time, bool) {
+	if heartConn, ok := any(c.conn).(ConnHeart[M]); ok {
+		return heartConn.Heart(connect, count)
+	}
+	var zeroM M
+	return zeroM, time.Now().Add(60 * time.Second), false
+}
+
+// HeartAck 实现 ConnHeartAck: T 实现时透传, 否则不识别任何应答.
+func (c *ServerConn[M, T]) HeartAck(data M) bool {
+	if ackConn, ok := any(c.conn).(ConnHeartAck[M]); ok {
+		return ackConn.HeartAck(data)
+	}
+	return false
+}
+
+// CancelMessage 实现 ConnCancel: T 实现时透传, 否则不发送取消消息.
+func (c *ServerConn[M, T]) CancelMessage(id any) (M, bool) {
+	if cancelConn, ok := any(c.conn).(ConnCancel[M]); ok {
+		return cancelConn.CancelMessage(id)
+	}
+	var zeroM M
+	return zeroM, false
+}
+
+// Handle 实现 Conn.
+func (c *ServerConn[M, T]) Handle(ctx context.Context, msg M) {
+	key, ok := c.server.config.Route(msg)
+	if !ok {
+		c.conn.Handle(ctx, msg)
+		return
+	}
+	c.server.locker.RLock()
+	handler, found := c.server.handlers[key]
+	if !found {
+		handler, found = c.server.handlers[""]
+	}
+	c.server.locker.RUnlock()
+	if !found {
+		c.reply(msg, *new(M), ErrNoHandler)
+		return
+	}
+	c.locker.Lock()
+	if c.draining {
+		c.locker.Unlock()
+		c.reply(msg, *new(M), ErrServerClosed)
+		return
+	}
+	c.handlers.Go(func() {
+		resp, err := handler(c.ctx, c.peer, msg)
+		c.reply(msg, resp, err)
+	})
+	c.locker.Unlock()
+}
+
+// reply 直接写底层连接而不经过 peer 的发送队列: Handle 在 asyncGo 内调用, 向自己的
+// 队列投递可能死锁; Shutdown 期间 peer 也会拒绝新的 Write.
+func (c *ServerConn[M, T]) reply(req M, resp M, err error) {
+	out, ok := c.server.config.Reply(req, resp, err)
+	if !ok {
+		return
+	}
+	c.peer.traffic(TapWrite, out)
+	if writeErr := c.conn.Write(c.ctx, out); writeErr != nil {
+		slog.Debug("net server reply failed", slog.Any("err", writeErr))
+	}
+}
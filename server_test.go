@@ -0,0 +1,204 @@
+package net
+
+import (
+	"context"
+	"errors"
+	gonet "net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rpcPayload 是测试用的 RPC 载荷: Method 非空为请求, 否则为响应.
+type rpcPayload struct {
+	Method string `json:"method,omitempty"`
+	Body   string `json:"body,omitempty"`
+	Err    string `json:"err,omitempty"`
+}
+
+type rpcFrame = Frame[rpcPayload]
+
+type rpcPeer = Client[*rpcFrame, *ServerConn[*rpcFrame, *FramedConn[rpcPayload]]]
+
+func newTestServer(t *testing.T, config ServerConfig[*rpcFrame, *FramedConn[rpcPayload]]) (*Server[*rpcFrame, *FramedConn[rpcPayload]], string) {
+	t.Helper()
+	config.Wrap = func(conn gonet.Conn) (*FramedConn[rpcPayload], error) {
+		return NewFramedConn[rpcPayload](conn, nil, nil), nil
+	}
+	config.Route = func(msg *rpcFrame) (string, bool) {
+		return msg.Payload.Method, msg.Payload.Method != ""
+	}
+	config.Reply = func(req, resp *rpcFrame, err error) (*rpcFrame, bool) {
+		out := &rpcFrame{ID: req.ID}
+		if err != nil {
+			out.Payload.Err = err.Error()
+		} else if resp != nil {
+			out.Payload.Body = resp.Payload.Body
+		}
+		return out, true
+	}
+	listener, err := gonet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	server := NewServer(config)
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+	}()
+	return server, listener.Addr().String()
+}
+
+// dialTestServer 建立一个 Client 连接; handler 处理服务端发来的请求 (回调).
+func dialTestServer(t *testing.T, addr string, handler func(ctx context.Context, conn *FramedConn[rpcPayload], frame *rpcFrame)) *Client[*rpcFrame, *FramedConn[rpcPayload]] {
+	t.Helper()
+	netConn, err := gonet.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	var conn *FramedConn[rpcPayload]
+	conn = NewFramedConn(netConn, func(ctx context.Context, frame *rpcFrame) {
+		if handler != nil {
+			handler(ctx, conn, frame)
+		}
+	}, nil)
+	client := NewClient[*rpcFrame, *FramedConn[rpcPayload]]()
+	client.Reset(context.Background(), conn)
+	return client
+}
+
+func TestServerRoutesRequestsAndCallsBack(t *testing.T) {
+	server, addr := newTestServer(t, ServerConfig[*rpcFrame, *FramedConn[rpcPayload]]{})
+	server.Handle("echo", func(ctx context.Context, peer *rpcPeer, req *rpcFrame) (*rpcFrame, error) {
+		return &rpcFrame{Payload: rpcPayload{Body: "echo:" + req.Payload.Body}}, nil
+	})
+	// 处理函数内反向调用客户端, 验证同一连接上的双向 RPC.
+	server.Handle("greet", func(ctx context.Context, peer *rpcPeer, req *rpcFrame) (*rpcFrame, error) {
+		resp, err := peer.Request(ctx, NewRequestFrame(rpcPayload{Method: "name"}))
+		if err != nil {
+			return nil, err
+		}
+		return &rpcFrame{Payload: rpcPayload{Body: "hello " + resp.Payload.Body}}, nil
+	})
+	defer checkClose(t, "server", server.Close)
+
+	client := dialTestServer(t, addr, func(ctx context.Context, conn *FramedConn[rpcPayload], frame *rpcFrame) {
+		if frame.Payload.Method == "name" {
+			if err := conn.Write(ctx, &rpcFrame{ID: frame.ID, Payload: rpcPayload{Body: "alice"}}); err != nil {
+				t.Errorf("callback reply failed: %v", err)
+			}
+		}
+	})
+	defer func() { _ = client.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	resp, err := client.Request(ctx, NewRequestFrame(rpcPayload{Method: "echo", Body: "ping"}))
+	if err != nil || resp.Payload.Body != "echo:ping" {
+		t.Fatalf("echo: resp=%#v err=%v", resp, err)
+	}
+	resp, err = client.Request(ctx, NewRequestFrame(rpcPayload{Method: "greet"}))
+	if err != nil || resp.Payload.Body != "hello alice" {
+		t.Fatalf("greet: resp=%#v err=%v", resp, err)
+	}
+	resp, err = client.Request(ctx, NewRequestFrame(rpcPayload{Method: "missing"}))
+	if err != nil || resp.Payload.Err != ErrNoHandler.Error() {
+		t.Fatalf("missing: resp=%#v err=%v", resp, err)
+	}
+	if peers := server.Peers(); len(peers) != 1 {
+		t.Fatalf("Peers() = %d, want 1", len(peers))
+	}
+}
+
+func TestServerMaxConnsAndConnContext(t *testing.T) {
+	type userKey struct{}
+	disconnected := make(chan struct{}, 2)
+	server, addr := newTestServer(t, ServerConfig[*rpcFrame, *FramedConn[rpcPayload]]{
+		MaxConns: 1,
+		ConnContext: func(ctx context.Context, conn gonet.Conn) context.Context {
+			return context.WithValue(ctx, userKey{}, conn.RemoteAddr().String())
+		},
+		OnDisconnect: func(peer *rpcPeer, err error) {
+			disconnected <- struct{}{}
+		},
+	})
+	server.Handle("whoami", func(ctx context.Context, peer *rpcPeer, req *rpcFrame) (*rpcFrame, error) {
+		user, _ := ctx.Value(userKey{}).(string)
+		return &rpcFrame{Payload: rpcPayload{Body: user}}, nil
+	})
+	defer checkClose(t, "server", server.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	first := dialTestServer(t, addr, nil)
+	resp, err := first.Request(ctx, NewRequestFrame(rpcPayload{Method: "whoami"}))
+	if err != nil || !strings.HasPrefix(resp.Payload.Body, "127.0.0.1:") {
+		t.Fatalf("whoami: resp=%#v err=%v", resp, err)
+	}
+
+	// 名额已满: 第二个连接在 TCP 层建立但不会被服务, 请求超时.
+	second := dialTestServer(t, addr, nil)
+	defer func() { _ = second.Close() }()
+	shortCtx, shortCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	_, err = second.Request(shortCtx, NewRequestFrame(rpcPayload{Method: "whoami"}))
+	shortCancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second conn before slot freed: err=%v, want deadline", err)
+	}
+
+	// 第一个连接断开后名额释放, 第二个连接开始被服务 (之前发出的请求已超时丢弃).
+	_ = first.Close()
+	select {
+	case <-disconnected:
+	case <-ctx.Done():
+		t.Fatal("OnDisconnect was not called")
+	}
+	resp, err = second.Request(ctx, NewRequestFrame(rpcPayload{Method: "whoami"}))
+	if err != nil || resp.Payload.Body == "" {
+		t.Fatalf("second conn after slot freed: resp=%#v err=%v", resp, err)
+	}
+}
+
+func TestServerShutdownWaitsForHandlers(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server, addr := newTestServer(t, ServerConfig[*rpcFrame, *FramedConn[rpcPayload]]{})
+	server.Handle("slow", func(ctx context.Context, peer *rpcPeer, req *rpcFrame) (*rpcFrame, error) {
+		close(started)
+		<-release
+		return &rpcFrame{Payload: rpcPayload{Body: "done"}}, nil
+	})
+
+	client := dialTestServer(t, addr, nil)
+	defer func() { _ = client.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() {
+		resp, err := client.Request(ctx, NewRequestFrame(rpcPayload{Method: "slow"}))
+		if err == nil && resp.Payload.Body != "done" {
+			err = errors.New("unexpected body " + resp.Payload.Body)
+		}
+		result <- err
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- server.Shutdown(ctx) }()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned before handler finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatalf("in-flight request failed: %v", err)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if err := server.Serve(nil); !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("Serve after Shutdown = %v, want ErrServerClosed", err)
+	}
+}
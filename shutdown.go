@@ -0,0 +1,83 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrShuttingDown: Shutdown 开始后新的 Write / Request / Stream 被拒绝.
+var ErrShuttingDown = fmt.Errorf("client shutting down")
+
+// Shutdown 优雅关闭 Client: 立即拒绝新的 Write / Request / Stream (返回 ErrShuttingDown,
+// AsyncCall 不受影响), 等待已入队的写出完毕、等待响应的请求收到响应 (或被 caller 取消),
+// 然后 Close. ctx 结束时不再等待, 直接 Close 并返回 ctx.Err(), 剩余请求按 Close 语义
+// 拿到 ErrConnectionClosed. 等待期间连接自行断开也会立即结束等待.
+//
+// Supervise 模式下 Close 同时停止 supervisor. 正常完成返回 nil; 所有并发的 Shutdown
+// 都返回后才恢复接受发送, 可再次 Reset 复用 Client.
+func (client *Client[M, T]) Shutdown(ctx context.Context) error {
+	client.locker.Lock()
+	client.draining.Add(1)
+	stopChan := client.stopChan
+	asynchan, highchan, lowchan := client.asynchan, client.highchan, client.lowchan
+	client.locker.Unlock()
+	// Close 之后才退出 draining: 先返回的 Shutdown 不会让仍在排空的另一个重新接受发送.
+	defer client.draining.Add(-1)
+
+	var drainErr error
+	if asynchan != nil {
+	drainLoop:
+		for {
+			// 先取唤醒 chan 再检查: 检查之后的变化必然 close 这个 chan, 不会漏掉.
+			wake := client.drained.wait()
+			if client.inflight.Load() == 0 && len(asynchan)+len(highchan)+len(lowchan) == 0 && !client.throttling.Load() {
+				break
+			}
+			select {
+			case <-wake:
+			case <-stopChan:
+				break drainLoop
+			case <-ctx.Done():
+				drainErr = ctx.Err()
+				break drainLoop
+			}
+		}
+	}
+	err := client.Close()
+	if drainErr != nil {
+		return drainErr
+	}
+	if errors.Is(err, ErrConnectionClosed) {
+		return nil
+	}
+	return err
+}
+
+// drainSignal 唤醒等待排空的 Shutdown: wait 返回的 chan 在下一次 notify 时 close.
+// 无人等待时 notify 只是一次 atomic 读, 可以放在请求结束等热路径上.
+type drainSignal struct {
+	wake atomic.Pointer[chan struct{}]
+}
+
+func (signal *drainSignal) wait() <-chan struct{} {
+	for {
+		if wake := signal.wake.Load(); wake != nil {
+			return *wake
+		}
+		wake := make(chan struct{})
+		if signal.wake.CompareAndSwap(nil, &wake) {
+			return wake
+		}
+	}
+}
+
+func (signal *drainSignal) notify() {
+	if signal.wake.Load() == nil {
+		return
+	}
+	if wake := signal.wake.Swap(nil); wake != nil {
+		close(*wake)
+	}
+}
@@ -0,0 +1,76 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClientShutdownWaitsForPendingRequest(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.Reset(context.Background(), conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	respCh := make(chan testMessage, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		resp, err := client.Request(ctx, testMessage{id: "req-1", value: "request"})
+		respCh <- resp
+		errCh <- err
+	}()
+	req := <-conn.writeCh
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- client.Shutdown(ctx) }()
+	deadline := time.Now().Add(time.Second)
+	for !errors.Is(client.Write(ctx, testMessage{value: "late"}), ErrShuttingDown) {
+		if time.Now().After(deadline) {
+			t.Fatal("client still accepts Write during Shutdown")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned before pending request was answered: %v", err)
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	conn.readCh <- testMessage{id: req.id, value: "response"}
+	if err := <-errCh; err != nil {
+		t.Fatalf("pending Request failed during Shutdown: %v", err)
+	}
+	if resp := <-respCh; resp.value != "response" {
+		t.Fatalf("unexpected response: %#v", resp)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if !client.Stats().Closed {
+		t.Fatal("client not closed after Shutdown")
+	}
+}
+
+func TestClientShutdownHonorsContext(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	client.Reset(context.Background(), conn)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.Request(context.Background(), testMessage{id: "req-1", value: "request"})
+		errCh <- err
+	}()
+	<-conn.writeCh
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := client.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown err = %v, want context.DeadlineExceeded", err)
+	}
+	if err := <-errCh; !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("pending Request err = %v, want ErrConnectionClosed", err)
+	}
+}
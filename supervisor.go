@@ -0,0 +1,243 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// ErrAlreadySupervised: 同一 Client 上重复调用 Supervise. 一个 Client 同时只能有一个
+// supervisor 负责拨号, 否则两个重连循环会互相 Reset 对方的连接.
+var ErrAlreadySupervised = fmt.Errorf("client already supervised")
+
+// superviseStableWindow — 连接存活短于该窗口就断开, 视为一次失败的拨号尝试:
+// 继续累加 attempt 并走 backoff, 防止"拨通即被踢"的对端把重连退化成 hot loop.
+const superviseStableWindow = 10 * time.Second
+
+// DefaultReconnectBackoff 是 Client.Supervise 默认的重连间隔.
+// 500ms * 2^attempt, capped 30s, 再加 0~25% jitter 防止大量 client 同时重连.
+// attempt 从 0 起计数 (即 0 = 第一次失败后的等待).
+func DefaultReconnectBackoff(attempt int) time.Duration {
+	if attempt < 0 {
+		attempt = 0
+	}
+	const maxShiftBeforeCap = 6 // 500ms<<6 = 32s, 第一个被 cap 到 30s 的值.
+	base := 30 * time.Second
+	if attempt < maxShiftBeforeCap {
+		base = time.Duration(500*(1<<uint(attempt))) * time.Millisecond
+	}
+	if base > 30*time.Second {
+		base = 30 * time.Second
+	}
+	jitter := time.Duration(rand.Int64N(int64(base / 4)))
+	return base + jitter
+}
+
+// PendingPolicy 决定 Supervise 模式下连接断开时 Request / Write 的行为.
+type PendingPolicy int
+
+const (
+	// PendingFailFast 与未 supervise 时一致: 断线时 in-flight Request 立即拿到
+	// lastError (通常是 ErrConnectionClosed), 断线期间的新调用也立即失败.
+	PendingFailFast PendingPolicy = iota
+	// PendingWaitReconnect 让 Request / Write 在断线时等待 supervisor 建好下一条连接
+	// 后重新发送, 直到 caller ctx 结束. 已写出但未收到响应的请求会被重发 (at-least-once),
+	// 只应在请求幂等时使用. 只作用于加锁版本 Request / Write; *Unsafe 由 single owner
+	// goroutine 调用, 不能在其中阻塞等重连.
+	PendingWaitReconnect
+)
+
+// SuperviseConfig 配置 Client.Supervise.
+type SuperviseConfig[T any] struct {
+	// Backoff 返回第 attempt 次拨号失败后的等待时间; nil 使用 DefaultReconnectBackoff.
+	Backoff func(attempt int) time.Duration
+	// OnConnect 在每次新连接 Reset 完成后调用 (supervisor 协程内同步执行).
+	OnConnect func(conn T)
+	// OnDisconnect 在连接的 asyncGo 退出后调用, err 是该连接的 lastError.
+	// 主动 Close / supervise ctx 取消导致的退出不回调.
+	OnDisconnect func(conn T, err error)
+	// Pending 决定断线期间 Request / Write 的行为, 默认 PendingFailFast.
+	Pending PendingPolicy
+}
+
+// Supervise 让 Client 进入自动重连模式: 后台协程用 dial 建立连接并 Reset, 连接的
+// asyncGo 退出后按 Backoff 重新拨号, 直到 ctx 取消或 Close. 立即返回, 首次连接结果
+// 通过 OnConnect 通知.
+//
+// supervise 期间 caller 仍可手动 Reset 换连接, supervisor 会接管新连接而不是重新拨号.
+// 回调在 supervisor 协程内同步执行, 不得同步调用 Close (Close 会等待 supervisor 退出).
+func (client *Client[M, T]) Supervise(ctx context.Context, dial func(ctx context.Context) (T, error), config SuperviseConfig[T]) error {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	if client.superviseCancel != nil {
+		return ErrAlreadySupervised
+	}
+	if client.resetSignal == nil {
+		client.resetSignal = make(chan struct{})
+	}
+	sctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	client.superviseCancel = cancel
+	client.superviseDone = done
+	client.pendingPolicy = config.Pending
+	go client.superviseGo(sctx, dial, config, done)
+	return nil
+}
+
+// stopSuperviseLocked 取消 supervisor 并返回其退出信号 (未 supervise 时为 nil).
+// 必须在 Lock 下调用: supervisor 也在 Lock 下检查 ctx 后才 ResetUnsafe, 二者串行,
+// 保证 Close 返回后不会被 supervisor 用新连接"复活".
+func (client *Client[M, T]) stopSuperviseLocked() <-chan struct{} {
+	if client.superviseCancel != nil {
+		client.superviseCancel()
+		client.endPendingWaitLocked()
+	}
+	return client.superviseDone
+}
+
+// endPendingWaitLocked 退出 PendingWaitReconnect: supervisor 不会再建连接, 唤醒正在
+// 等重连的 caller, 让它们按 FailFast 重试一次后拿到真实错误, 而不是等到 ctx 结束.
+func (client *Client[M, T]) endPendingWaitLocked() {
+	client.pendingPolicy = PendingFailFast
+	if client.resetSignal != nil {
+		close(client.resetSignal)
+	}
+	client.resetSignal = make(chan struct{})
+}
+
+func (client *Client[M, T]) superviseGo(ctx context.Context, dial func(ctx context.Context) (T, error), config SuperviseConfig[T], done chan struct{}) {
+	defer func() {
+		client.locker.Lock()
+		if client.superviseDone == done {
+			client.superviseCancel = nil
+			client.superviseDone = nil
+			if client.pendingPolicy != PendingFailFast {
+				client.endPendingWaitLocked()
+			}
+		}
+		client.locker.Unlock()
+		close(done)
+	}()
+	backoff := config.Backoff
+	if backoff == nil {
+		backoff = DefaultReconnectBackoff
+	}
+	sleep := func(attempt int) bool {
+		timer := time.NewTimer(backoff(attempt))
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	attempt := 0
+	connected := false
+	for {
+		conn, err := dial(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("net client supervise dial failed",
+				slog.Int("attempt", attempt), slog.Any("err", err))
+			if !sleep(attempt) {
+				return
+			}
+			attempt++
+			continue
+		}
+		client.locker.Lock()
+		if ctx.Err() != nil {
+			client.locker.Unlock()
+			if closeErr := conn.Close(context.Background()); closeErr != nil {
+				slog.Debug("net client supervise close late connection failed", slog.Any("err", closeErr))
+			}
+			return
+		}
+		// 连接用不随 supervise 取消的 ctx: Close 先停 supervisor 再 CloseUnsafe, 若连接
+		// 随 sctx 取消, asyncGo 会走 ctx.Done() 分支把 lastError 置为 context.Canceled,
+		// Close / pending Request 就拿不到 ErrConnectionClosed (见 Client.handleCancel).
+		client.ResetUnsafe(context.WithoutCancel(ctx), conn)
+		stopChan := client.stopChan
+		ownStop := stopChan
+		client.locker.Unlock()
+		if connected {
+			client.reconnects.Add(1)
+			if observer := client.loadObserver(); observer != nil {
+				observer.Reconnect(observerComponent)
+			}
+		}
+		connected = true
+		connectedAt := time.Now()
+		if config.OnConnect != nil {
+			config.OnConnect(conn)
+		}
+
+		// 等当前连接结束. stopChan 被换掉说明 caller 手动 Reset 了新连接: 接管它继续等,
+		// 不重新拨号.
+		for {
+			select {
+			case <-stopChan:
+			case <-ctx.Done():
+				client.closeSupervisedConn(ownStop)
+				return
+			}
+			client.locker.RLock()
+			current, currentConn := client.stopChan, client.conn
+			client.locker.RUnlock()
+			if current == stopChan {
+				break
+			}
+			stopChan, conn = current, currentConn
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		lastErr := client.getLastError()
+		if config.OnDisconnect != nil {
+			config.OnDisconnect(conn, lastErr)
+		}
+		if time.Since(connectedAt) >= superviseStableWindow {
+			attempt = 0
+			continue
+		}
+		// 连接存活过短: 视为失败尝试, 走 backoff 再拨.
+		if !sleep(attempt) {
+			return
+		}
+		attempt++
+	}
+}
+
+// closeSupervisedConn 在 supervise ctx 被 caller 取消时关闭 supervisor 建立的连接
+// (连接的 ctx 不随 supervise 取消). stopChan 已换掉说明 caller 手动 Reset 过, 不动 caller 的连接;
+// Close 路径已 CloseUnsafe 过, 再调一次无副作用.
+func (client *Client[M, T]) closeSupervisedConn(stopChan chan struct{}) {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	if client.stopChan == stopChan {
+		client.CloseUnsafe()
+	}
+}
+
+// waitReconnect 供 PendingWaitReconnect 模式使用: 阻塞到 resetSignal 关闭 (下一次
+// ResetUnsafe) 或 ctx 结束. resetSignal 必须在发起本次尝试前 (与入队同一 RLock 内)
+// 读取, 否则重连恰好发生在失败与读取之间会错过信号.
+func waitReconnect(ctx context.Context, resetSignal <-chan struct{}) error {
+	select {
+	case <-resetSignal:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isDisconnected 判断 async 入队错误是否是"当前没有可用连接".
+func isDisconnected(err error) bool {
+	return errors.Is(err, ErrConnectionClosed) || errors.Is(err, ErrNotConnected)
+}
@@ -0,0 +1,192 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// superviseDialer 每次拨号返回一个新的 fakeConn, 并通过 conns 通知测试.
+func superviseDialer(conns chan *fakeConn) func(ctx context.Context) (*fakeConn, error) {
+	return func(ctx context.Context) (*fakeConn, error) {
+		conn := newFakeConn()
+		select {
+		case conns <- conn:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return conn, nil
+	}
+}
+
+func TestClientSuperviseRedialsAfterDisconnect(t *testing.T) {
+	client := NewClient[testMessage, *fakeConn]()
+	conns := make(chan *fakeConn, 4)
+	connected := make(chan *fakeConn, 4)
+	disconnected := make(chan error, 4)
+	err := client.Supervise(context.Background(), superviseDialer(conns), SuperviseConfig[*fakeConn]{
+		Backoff:      func(int) time.Duration { return 10 * time.Millisecond },
+		OnConnect:    func(conn *fakeConn) { connected <- conn },
+		OnDisconnect: func(conn *fakeConn, err error) { disconnected <- err },
+	})
+	if err != nil {
+		t.Fatalf("Supervise failed: %v", err)
+	}
+	if err := client.Supervise(context.Background(), superviseDialer(conns), SuperviseConfig[*fakeConn]{}); !errors.Is(err, ErrAlreadySupervised) {
+		t.Fatalf("second Supervise = %v, want ErrAlreadySupervised", err)
+	}
+
+	first := <-conns
+	select {
+	case conn := <-connected:
+		if conn != first {
+			t.Fatal("OnConnect reported a different connection")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("first connection was not reported")
+	}
+
+	checkClose(t, "first conn", func() error { return first.Close(context.Background()) })
+	select {
+	case err := <-disconnected:
+		if !errors.Is(err, ErrConnectionClosed) {
+			t.Fatalf("OnDisconnect err = %v, want ErrConnectionClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("disconnect was not reported")
+	}
+	select {
+	case <-conns:
+	case <-time.After(time.Second):
+		t.Fatal("supervisor did not redial")
+	}
+	select {
+	case <-connected:
+	case <-time.After(time.Second):
+		t.Fatal("reconnect was not reported")
+	}
+	if got := client.Stats().Reconnects; got != 1 {
+		t.Fatalf("Reconnects = %d, want 1", got)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- client.Close() }()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close blocked behind supervisor")
+	}
+	select {
+	case <-conns:
+		t.Fatal("supervisor dialed after Close")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestClientSupervisePendingWaitReconnectResendsRequest(t *testing.T) {
+	client := NewClient[testMessage, *fakeConn]()
+	conns := make(chan *fakeConn, 4)
+	err := client.Supervise(context.Background(), superviseDialer(conns), SuperviseConfig[*fakeConn]{
+		Backoff: func(int) time.Duration { return 10 * time.Millisecond },
+		Pending: PendingWaitReconnect,
+	})
+	if err != nil {
+		t.Fatalf("Supervise failed: %v", err)
+	}
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	respCh := make(chan testMessage, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		resp, err := client.Request(ctx, testMessage{id: "req-resend", value: "request"})
+		respCh <- resp
+		errCh <- err
+	}()
+
+	first := <-conns
+	select {
+	case <-first.writeCh:
+	case <-ctx.Done():
+		t.Fatal("request was not written on first connection")
+	}
+	// 已写出未响应时断线: PendingWaitReconnect 应在下一条连接上重发.
+	checkClose(t, "first conn", func() error { return first.Close(context.Background()) })
+
+	second := <-conns
+	select {
+	case req := <-second.writeCh:
+		if req.id != "req-resend" {
+			t.Fatalf("resent request = %#v", req)
+		}
+		second.readCh <- testMessage{id: req.id, value: "response"}
+	case <-ctx.Done():
+		t.Fatal("request was not resent after reconnect")
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if resp := <-respCh; resp.value != "response" {
+		t.Fatalf("unexpected response: %#v", resp)
+	}
+}
+
+func TestClientSuperviseCloseReportsConnectionClosed(t *testing.T) {
+	client := NewClient[testMessage, *fakeConn]()
+	conns := make(chan *fakeConn, 4)
+	if err := client.Supervise(context.Background(), superviseDialer(conns), SuperviseConfig[*fakeConn]{}); err != nil {
+		t.Fatalf("Supervise failed: %v", err)
+	}
+	first := <-conns
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.Request(context.Background(), testMessage{id: "pending", value: "request"})
+		errCh <- err
+	}()
+	select {
+	case <-first.writeCh:
+	case <-time.After(time.Second):
+		t.Fatal("request was not written")
+	}
+	if err := client.Close(); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("Close = %v, want ErrConnectionClosed", err)
+	}
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, ErrConnectionClosed) {
+			t.Fatalf("pending Request = %v, want ErrConnectionClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pending Request was not released by Close")
+	}
+}
+
+func TestClientSuperviseCtxCancelClosesConnection(t *testing.T) {
+	client := NewClient[testMessage, *fakeConn]()
+	conns := make(chan *fakeConn, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := client.Supervise(ctx, superviseDialer(conns), SuperviseConfig[*fakeConn]{}); err != nil {
+		t.Fatalf("Supervise failed: %v", err)
+	}
+	first := <-conns
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.Request(context.Background(), testMessage{id: "pending", value: "request"})
+		errCh <- err
+	}()
+	<-first.writeCh
+	cancel()
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, ErrConnectionClosed) {
+			t.Fatalf("pending Request = %v, want ErrConnectionClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelling the supervise ctx left the connection open")
+	}
+	checkClose(t, "client", client.Close)
+}
@@ -0,0 +1,151 @@
+package net
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// TapDirection 是被旁路捕获消息的方向.
+type TapDirection int
+
+const (
+	TapRead  TapDirection = iota // 从 Conn 读到的消息 (含心跳应答与未匹配的推送)
+	TapWrite                     // 写入 Conn 的消息 (含心跳与取消消息)
+)
+
+func (direction TapDirection) String() string {
+	switch direction {
+	case TapRead:
+		return "read"
+	case TapWrite:
+		return "write"
+	default:
+		return fmt.Sprintf("TapDirection(%d)", int(direction))
+	}
+}
+
+// MarshalText 把方向编码为 "read" / "write".
+func (direction TapDirection) MarshalText() ([]byte, error) {
+	switch direction {
+	case TapRead, TapWrite:
+		return []byte(direction.String()), nil
+	default:
+		return nil, fmt.Errorf("unknown tap direction: %d", int(direction))
+	}
+}
+
+// UnmarshalText 解析 MarshalText 的输出.
+func (direction *TapDirection) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "read":
+		*direction = TapRead
+	case "write":
+		*direction = TapWrite
+	default:
+		return fmt.Errorf("unknown tap direction: %q", text)
+	}
+	return nil
+}
+
+// TapEvent 是一条被捕获的消息, 同时也是 Recorder 的记录格式 (JSON lines, 每行一个对象):
+//
+//	{"dir":"write","time":"2024-05-01T12:00:00.123456789Z","id":"42","data":{...}}
+//
+// dir 为 "read" 或 "write"; time 为 RFC 3339 (纳秒精度); id 为消息 NotifyMessage.Id(),
+// 没有 Id 的消息省略该字段; data 为消息本身的 JSON 编码. 从文件读回时 id 按 JSON 解码为
+// string / float64 等基础类型, 只用于查看与过滤.
+type TapEvent[M any] struct {
+	Direction TapDirection `json:"dir"`
+	Time      time.Time    `json:"time"`
+	ID        any          `json:"id,omitempty"`
+	Data      M            `json:"data"`
+}
+
+// SetTap 设置旁路回调, nil 关闭. 回调在读写协程中同步调用 (读与写分属不同协程, 会并发),
+// 必须线程安全且尽快返回. 覆盖 Client 经 Conn 读到的每条消息, 以及发送队列、心跳、
+// 取消消息的每次写入; AsyncCall 回调中直接对 conn 的写入不经过 Client, 不会被捕获.
+// 写入在调用 Conn.Write 之前捕获, 保证请求先于其响应出现; 写失败的消息同样会被捕获,
+// 随后连接关闭. 可随时调用, 立即生效.
+func (client *Client[M, T]) SetTap(tap func(event TapEvent[M])) {
+	client.locker.Lock()
+	defer client.locker.Unlock()
+	if tap == nil {
+		client.tap.Store(nil)
+		return
+	}
+	client.tap.Store(&tap)
+}
+
+func (client *Client[M, T]) tapMessage(direction TapDirection, data M) {
+	tap := client.tap.Load()
+	if tap == nil {
+		return
+	}
+	event := TapEvent[M]{Direction: direction, Time: time.Now(), Data: data}
+	if notify, ok := any(data).(NotifyMessage); ok {
+		if id, ok := notify.Id(); ok {
+			event.ID = id
+		}
+	}
+	(*tap)(event)
+}
+
+// Recorder 把 TapEvent 以 JSON lines 写入 io.Writer, Tap 方法可直接交给 SetTap.
+// 并发安全. 写入出错后不再写入, 错误由 Err 返回.
+type Recorder[M any] struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	err     error
+}
+
+// NewRecorder 创建写入 w 的 Recorder. w 不做缓冲, 需要时由调用方包一层 bufio.Writer
+// 并在结束时 Flush.
+func NewRecorder[M any](w io.Writer) *Recorder[M] {
+	return &Recorder[M]{encoder: json.NewEncoder(w)}
+}
+
+// Tap 记录一条消息.
+func (recorder *Recorder[M]) Tap(event TapEvent[M]) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.err != nil {
+		return
+	}
+	if err := recorder.encoder.Encode(event); err != nil {
+		recorder.err = fmt.Errorf("record tap event: %w", err)
+	}
+}
+
+// Err 返回第一次写入失败的错误.
+func (recorder *Recorder[M]) Err() error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return recorder.err
+}
+
+// ReadRecords 读取 Recorder 写出的全部记录, 跳过空行. 出错时返回已读取的记录和带行号的错误.
+func ReadRecords[M any](r io.Reader) ([]TapEvent[M], error) {
+	var events []TapEvent[M]
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var event TapEvent[M]
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return events, fmt.Errorf("read tap record line %d: %w", line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return events, fmt.Errorf("read tap records: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,107 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientTapCapturesReadsAndWrites(t *testing.T) {
+	conn := newFakeConn()
+	client := NewClient[testMessage, *fakeConn]()
+	var mu sync.Mutex
+	var events []TapEvent[testMessage]
+	client.SetTap(func(event TapEvent[testMessage]) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	})
+	client.Reset(context.Background(), conn)
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go func() {
+		req := <-conn.writeCh
+		conn.readCh <- testMessage{id: req.id, value: "response"}
+	}()
+	if _, err := client.Request(ctx, testMessage{id: "req-1", value: "request"}); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	if err := client.Write(ctx, testMessage{value: "notify"}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	<-conn.writeCh
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n >= 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 3 {
+		t.Fatalf("captured %d events, want 3: %#v", len(events), events)
+	}
+	want := []struct {
+		direction TapDirection
+		id        any
+		value     string
+	}{
+		{TapWrite, "req-1", "request"},
+		{TapRead, "req-1", "response"},
+		{TapWrite, nil, "notify"},
+	}
+	for i, w := range want {
+		got := events[i]
+		if got.Direction != w.direction || got.ID != w.id || got.Data.value != w.value || got.Time.IsZero() {
+			t.Fatalf("event %d = %#v, want %v %v %q", i, got, w.direction, w.id, w.value)
+		}
+	}
+}
+
+type recordedPayload struct {
+	Seq  int    `json:"seq"`
+	Body string `json:"body"`
+}
+
+func TestRecorderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewRecorder[recordedPayload](&buf)
+	at := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	recorder.Tap(TapEvent[recordedPayload]{Direction: TapWrite, Time: at, ID: "7", Data: recordedPayload{Seq: 1, Body: "ping"}})
+	recorder.Tap(TapEvent[recordedPayload]{Direction: TapRead, Time: at.Add(time.Millisecond), Data: recordedPayload{Seq: 2, Body: "pong"}})
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("recorder error: %v", err)
+	}
+	first, _, _ := strings.Cut(buf.String(), "\n")
+	if want := `{"dir":"write","time":"2024-05-01T12:00:00.123456789Z","id":"7","data":{"seq":1,"body":"ping"}}`; first != want {
+		t.Fatalf("first line = %s, want %s", first, want)
+	}
+
+	events, err := ReadRecords[recordedPayload](&buf)
+	if err != nil {
+		t.Fatalf("ReadRecords failed: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("read %d records, want 2", len(events))
+	}
+	if events[0].Direction != TapWrite || events[0].ID != "7" || !events[0].Time.Equal(at) || events[0].Data.Body != "ping" {
+		t.Fatalf("unexpected first record: %#v", events[0])
+	}
+	if events[1].Direction != TapRead || events[1].ID != nil || events[1].Data.Seq != 2 {
+		t.Fatalf("unexpected second record: %#v", events[1])
+	}
+
+	if _, err := ReadRecords[recordedPayload](strings.NewReader(`{"dir":"sideways"}`)); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("bad direction error = %v", err)
+	}
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/zdypro888/net"
+	"github.com/zdypro888/net/metrics"
 )
 
 // Client WebSocket 客户端（基于 Session）。
@@ -22,6 +24,8 @@ type Client[T any] struct {
 	codecs         *codecSet
 	bufferSize     int
 	maxMessageSize int64
+	observer       metrics.Observer
+	tap            func(event net.TapEvent[*Message[T]])
 }
 
 // NewClient 创建客户端。可选 WithCodecs 配置支持的编码 (默认仅 JSON)。
@@ -39,7 +43,7 @@ func NewClientWithBuffer[T any](serverURL string, bufferSize int, opts ...Option
 	for _, opt := range opts {
 		opt(&o)
 	}
-	client := &Client[T]{serverURL: serverURL, codecs: newCodecSet(o.codecs), bufferSize: bufferSize, maxMessageSize: o.resolvedMaxMessageSize()}
+	client := &Client[T]{serverURL: serverURL, codecs: newCodecSet(o.codecs), bufferSize: bufferSize, maxMessageSize: o.resolvedMaxMessageSize(), observer: o.observer}
 	client.resetSessionLocked()
 	return client
 }
@@ -51,7 +55,8 @@ func (c *Client[T]) Handle() <-chan *Packet[T] {
 }
 
 func (c *Client[T]) resetSessionLocked() {
-	session := createSessionWithBuffer[T](uuid.New().String(), c.bufferSize)
+	session := createSessionWithBuffer[T](uuid.New().String(), c.bufferSize, c.observer)
+	session.SetTap(c.tap)
 	handleChan := make(chan *Packet[T], c.bufferSize)
 	c.session = session
 	c.handleChan = handleChan
@@ -236,6 +241,17 @@ func (c *Client[T]) Reply(ctx context.Context, id string, data T) error {
 	return session.Reply(ctx, id, data)
 }
 
+// SetTap 设置旁路回调, nil 关闭. 作用于当前及 Close 后重新 Connect 的 Session,
+// 语义见 Session.SetTap.
+func (c *Client[T]) SetTap(tap func(event net.TapEvent[*Message[T]])) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.tap = tap
+	if c.session != nil {
+		c.session.SetTap(tap)
+	}
+}
+
 // Close 关闭连接
 func (c *Client[T]) Close() error {
 	c.locker.RLock()
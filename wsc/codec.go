@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/zdypro888/net/metrics"
 )
 
 // CodecJSON 是默认 JSON codec 的 wire 标识符。
@@ -56,6 +57,7 @@ type options struct {
 	codecs             []Codec
 	maxMessageSize     int64
 	sessionIdleTimeout *time.Duration
+	observer           metrics.Observer
 }
 
 // resolvedMaxMessageSize 返回配置的入站消息上限, 未配置(<=0)时回退默认 MaxMessageSize。
@@ -85,6 +87,12 @@ func WithSessionIdleTimeout(timeout time.Duration) Option {
 	return func(o *options) { o.sessionIdleTimeout = &timeout }
 }
 
+// WithObserver 设置观测回调 (见 metrics 包), component 为 "wsc". 上报 Session 的
+// 建立 / 关闭与重连、Request 的耗时, 以及每个 websocket 帧的收发字节数 (含心跳).
+func WithObserver(observer metrics.Observer) Option {
+	return func(o *options) { o.observer = observer }
+}
+
 // codecSet 按优先级保存已配置的 codec, 并提供按名查找与协商。
 type codecSet struct {
 	order  []Codec
@@ -35,6 +35,9 @@ const (
 // 错误定义
 var ErrSessionClosed = errors.New("session closed")
 
+// ErrSessionShuttingDown Session.Shutdown 开始后新的 Write / Request 被拒绝 (Reply 仍允许).
+var ErrSessionShuttingDown = errors.New("session shutting down")
+
 // HandshakeRequest 握手请求
 type HandshakeRequest struct {
 	GUID    string `json:"guid"`
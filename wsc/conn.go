@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/zdypro888/net/metrics"
 )
 
 type messagechannel[T any] struct {
@@ -36,11 +37,13 @@ type wsconnection[T any] struct {
 	closeMux sync.Once
 	// codec 决定信封的 wire 编码 (JSON 文本帧 / proto 二进制帧), 握手协商得到。
 	codec Codec
+	// observer 按帧上报收发字节数, nil 表示不上报.
+	observer metrics.Observer
 }
 
 // createWSConnection 创建 WebSocket 连接封装, msgchan 由 Conn 管理。codec 为 nil 时
 // 回退到默认 JSON codec。
-func createWSConnection[T any](conn *websocket.Conn, bufferSize int, codec Codec) *wsconnection[T] {
+func createWSConnection[T any](conn *websocket.Conn, bufferSize int, codec Codec, observer metrics.Observer) *wsconnection[T] {
 	if bufferSize <= 0 {
 		bufferSize = DefaultBufferSize
 	}
@@ -50,9 +53,10 @@ func createWSConnection[T any](conn *websocket.Conn, bufferSize int, codec Codec
 	// 读上限已由 Client.dial / Server.OnConnection 按各自配置在握手前设于同一 conn,
 	// 此处不再重复 SetReadLimit, 以免用 const 覆盖掉调用方配置的 WithMaxMessageSize。
 	return &wsconnection[T]{
-		conn:    conn,
-		msgchan: make(chan *messagechannel[T], bufferSize),
-		codec:   codec,
+		conn:     conn,
+		msgchan:  make(chan *messagechannel[T], bufferSize),
+		codec:    codec,
+		observer: observer,
 	}
 }
 
@@ -94,6 +98,9 @@ func (c *wsconnection[T]) Read(ctx context.Context) (*Message[T], error) {
 	if err != nil {
 		return nil, err
 	}
+	if c.observer != nil {
+		c.observer.MessageReceived(observerComponent, len(data))
+	}
 	var msg Message[T]
 	if err := c.codec.Decode(messageType, data, &msg); err != nil {
 		return nil, err
@@ -111,6 +118,9 @@ func (c *wsconnection[T]) Write(ctx context.Context, data *Message[T]) error {
 		return err
 	}
 	err = c.conn.WriteMessage(messageType, payload)
+	if err == nil && c.observer != nil {
+		c.observer.MessageSent(observerComponent, len(payload))
+	}
 	if clearErr := c.conn.SetWriteDeadline(time.Time{}); err == nil && clearErr != nil {
 		return clearErr
 	}
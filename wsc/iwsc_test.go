@@ -1,10 +1,13 @@
 package wsc
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -422,7 +425,7 @@ func TestServerIdleCleanupDoesNotRemoveAdvancedGeneration(t *testing.T) {
 	server := NewServerWithBuffer[testPayload](64, WithSessionIdleTimeout(20*time.Millisecond))
 	defer checkClose(t, "server", server.Close)
 
-	session := createSessionWithBuffer[testPayload]("reconnecting-guid", 64)
+	session := createSessionWithBuffer[testPayload]("reconnecting-guid", 64, nil)
 	session.setOnDisconnect(server.scheduleSessionCleanup)
 	server.locker.Lock()
 	server.sessions[session.guid] = session
@@ -461,7 +464,7 @@ func TestServerCanceledCleanupDoesNotExpireSession(t *testing.T) {
 	server := NewServerWithBuffer[testPayload](64, WithSessionIdleTimeout(time.Hour))
 	defer checkClose(t, "server", server.Close)
 
-	session := createSessionWithBuffer[testPayload]("canceled-cleanup-guid", 64)
+	session := createSessionWithBuffer[testPayload]("canceled-cleanup-guid", 64, nil)
 	session.setOnDisconnect(server.scheduleSessionCleanup)
 	server.locker.Lock()
 	server.sessions[session.guid] = session
@@ -490,7 +493,7 @@ func TestServerReconnectFailureReschedulesIdleCleanup(t *testing.T) {
 	defer checkClose(t, "server", server.Close)
 
 	upgrader := websocket.Upgrader{}
-	session := createSessionWithBuffer[testPayload]("failed-reconnect-guid", 64)
+	session := createSessionWithBuffer[testPayload]("failed-reconnect-guid", 64, nil)
 	session.setOnDisconnect(server.scheduleSessionCleanup)
 	server.locker.Lock()
 	server.sessions[session.guid] = session
@@ -560,7 +563,7 @@ func TestServerDisconnectCoalescesBlockedClosedPacketDelivery(t *testing.T) {
 	server := NewServerWithBuffer[testPayload](1, WithSessionIdleTimeout(time.Hour))
 	defer checkClose(t, "server", server.Close)
 
-	session := createSessionWithBuffer[testPayload]("blocked-closed-consumer-guid", 1)
+	session := createSessionWithBuffer[testPayload]("blocked-closed-consumer-guid", 1, nil)
 	session.setOnDisconnect(server.scheduleSessionCleanup)
 	server.locker.Lock()
 	server.sessions[session.guid] = session
@@ -626,7 +629,7 @@ func TestServerDisconnectClosedSignalResetsAfterDelivery(t *testing.T) {
 	server := NewServerWithBuffer[testPayload](1, WithSessionIdleTimeout(time.Hour))
 	defer checkClose(t, "server", server.Close)
 
-	session := createSessionWithBuffer[testPayload]("closed-signal-reset-guid", 1)
+	session := createSessionWithBuffer[testPayload]("closed-signal-reset-guid", 1, nil)
 	session.setOnDisconnect(server.scheduleSessionCleanup)
 	server.locker.Lock()
 	server.sessions[session.guid] = session
@@ -687,7 +690,7 @@ func TestWSConnectionCloseDoesNotBlockWhenMessageChannelIsFull(t *testing.T) {
 		t.Fatalf("Dial failed: %v", err)
 	}
 
-	wsConn := createWSConnection[testPayload](conn, 1, defaultCodec)
+	wsConn := createWSConnection[testPayload](conn, 1, defaultCodec, nil)
 	wsConn.msgchan <- &messagechannel[testPayload]{Message: &Message[testPayload]{Data: testPayload{Kind: "queued"}}}
 
 	done := make(chan error, 1)
@@ -756,3 +759,218 @@ func TestServerSessionDirectCloseRemovesTableEntry(t *testing.T) {
 		t.Fatalf("server table still holds session %q after direct Close; zombie entry", guid)
 	}
 }
+
+func TestServerShutdownWaitsForPendingRequest(t *testing.T) {
+	server := NewServerWithBuffer[testPayload](64)
+	upgrader := websocket.Upgrader{}
+	sessionCh := make(chan *Session[testPayload], 1)
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if session, err := server.OnConnection(conn, nil); err == nil {
+			sessionCh <- session
+		}
+	}))
+	defer httpServer.Close()
+
+	client := NewClientWithBuffer[testPayload]("ws"+httpServer.URL[len("http"):], 64)
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	var session *Session[testPayload]
+	select {
+	case session = <-sessionCh:
+	case <-ctx.Done():
+		t.Fatalf("timed out waiting for session: %v", ctx.Err())
+	}
+
+	respCh := make(chan testPayload, 1)
+	reqErrCh := make(chan error, 1)
+	go func() {
+		resp, err := session.Request(ctx, testPayload{Kind: "request", Value: 1})
+		respCh <- resp
+		reqErrCh <- err
+	}()
+	var packet *Packet[testPayload]
+	select {
+	case packet = <-client.Handle():
+	case <-ctx.Done():
+		t.Fatalf("timed out waiting for server request: %v", ctx.Err())
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- server.Shutdown(ctx) }()
+	deadline := time.Now().Add(time.Second)
+	for !errors.Is(session.Write(ctx, testPayload{Kind: "late"}), ErrSessionShuttingDown) {
+		if time.Now().After(deadline) {
+			t.Fatal("session still accepts Write during Shutdown")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned before pending request was answered: %v", err)
+	default:
+	}
+
+	if err := client.Reply(ctx, packet.ID, testPayload{Kind: "reply", Value: 2}); err != nil {
+		t.Fatalf("Reply failed: %v", err)
+	}
+	if err := <-reqErrCh; err != nil {
+		t.Fatalf("pending Request failed during Shutdown: %v", err)
+	}
+	if resp := <-respCh; resp.Value != 2 {
+		t.Fatalf("unexpected response: %#v", resp)
+	}
+	select {
+	case err := <-shutdownErr:
+		if err != nil {
+			t.Fatalf("Shutdown failed: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("Shutdown did not finish after pending request completed")
+	}
+	if server.GetSession(session.GUID()) != nil {
+		t.Fatal("session still registered after Shutdown")
+	}
+}
+
+func TestClientTapRecordsMessages(t *testing.T) {
+	server := NewServerWithBuffer[testPayload](64)
+	upgrader := websocket.Upgrader{}
+	sessionCh := make(chan *Session[testPayload], 1)
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if session, err := server.OnConnection(conn, nil); err == nil {
+			sessionCh <- session
+		}
+	}))
+	defer httpServer.Close()
+	defer checkClose(t, "server", server.Close)
+
+	var buf bytes.Buffer
+	recorder := net.NewRecorder[*Message[testPayload]](&buf)
+	client := NewClientWithBuffer[testPayload]("ws"+httpServer.URL[len("http"):], 64)
+	client.SetTap(recorder.Tap)
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	session := <-sessionCh
+	go func() {
+		packet := <-session.Handle()
+		_ = session.Reply(context.Background(), packet.ID, testPayload{Kind: "reply", Value: packet.Data.Value + 1})
+	}()
+	if _, err := client.Request(ctx, testPayload{Kind: "request", Value: 1}); err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	client.SetTap(nil)
+	if err := recorder.Err(); err != nil {
+		t.Fatalf("recorder error: %v", err)
+	}
+
+	events, err := net.ReadRecords[*Message[testPayload]](&buf)
+	if err != nil {
+		t.Fatalf("ReadRecords failed: %v", err)
+	}
+	var request, response *net.TapEvent[*Message[testPayload]]
+	for i := range events {
+		event := &events[i]
+		if event.Data.IsHeart {
+			continue
+		}
+		switch {
+		case event.Direction == net.TapWrite && event.Data.Data.Kind == "request":
+			request = event
+		case event.Direction == net.TapRead && event.Data.Data.Kind == "reply":
+			response = event
+		}
+	}
+	if request == nil || response == nil {
+		t.Fatalf("request/response not recorded: %s", buf.String())
+	}
+	if request.ID == nil || request.ID != response.ID || request.ID != request.Data.ID {
+		t.Fatalf("recorded ids: request=%v response=%v message=%q", request.ID, response.ID, request.Data.ID)
+	}
+}
+
+func TestSessionShutdownDeliversEveryAcceptedWrite(t *testing.T) {
+	server := NewServerWithBuffer[testPayload](64)
+	upgrader := websocket.Upgrader{}
+	sessionCh := make(chan *Session[testPayload], 1)
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if session, err := server.OnConnection(conn, nil); err == nil {
+			sessionCh <- session
+		}
+	}))
+	defer httpServer.Close()
+
+	client := NewClientWithBuffer[testPayload]("ws"+httpServer.URL[len("http"):], 64)
+	defer checkClose(t, "client", client.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Connect(ctx); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	var session *Session[testPayload]
+	select {
+	case session = <-sessionCh:
+	case <-ctx.Done():
+		t.Fatalf("timed out waiting for session: %v", ctx.Err())
+	}
+
+	var received atomic.Int64
+	go func() {
+		for range client.Handle() {
+			received.Add(1)
+		}
+	}()
+
+	// 并发 Write 与 Shutdown 竞争: 返回 nil 的 Write 必须在关闭前写出, 不能在排空后才入队.
+	var accepted atomic.Int64
+	var writers sync.WaitGroup
+	for range 8 {
+		writers.Go(func() {
+			for {
+				err := session.Write(ctx, testPayload{Kind: "burst"})
+				if err != nil {
+					if !errors.Is(err, ErrSessionShuttingDown) && !errors.Is(err, ErrSessionClosed) {
+						t.Errorf("Write failed: %v", err)
+					}
+					return
+				}
+				accepted.Add(1)
+			}
+		})
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := session.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	writers.Wait()
+
+	for received.Load() < accepted.Load() {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("client received %d of %d accepted writes", received.Load(), accepted.Load())
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
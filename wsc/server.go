@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/zdypro888/net/metrics"
 )
 
 // Server 管理多个 Session
@@ -19,6 +20,7 @@ type Server[T any] struct {
 	bufferSize         int
 	codecs             *codecSet
 	maxMessageSize     int64
+	observer           metrics.Observer
 	sessionIdleTimeout time.Duration
 }
 
@@ -51,6 +53,7 @@ func NewServerWithBuffer[T any](bufferSize int, opts ...Option) *Server[T] {
 		bufferSize:         bufferSize,
 		codecs:             newCodecSet(o.codecs),
 		maxMessageSize:     o.resolvedMaxMessageSize(),
+		observer:           o.observer,
 		sessionIdleTimeout: sessionIdleTimeout,
 	}
 }
@@ -174,7 +177,7 @@ func (server *Server[T]) OnConnection(conn *websocket.Conn, args any) (*Session[
 		session = existing
 		session.setOnDisconnect(server.scheduleSessionCleanup)
 	} else {
-		session = createSessionWithBuffer[T](req.GUID, server.bufferSize)
+		session = createSessionWithBuffer[T](req.GUID, server.bufferSize, server.observer)
 		session.setOnDisconnect(server.scheduleSessionCleanup)
 		// D4: 调用方直接 session.Close() (不经 RemoveSession) 时也要把表项摘除,
 		// 否则禁用 idle cleanup (WithSessionIdleTimeout<=0) 的配置下僵尸条目永不回收.
@@ -222,6 +225,26 @@ func (server *Server[T]) Close() error {
 	return err
 }
 
+// Shutdown 优雅关闭服务器: 摘除所有会话并并发调用 Session.Shutdown(ctx), 等全部
+// 完成后返回合并的错误. ctx 结束时尚未完成的会话直接 Close.
+func (server *Server[T]) Shutdown(ctx context.Context) error {
+	server.locker.Lock()
+	sessions := make([]*Session[T], 0, len(server.sessions))
+	for guid, session := range server.sessions {
+		server.cancelSessionCleanupLocked(guid)
+		delete(server.sessions, guid)
+		sessions = append(sessions, session)
+	}
+	server.locker.Unlock()
+	errs := make([]error, len(sessions))
+	var waiter sync.WaitGroup
+	for i, session := range sessions {
+		waiter.Go(func() { errs[i] = session.Shutdown(ctx) })
+	}
+	waiter.Wait()
+	return errors.Join(errs...)
+}
+
 // GetSession 获取指定 GUID 的会话
 func (server *Server[T]) GetSession(guid string) *Session[T] {
 	server.locker.Lock()
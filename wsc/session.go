@@ -11,6 +11,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/zdypro888/net"
+	"github.com/zdypro888/net/metrics"
 )
 
 // DefaultAsyncTimeout — async() 写 asyncChan 的兜底超时. caller 传 ctx
@@ -23,6 +24,9 @@ const DefaultAsyncTimeout = 30 * time.Second
 // 不丢帧, 仅观察.
 const HandchanBlockWarnThreshold = 5 * time.Second
 
+// observerComponent 是 wsc 上报给 metrics.Observer 的 component.
+const observerComponent = "wsc"
+
 type Packet[T any] struct {
 	ID     string // 请求 ID (用于回复)
 	Data   T
@@ -68,6 +72,17 @@ type Session[T any] struct {
 	// WithSessionIdleTimeout<=0 (禁用空闲清理) 配置下僵尸条目永不回收.
 	onClose      atomic.Pointer[func(session *Session[T])]
 	closedSignal atomic.Bool
+	// draining: Shutdown 进行中, 拒绝新的 Write / Request; 在 locker 写锁下置位, Write /
+	// Request 在读锁下检查. pendingRequests 是已入队但尚未拿到响应 (或失败) 的 Request 数,
+	// draining 后只减不增; 归零时 close drained (drainOnce), Shutdown 等它.
+	draining        atomic.Bool
+	pendingRequests atomic.Int64
+	drained         chan struct{}
+	drainOnce       sync.Once
+	// tap: SetTap 设置的旁路回调, 经 tapMessage 转给内部 net.Client (跨重连保持).
+	tap atomic.Pointer[func(event net.TapEvent[*Message[T]])]
+	// observer: WithObserver 配置, 创建时设定后只读; nil 表示不上报.
+	observer metrics.Observer
 }
 
 // Stats 拍 session 当前队列占用 + 观察性计数器. 不持锁 — Len/Cap 是 chan
@@ -126,6 +141,23 @@ func (s *Session[T]) advanceGeneration() uint64 {
 	return s.connGeneration.Add(1)
 }
 
+// SetTap 设置旁路回调, nil 关闭: 捕获本 Session 在 websocket 上读写的每条 Message
+// (含心跳), 语义见 net.Client.SetTap. 可直接传 net.NewRecorder[*Message[T]](w).Tap
+// 录制为 JSON lines. 跨重连保持, 可随时调用.
+func (s *Session[T]) SetTap(tap func(event net.TapEvent[*Message[T]])) {
+	if tap == nil {
+		s.tap.Store(nil)
+		return
+	}
+	s.tap.Store(&tap)
+}
+
+func (s *Session[T]) tapMessage(event net.TapEvent[*Message[T]]) {
+	if tap := s.tap.Load(); tap != nil {
+		(*tap)(event)
+	}
+}
+
 func (s *Session[T]) setOnDisconnect(fn func(session *Session[T], generation uint64)) {
 	s.onDisconnect.Store(&fn)
 }
@@ -146,7 +178,7 @@ func (s *Session[T]) notifyDisconnect(generation uint64) {
 	}
 }
 
-func createSessionWithBuffer[T any](guid string, bufferSize int) *Session[T] {
+func createSessionWithBuffer[T any](guid string, bufferSize int, observer metrics.Observer) *Session[T] {
 	if bufferSize <= 0 {
 		bufferSize = DefaultBufferSize
 	}
@@ -159,6 +191,11 @@ func createSessionWithBuffer[T any](guid string, bufferSize int) *Session[T] {
 		handchan:   handchan,
 		stopChan:   stopChan,
 		asyncChan:  asyncChan,
+		drained:    make(chan struct{}),
+		observer:   observer,
+	}
+	if observer != nil {
+		observer.SessionOpen(observerComponent)
 	}
 	// Go 1.25 WaitGroup.Go 自动 Add(1)/Done, 比显式 Add+defer Done 少一个易错点.
 	s.waiter.Go(func() { s.asyncGo(asyncChan, handchan, stopChan) })
@@ -171,6 +208,7 @@ const (
 	asyncCommandConn asyncCommand = iota + 1
 	asyncCommandWrite
 	asyncCommandRequest
+	asyncCommandShutdown
 )
 
 type asyncMsgErr[T any] struct {
@@ -183,6 +221,7 @@ type asyncInfo[T any] struct {
 	Conn    *websocket.Conn
 	Codec   Codec // 仅 asyncCommandConn 使用: 本次连接握手协商出的 codec
 	Request *Message[T]
+	Ctx     context.Context // 仅 asyncCommandShutdown 使用: rawconn.Shutdown 的等待上限
 
 	response chan *asyncMsgErr[T]
 	ready    chan error
@@ -293,13 +332,17 @@ func (s *Session[T]) handleMessageGo(handchan chan *Packet[T], msgchan <-chan *m
 func (s *Session[T]) asyncGo(asyncChan <-chan *asyncInfo[T], handchan chan *Packet[T], stopChan chan struct{}) {
 	rawconn := net.NewClient[*Message[T], *wsconnection[T]]()
 	rawconn.SetBufferSize(s.bufferSize)
+	rawconn.SetTap(s.tapMessage)
 	var recvWaiter sync.WaitGroup
 	for info := range asyncChan {
 		switch info.Command {
 		case asyncCommandConn: // 设置连接
 			// wsConn 所有权转移到 rawconn, rawconn.Close 时会关闭连接
-			wsConn := createWSConnection[T](info.Conn, s.bufferSize, info.Codec)
+			wsConn := createWSConnection[T](info.Conn, s.bufferSize, info.Codec, s.observer)
 			generation := s.advanceGeneration()
+			if generation > 1 && s.observer != nil {
+				s.observer.Reconnect(observerComponent)
+			}
 			s.wsconn.Store(wsConn)
 			// Go 1.25 WaitGroup.Go.
 			recvWaiter.Go(func() { s.handleMessageGo(handchan, wsConn.channel(), stopChan, generation) })
@@ -322,10 +365,24 @@ func (s *Session[T]) asyncGo(asyncChan <-chan *asyncInfo[T], handchan chan *Pack
 		case asyncCommandRequest: // 发送请求并等待响应; 断线由 info.Response 直接通知 caller, 不在此处重试
 			// RequestCallbackUnsafe 内部 async() 失败时会同步通过 info.Response
 			// 通知 caller, 这里仍然记 lastWriteError 用作运维观察 (不重复通知 caller).
-			if err := rawconn.RequestCallbackUnsafe(context.Background(), info.Request, info.Response); err != nil {
+			// 回调恰好一次 (响应 / 失败), 借此维护 Shutdown 等待的 pendingRequests.
+			callback := func(msg *Message[T], err error) {
+				info.Response(msg, err)
+				s.releaseRequest()
+			}
+			if err := rawconn.RequestCallbackUnsafe(context.Background(), info.Request, callback); err != nil {
 				s.writeErrors.Add(1)
 				s.lastWriteError.Store(&err)
 			}
+		case asyncCommandShutdown:
+			// Session.Shutdown 已等 pendingRequests 清空才投递本命令, 排在它之前的 Write 也已
+			// 按队列顺序交给 rawconn; rawconn 剩下的只是尚未写出的消息, 这里阻塞 asyncGo
+			// 直到写完并关闭连接.
+			err := rawconn.Shutdown(info.Ctx)
+			if info.ready != nil {
+				info.ready <- err
+				close(info.ready)
+			}
 		}
 	}
 	if err := rawconn.Close(); err != nil {
@@ -422,10 +479,15 @@ func (s *Session[T]) enqueueWrite(ctx context.Context, id string, data T) error
 }
 
 // Write 发送通知 (不等待响应). 持 RLock — 跟其它 Write/Reply/Request/Reset 并发安全,
-// Close 拿 WLock 时互斥.
+// Close 拿 WLock 时互斥. Shutdown 开始后返回 ErrSessionShuttingDown.
 func (s *Session[T]) Write(ctx context.Context, data T) error {
 	s.locker.RLock()
 	defer s.locker.RUnlock()
+	// 持 RLock 检查 draining: Shutdown 持 WLock 置位, 通过检查的调用必然在 Shutdown
+	// 开始等待前完成入队, 不会在排空之后才混进队列.
+	if s.draining.Load() {
+		return ErrSessionShuttingDown
+	}
 	return s.enqueueWrite(ctx, "", data)
 }
 
@@ -443,13 +505,26 @@ func (s *Session[T]) enqueueRequest(ctx context.Context, data T) (*asyncInfo[T],
 		Request:  &Message[T]{ID: uuid.New().String(), Data: data},
 		response: make(chan *asyncMsgErr[T], 0x01),
 	}
+	s.pendingRequests.Add(1)
 	if err := s.async(ctx, request); err != nil {
+		s.releaseRequest()
 		close(request.response)
 		return nil, err
 	}
 	return request, nil
 }
 
+// releaseRequest 结束一个 pendingRequests 计数; Shutdown 开始后最后一个结束时通知 drained.
+func (s *Session[T]) releaseRequest() {
+	if s.pendingRequests.Add(-1) == 0 && s.draining.Load() {
+		s.signalDrained()
+	}
+}
+
+func (s *Session[T]) signalDrained() {
+	s.drainOnce.Do(func() { close(s.drained) })
+}
+
 func (s *Session[T]) waitRequest(ctx context.Context, request *asyncInfo[T]) (T, error) {
 	var zero T
 	select {
@@ -473,9 +548,26 @@ func (s *Session[T]) waitRequest(ctx context.Context, request *asyncInfo[T]) (T,
 }
 
 // Request 入队阶段持 RLock (保证 asyncChan 不被并发 close), wait 阶段不持锁
-// (避免 Close 拿 Wlock 时 RLock 永远不释放 → 死锁).
+// (避免 Close 拿 Wlock 时 RLock 永远不释放 → 死锁). Shutdown 开始后返回 ErrSessionShuttingDown.
 func (s *Session[T]) Request(ctx context.Context, data T) (T, error) {
+	if s.observer != nil {
+		start := time.Now()
+		s.observer.RequestStart(observerComponent)
+		resp, err := s.request(ctx, data)
+		s.observer.RequestEnd(observerComponent, time.Since(start), err)
+		return resp, err
+	}
+	return s.request(ctx, data)
+}
+
+func (s *Session[T]) request(ctx context.Context, data T) (T, error) {
 	s.locker.RLock()
+	// 与 Write 同理, draining 检查与入队 (含 pendingRequests 计数) 同在 RLock 内.
+	if s.draining.Load() {
+		s.locker.RUnlock()
+		var zero T
+		return zero, ErrSessionShuttingDown
+	}
 	request, err := s.enqueueRequest(ctx, data)
 	s.locker.RUnlock()
 	if err != nil {
@@ -497,6 +589,9 @@ func (s *Session[T]) Close() error {
 	if s.asyncChan != nil {
 		close(s.asyncChan)
 		s.asyncChan = nil
+		if s.observer != nil {
+			s.observer.SessionClose(observerComponent)
+		}
 	}
 	if wsConn := s.wsconn.Load(); wsConn != nil {
 		if err := wsConn.Close(context.Background()); err != nil && !errors.Is(err, net.ErrConnectionClosed) {
@@ -508,3 +603,65 @@ func (s *Session[T]) Close() error {
 	s.notifyClose()
 	return nil
 }
+
+// Shutdown 优雅关闭 session: 拒绝新的 Write / Request (ErrSessionShuttingDown), Reply
+// 仍然可用 (对端此前发来的请求可以正常应答); 等待已入队的消息写出、已发出的 Request
+// 收到响应, 再由底层 net.Client.Shutdown 写完剩余消息后关闭连接, 最后 Close.
+// ctx 结束时不再等待, 直接 Close 并返回 ctx.Err(). 等待期间连接断开 (尚未重连) 时,
+// 在途 Request 由底层按断线语义立即失败, 等待随之结束.
+func (s *Session[T]) Shutdown(ctx context.Context) error {
+	// 持 WLock 置 draining: 等持 RLock 已通过检查的 Write / Request 入队完毕, 之后的调用
+	// 都会看到 draining, pendingRequests 不会再增长. 已入队的 Write 排在下面的
+	// shutdown 命令之前, 由 asyncGo 按顺序交给底层.
+	s.locker.Lock()
+	asyncChan := s.asyncChan
+	if asyncChan != nil {
+		s.draining.Store(true)
+	}
+	s.locker.Unlock()
+	if asyncChan == nil {
+		return nil
+	}
+
+	// 置位前已归零时 releaseRequest 看不到 draining, 由这里补发.
+	if s.pendingRequests.Load() == 0 {
+		s.signalDrained()
+	}
+	var drainErr error
+	select {
+	case <-s.drained:
+	case <-s.stopChan:
+	case <-ctx.Done():
+		drainErr = ctx.Err()
+	}
+	if drainErr == nil {
+		drainErr = s.shutdownRawConn(ctx)
+	}
+	return errors.Join(drainErr, s.Close())
+}
+
+// shutdownRawConn 让 asyncGo 对底层 net.Client 执行 Shutdown.
+func (s *Session[T]) shutdownRawConn(ctx context.Context) error {
+	info := &asyncInfo[T]{
+		Command: asyncCommandShutdown,
+		Ctx:     ctx,
+		ready:   make(chan error, 1),
+	}
+	s.locker.RLock()
+	err := s.async(ctx, info)
+	s.locker.RUnlock()
+	if err != nil {
+		if errors.Is(err, ErrSessionClosed) {
+			return nil
+		}
+		return err
+	}
+	select {
+	case err := <-info.ready:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.stopChan:
+		return nil
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/zdypro888/net/metrics"
 )
 
 func checkClose(t *testing.T, name string, closeFn func() error) {
@@ -75,7 +77,7 @@ func TestCopyLoopReturnsWhenWebSocketSideCloses(t *testing.T) {
 	pipeReader, pipeWriter := net.Pipe()
 	done := make(chan error, 1)
 	go func() {
-		done <- copyLoop(context.Background(), proxyConn, pipeReader)
+		done <- copyLoop(context.Background(), proxyConn, pipeReader, nil, "")
 	}()
 
 	if err := wsConn.Close(); err != nil {
@@ -93,6 +95,64 @@ func TestCopyLoopReturnsWhenWebSocketSideCloses(t *testing.T) {
 	checkClose(t, "pipe writer", pipeWriter.Close)
 }
 
+func TestCopyLoopReportsTunnelMetrics(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	serverConn := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, err := upgrader.Upgrade(w, r, nil); err == nil {
+			serverConn <- conn
+		}
+	}))
+	defer server.Close()
+	wsConn, _, err := websocket.DefaultDialer.Dial("ws"+server.URL[len("http"):], nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	proxyConn := <-serverConn
+
+	observer := metrics.NewPrometheus("test")
+	tunnelSide, remoteSide := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- copyLoop(context.Background(), proxyConn, tunnelSide, observer, "wsproxy.server")
+	}()
+	if err := wsConn.WriteMessage(websocket.BinaryMessage, []byte("hello")); err != nil {
+		t.Fatalf("ws write failed: %v", err)
+	}
+	buf := make([]byte, 16)
+	if n, err := remoteSide.Read(buf); err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("remote read %q, %v", buf[:n], err)
+	}
+	if _, err := remoteSide.Write([]byte("hi")); err != nil {
+		t.Fatalf("remote write failed: %v", err)
+	}
+	if _, message, err := wsConn.ReadMessage(); err != nil || string(message) != "hi" {
+		t.Fatalf("ws read %q, %v", message, err)
+	}
+	checkClose(t, "ws conn", wsConn.Close)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("copyLoop blocked after websocket close")
+	}
+	checkClose(t, "remote side", remoteSide.Close)
+
+	var out strings.Builder
+	if _, err := observer.WriteTo(&out); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	for _, want := range []string{
+		`test_tunnels_open{component="wsproxy.server"} 0`,
+		`test_tunnels_closed_total{component="wsproxy.server",result="ok"} 1`,
+		`test_received_bytes_total{component="wsproxy.server"} 5`,
+		`test_sent_bytes_total{component="wsproxy.server"} 2`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("metrics missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
 // TestServerCloseAllCancelsInflightDialout 回归 BUG-4:
 // 旧实现 onClientDialout 用 context.Background(), CloseAll 不取消在飞 copyLoop.
 // 修复后 Server.ctx 被 cancel + activeWG.Wait, CloseAll 返回时 dialout 已退出.
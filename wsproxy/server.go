@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/zdypro888/net/metrics"
 )
 
 var ErrNoConnection = errors.New("no available connection")
@@ -59,6 +60,10 @@ type Server struct {
 	// (关闭连接, slaver 侧按既有 backoff 重试), 防止死注册无界堆积.
 	MaxSessions int
 
+	// Observer 非 nil 时上报每条 dialout 隧道的开始 / 结束与收发字节数
+	// (component "wsproxy.server", 字节按客户端侧 websocket 计). 需在接收连接之前设置.
+	Observer metrics.Observer
+
 	// 生命周期管理: ctx 用于让 in-flight onClientDialout / copyLoop 在 CloseAll
 	// 时被 cancel. activeWG 等待所有在飞 dialout goroutine 退出.
 	// ctx/cancel 一次性创建, 之后只读 — 不是同步原语, 是 cancel 协议.
@@ -410,7 +415,7 @@ func (server *Server) onClientDialout(ctx context.Context, conn *websocket.Conn,
 	}
 	server.activeDialouts.Add(1)
 	defer server.activeDialouts.Add(-1)
-	if err := copyLoop(ctx, conn, session); err != nil {
+	if err := copyLoop(ctx, conn, session, server.Observer, "wsproxy.server"); err != nil {
 		slog.Warn("wsproxy onClientDialout copy loop failed", slog.Any("err", err))
 	}
 }
@@ -9,11 +9,16 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/zdypro888/net/metrics"
 )
 
 type Slaver struct {
 	Id    string
 	Token string
+
+	// Observer 非 nil 时上报每条隧道的开始 / 结束与收发字节数 (component "wsproxy.slaver").
+	// 需在 Start / Run 之前设置.
+	Observer metrics.Observer
 }
 
 func NewSlaver() *Slaver {
@@ -178,7 +183,7 @@ func (slaver *Slaver) dialContext(ctx context.Context, wsConn *websocket.Conn, n
 		return
 	}
 	stopContextClose()
-	if err := copyLoop(ctx, wsConn, conn); err != nil {
+	if err := copyLoop(ctx, wsConn, conn, slaver.Observer, "wsproxy.slaver"); err != nil {
 		slog.Warn("wsproxy slaver copy loop failed", slog.Any("err", err))
 	}
 }
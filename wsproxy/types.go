@@ -8,8 +8,10 @@ import (
 	"log/slog"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/zdypro888/net/metrics"
 )
 
 const MaxMessageSize = 32 << 20
@@ -74,8 +76,14 @@ func closeWebSocketOnContextDone(ctx context.Context, conn *websocket.Conn) func
 }
 
 // copyLoop 在 websocket 连接与 net.Conn 之间双向搬运数据, 任一方向出错或 ctx
-// 取消时关闭两端并返回. server 与 slaver 的隧道桥接共用.
-func copyLoop(ctx context.Context, wsConn *websocket.Conn, conn net.Conn) error {
+// 取消时关闭两端并返回. server 与 slaver 的隧道桥接共用. observer 非 nil 时按隧道
+// 上报 TunnelOpen / TunnelClose, 按 websocket 帧上报收发字节数.
+func copyLoop(ctx context.Context, wsConn *websocket.Conn, conn net.Conn, observer metrics.Observer, component string) (err error) {
+	if observer != nil {
+		start := time.Now()
+		observer.TunnelOpen(component)
+		defer func() { observer.TunnelClose(component, time.Since(start), err) }()
+	}
 	var closeOnce sync.Once
 	closeBoth := func() {
 		closeOnce.Do(func() {
@@ -100,16 +108,15 @@ func copyLoop(ctx context.Context, wsConn *websocket.Conn, conn net.Conn) error
 	var waiter sync.WaitGroup
 	errCh := make(chan error, 2)
 	waiter.Go(func() {
-		errCh <- normalizeCopyError("ws_to_conn", wsCopyToConn(closeBoth, wsConn, conn))
+		errCh <- normalizeCopyError("ws_to_conn", wsCopyToConn(closeBoth, wsConn, conn, observer, component))
 	})
 	waiter.Go(func() {
-		errCh <- normalizeCopyError("conn_to_ws", connCopyToWs(closeBoth, conn, wsConn))
+		errCh <- normalizeCopyError("conn_to_ws", connCopyToWs(closeBoth, conn, wsConn, observer, component))
 	})
 	waiter.Wait()
 	close(done)
 	close(errCh)
 
-	var err error
 	for copyErr := range errCh {
 		err = errors.Join(err, copyErr)
 	}
@@ -126,20 +133,23 @@ func normalizeCopyError(direction string, err error) error {
 	return fmt.Errorf("%s: %w", direction, err)
 }
 
-func wsCopyToConn(closeBoth func(), wsConn *websocket.Conn, conn net.Conn) error {
+func wsCopyToConn(closeBoth func(), wsConn *websocket.Conn, conn net.Conn, observer metrics.Observer, component string) error {
 	defer closeBoth()
 	for {
 		_, message, err := wsConn.ReadMessage()
 		if err != nil {
 			return err
 		}
+		if observer != nil {
+			observer.MessageReceived(component, len(message))
+		}
 		if _, err := conn.Write(message); err != nil {
 			return err
 		}
 	}
 }
 
-func connCopyToWs(closeBoth func(), conn net.Conn, wsConn *websocket.Conn) error {
+func connCopyToWs(closeBoth func(), conn net.Conn, wsConn *websocket.Conn, observer metrics.Observer, component string) error {
 	defer closeBoth()
 	buf := make([]byte, 32*1024)
 	for {
@@ -150,5 +160,8 @@ func connCopyToWs(closeBoth func(), conn net.Conn, wsConn *websocket.Conn) error
 		if err := wsConn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
 			return err
 		}
+		if observer != nil {
+			observer.MessageSent(component, n)
+		}
 	}
 }